@@ -0,0 +1,203 @@
+package antiabuse
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/metrics"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Heuristic string
+
+const (
+	// chats created by one user within 10 minutes
+	ChatBurst Heuristic = "chat_burst"
+	// chats created with the same name by one user within an hour
+	DuplicateChatName Heuristic = "duplicate_chat_name"
+	// signups and guest accounts from one IP within an hour
+	SignupBurst Heuristic = "signup_burst"
+)
+
+// Action is the reaction to a request, stronger actions have higher values.
+type Action int
+
+const (
+	Allow Action = iota
+	// the request goes through, the offender is flagged for admin review
+	Flag
+	// the request is rejected until the offender slows down
+	Throttle
+)
+
+func (a Action) String() string {
+	switch a {
+	case Flag:
+		return "flag"
+	case Throttle:
+		return "throttle"
+	default:
+		return "allow"
+	}
+}
+
+// window counts events per key within a sliding time window.
+type window struct {
+	length    time.Duration
+	events    map[string][]time.Time
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+func newWindow(length time.Duration) *window {
+	return &window{length: length, events: make(map[string][]time.Time)}
+}
+
+// recent returns the events of the key within the window, the caller holds the mutex.
+func (w *window) recent(key string, now time.Time) []time.Time {
+	// keys without recent events are dropped once per window
+	if now.Sub(w.lastSweep) > w.length {
+		for k, events := range w.events {
+			if now.Sub(events[len(events)-1]) > w.length {
+				delete(w.events, k)
+			}
+		}
+		w.lastSweep = now
+	}
+
+	events := w.events[key]
+	start := 0
+	for start < len(events) && now.Sub(events[start]) > w.length {
+		start++
+	}
+	return events[start:]
+}
+
+// count returns the number of events of the key within the window.
+func (w *window) count(key string, now time.Time) int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.recent(key, now))
+}
+
+// add records an event and returns the number of events of the key within the window, including this one.
+func (w *window) add(key string, now time.Time) int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	events := append(w.recent(key, now), now)
+	w.events[key] = events
+
+	return len(events)
+}
+
+var chatWindow = newWindow(10 * time.Minute)
+var chatNameWindow = newWindow(time.Hour)
+var signupWindow = newWindow(time.Hour)
+
+// score flags counts above the threshold and throttles counts above twice of it, a threshold of 0 disables it.
+func score(count int, threshold int) Action {
+	if threshold <= 0 || count <= threshold {
+		return Allow
+	}
+	if count > 2*threshold {
+		return Throttle
+	}
+	return Flag
+}
+
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// CheckChatCreation scores a chat the user is about to create, it is throttled if one more chat would exceed twice a threshold.
+// Created chats have to be recorded with RecordChatCreation.
+func CheckChatCreation(db *gorm.DB, cfg *common.Config, userId string, name string, logger *common.Logger) Action {
+	now := time.Now()
+	normalized := normalizeName(name)
+
+	burst := score(chatWindow.count(userId, now)+1, cfg.AbuseChatBurst)
+	duplicate := score(chatNameWindow.count(userId+"\n"+normalized, now)+1, cfg.AbuseDuplicateChatNames)
+
+	if burst == Throttle {
+		report(db, logger, userId, ChatBurst, burst, "too many chats created")
+	}
+	if duplicate == Throttle {
+		report(db, logger, userId, DuplicateChatName, duplicate, fmt.Sprintf("chat name %q used too often", normalized))
+	}
+
+	if burst == Throttle || duplicate == Throttle {
+		return Throttle
+	}
+	return Allow
+}
+
+// RecordChatCreation counts a chat the user created and flags the user when it exceeds a threshold.
+func RecordChatCreation(db *gorm.DB, cfg *common.Config, userId string, name string, logger *common.Logger) {
+	now := time.Now()
+	normalized := normalizeName(name)
+
+	burst := score(chatWindow.add(userId, now), cfg.AbuseChatBurst)
+	report(db, logger, userId, ChatBurst, burst, "too many chats created")
+
+	duplicate := score(chatNameWindow.add(userId+"\n"+normalized, now), cfg.AbuseDuplicateChatNames)
+	report(db, logger, userId, DuplicateChatName, duplicate, fmt.Sprintf("chat name %q used too often", normalized))
+}
+
+// CheckSignup scores a signup or guest account from the IP, it is throttled if one more signup would exceed twice the threshold.
+// Created accounts have to be recorded with RecordSignup.
+func CheckSignup(db *gorm.DB, cfg *common.Config, ip string, logger *common.Logger) Action {
+	action := score(signupWindow.count(ip, time.Now())+1, cfg.AbuseSignupBurst)
+	if action != Throttle {
+		return Allow
+	}
+
+	report(db, logger, "ip:"+ip, SignupBurst, action, "too many signups")
+	return action
+}
+
+// RecordSignup counts a signup or guest account from the IP and flags the IP when it exceeds the threshold.
+func RecordSignup(db *gorm.DB, cfg *common.Config, ip string, logger *common.Logger) {
+	action := score(signupWindow.add(ip, time.Now()), cfg.AbuseSignupBurst)
+	report(db, logger, "ip:"+ip, SignupBurst, action, "too many signups")
+}
+
+// report counts the action and flags the subject for review, unless it already has an open flag for the heuristic.
+func report(db *gorm.DB, logger *common.Logger, subject string, heuristic Heuristic, action Action, details string) {
+	if action == Allow {
+		return
+	}
+
+	metrics.ObserveAbuseAction(string(heuristic), action.String())
+	logger.PrintfWarning("Abuse heuristic %s for %s: %s (%s)", heuristic, subject, details, action)
+
+	var count int64
+	if err := db.Model(&database.AbuseFlag{}).
+		Where("subject = ? AND heuristic = ? AND reviewed_at IS NULL", subject, heuristic).
+		Count(&count).Error; err != nil {
+		logger.PrintfError("Error checking abuse flags of %s. Error: %s", subject, err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	// details holds at most 255 characters
+	if runes := []rune(details); len(runes) > 255 {
+		details = string(runes[:252]) + "..."
+	}
+
+	if err := db.Create(&database.AbuseFlag{
+		Subject:   subject,
+		Heuristic: string(heuristic),
+		Action:    action.String(),
+		Details:   details,
+	}).Error; err != nil {
+		logger.PrintfError("Error flagging %s for %s. Error: %s", subject, heuristic, err)
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// number of flags returned by GetAbuseFlags
+const abuseFlagsPageSize = 100
+
+// GetAbuseFlags returns the open flags of the antiabuse heuristics, or the reviewed ones, newest first.
+func GetAbuseFlags(db *gorm.DB, query *AbuseFlagsRequest, logger *common.Logger) ([]AbuseFlagResponse, *api.ApiError) {
+	tx := db.Where("reviewed_at IS NULL")
+	if query.Reviewed {
+		tx = db.Where("reviewed_at IS NOT NULL")
+	}
+
+	var flags []database.AbuseFlag
+	if err := tx.Order("created_at desc").Limit(abuseFlagsPageSize).Find(&flags).Error; err != nil {
+		logger.PrintfError("Error getting abuse flags. Error: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	response := make([]AbuseFlagResponse, 0, len(flags))
+	for _, flag := range flags {
+		response = append(response, AbuseFlagResponse{
+			Id:         flag.Id,
+			CreatedAt:  flag.CreatedAt,
+			Subject:    flag.Subject,
+			Heuristic:  flag.Heuristic,
+			Action:     flag.Action,
+			Details:    flag.Details,
+			ReviewedAt: flag.ReviewedAt,
+			ReviewedBy: flag.ReviewedBy,
+		})
+	}
+
+	return response, nil
+}
+
+// ReviewAbuseFlag closes a flag in the name of the reviewing user, the next offence of the subject raises a new one.
+func ReviewAbuseFlag(db *gorm.DB, flagId string, reviewerId string, logger *common.Logger) *api.ApiError {
+	res := db.Model(&database.AbuseFlag{}).Where("id = ? AND reviewed_at IS NULL", flagId).Updates(map[string]interface{}{
+		"reviewed_at": time.Now(),
+		"reviewed_by": reviewerId,
+	})
+	if res.Error != nil {
+		logger.PrintfError("Error reviewing abuse flag: %s. Error: %s", flagId, res.Error)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if res.RowsAffected == 0 {
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	logger.Printf("Abuse flag: %s reviewed by: %s", flagId, reviewerId)
+
+	return nil
+}
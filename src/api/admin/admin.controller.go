@@ -0,0 +1,303 @@
+package admin
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/chat"
+	"easyflow-backend/src/api/telemetry"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/middleware"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAdminEndpoints(r *gin.RouterGroup) {
+	r.Use(middleware.LoggerMiddleware("Admin"))
+	r.Use(middleware.RateLimiter(1, 4))
+
+	// abuse flags are reviewed by moderators with their own account instead of the admin key
+	flags := r.Group("/abuse-flags", auth.AuthGuard(), auth.SessionGuard(), auth.RequireRole(enum.RoleAdmin, enum.RoleModerator))
+	flags.GET("", GetAbuseFlagsController)
+	flags.PUT("/:flagId/review", ReviewAbuseFlagController)
+
+	// the operator of an impersonation is the logged in admin, the shared admin key does not identify anyone
+	r.POST("/impersonate/:userId", auth.AuthGuard(), auth.SessionGuard(), auth.RequireRole(enum.RoleAdmin), ImpersonateController)
+
+	r.Use(AdminGuard())
+	r.GET("/log-level", GetLogLevelsController)
+	r.PUT("/log-level", SetLogLevelController)
+	r.GET("/captures", GetCapturedRequestsController)
+	r.POST("/reports/:type", StartReportController)
+	r.GET("/reports/:jobId", GetReportController)
+	r.PUT("/users/:userId/role", SetRoleController)
+	r.GET("/users/:userId/bandwidth", GetStreamBandwidthController)
+	r.GET("/users/:userId/connections", GetConnectionQualityController)
+}
+
+func GetLogLevelsController(c *gin.Context) {
+	_, _, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"default":   cfg.LogLevel,
+		"overrides": common.GetLogLevelOverrides(),
+	})
+}
+
+func SetLogLevelController(c *gin.Context) {
+	payload, logger, _, _, errors := common.SetupEndpoint[SetLogLevelRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	SetLogLevel(payload, logger)
+
+	c.JSON(http.StatusOK, common.GetLogLevelOverrides())
+}
+
+func GetCapturedRequestsController(c *gin.Context) {
+	_, _, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, middleware.GetCapturedRequests(time.Duration(cfg.DebugCaptureTTL)*time.Second))
+}
+
+func StartReportController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	job, err := StartReport(db, cfg, ReportType(c.Param("type")), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func GetReportController(c *gin.Context) {
+	_, logger, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	job, err := GetReport(cfg, c.Param("jobId"), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func SetRoleController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[SetRoleRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	if err := SetRole(db, c.Param("userId"), payload, logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func ImpersonateController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[ImpersonateRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	res, err := Impersonate(db, cfg, c.Param("userId"), user.(*auth.JWTAccessTokenPayload).UserId, payload, common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func GetStreamBandwidthController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	var query StreamBandwidthRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	days := query.Days
+	if days == 0 {
+		days = 7
+	}
+
+	res, err := chat.GetStreamBandwidth(db, cfg, c.Param("userId"), days, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// GetConnectionQualityController returns the connection quality of the live sessions of the user on this instance.
+func GetConnectionQualityController(c *gin.Context) {
+	c.JSON(http.StatusOK, telemetry.GetConnectionQuality(c.Param("userId")))
+}
+
+func GetAbuseFlagsController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	var query AbuseFlagsRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	flags, err := GetAbuseFlags(db, &query, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flags)
+}
+
+func ReviewAbuseFlagController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := ReviewAbuseFlag(db, c.Param("flagId"), user.(*auth.JWTAccessTokenPayload).UserId, logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"easyflow-backend/src/enum"
+	"time"
+)
+
+type SetLogLevelRequest struct {
+	// empty module applies to all modules
+	Module string `json:"module"`
+	// empty level removes the override
+	Level string `json:"level" validate:"omitempty,oneof=DEBUG INFO WARNING ERROR"`
+}
+
+type SetRoleRequest struct {
+	Role enum.Role `json:"role" validate:"required,oneof=USER MODERATOR ADMIN"`
+}
+
+type StreamBandwidthRequest struct {
+	// number of days including today, defaults to 7
+	Days int `form:"days" validate:"omitempty,min=1,max=90"`
+}
+
+type AbuseFlagsRequest struct {
+	// reviewed flags are only returned when set, newest first
+	Reviewed bool `form:"reviewed"`
+}
+
+type AbuseFlagResponse struct {
+	Id         string     `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	Subject    string     `json:"subject"`
+	Heuristic  string     `json:"heuristic"`
+	Action     string     `json:"action"`
+	Details    string     `json:"details"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+	ReviewedBy *string    `json:"reviewedBy,omitempty"`
+}
+
+type ReportJobResponse struct {
+	Id          string       `json:"id"`
+	Type        ReportType   `json:"type"`
+	Status      ReportStatus `json:"status"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	FinishedAt  *time.Time   `json:"finishedAt,omitempty"`
+	DownloadURL *string      `json:"downloadUrl,omitempty"`
+}
+
+type ImpersonateRequest struct {
+	// shown to the user in the audit log together with the id of the operator
+	Reason string `json:"reason" validate:"required,lte=500"`
+}
+
+type ImpersonateResponse struct {
+	// sent as "Authorization: Bearer <token>", it cannot be refreshed
+	AccessToken string    `json:"accessToken"`
+	TokenId     string    `json:"tokenId"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminGuard only lets requests through that carry the configured admin key in the X-Admin-Key header.
+// If no admin key is configured the admin endpoints are disabled.
+func AdminGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, logger, _, cfg, errs := common.SetupEndpoint[any](c)
+		if errs != nil {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:    http.StatusInternalServerError,
+				Error:   enum.ApiError,
+				Details: errs,
+			})
+			c.Abort()
+			return
+		}
+
+		adminKey := c.GetHeader("X-Admin-Key")
+		if cfg.AdminApiKey == "" || subtle.ConstantTimeCompare([]byte(adminKey), []byte(cfg.AdminApiKey)) != 1 {
+			logger.PrintfWarning("Rejected request to admin endpoint")
+			c.JSON(http.StatusForbidden, api.ApiError{
+				Code:  http.StatusForbidden,
+				Error: enum.NotAllowed,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+func SetLogLevel(payload *SetLogLevelRequest, logger *common.Logger) {
+	if payload.Level == "" {
+		common.ClearLogLevelOverride(payload.Module)
+		logger.Printf("Cleared log level override for module: %q", payload.Module)
+		return
+	}
+
+	common.SetLogLevelOverride(payload.Module, common.LogLevel(payload.Level))
+	logger.Printf("Set log level for module: %q to %s", payload.Module, payload.Level)
+}
+
+// SetRole changes the role of a user, it applies once the user refreshes the access token.
+func SetRole(db *gorm.DB, userId string, payload *SetRoleRequest, logger *common.Logger) *api.ApiError {
+	res := db.Model(&database.User{}).Where("id = ?", userId).Update("role", payload.Role)
+	if res.Error != nil {
+		logger.PrintfError("Error setting role of user: %s. Error: %s", userId, res.Error)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if res.RowsAffected == 0 {
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.UserNotFound,
+		}
+	}
+
+	logger.Printf("Set role of user: %s to %s", userId, payload.Role)
+
+	return nil
+}
+
+// Impersonate issues a token that lets the admin operatorId act as the user to reproduce issues.
+// The user sees the impersonation in the audit log and every request made with the token is logged.
+func Impersonate(db *gorm.DB, cfg *common.Config, userId string, operatorId string, payload *ImpersonateRequest, client common.ClientInfo, logger *common.Logger) (*ImpersonateResponse, *api.ApiError) {
+	var user database.User
+	if err := db.Where("id = ?", userId).First(&user).Error; err != nil {
+		logger.PrintfWarning("User with id: %s not found", userId)
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.UserNotFound,
+		}
+	}
+
+	if user.Disabled || user.DeletionScheduledAt != nil {
+		return nil, &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.AccountDisabled,
+		}
+	}
+
+	// an impersonated admin could grant roles with the token
+	if user.Role == enum.RoleAdmin {
+		logger.PrintfWarning("Rejected impersonation of admin: %s by: %s", userId, operatorId)
+		return nil, &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.NotAllowed,
+		}
+	}
+
+	token, claims, err := auth.IssueImpersonationToken(cfg, &user, operatorId)
+	if err != nil {
+		logger.PrintfError("Error generating impersonation token: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	details := fmt.Sprintf("%s: %s", operatorId, payload.Reason)
+	audit.Record(db, logger, user.Id, enum.Impersonated, client, &details)
+
+	logger.Printf("Issued impersonation token: %s of user: %s to: %s", claims.ID, user.Id, operatorId)
+
+	return &ImpersonateResponse{
+		AccessToken: token,
+		TokenId:     claims.ID,
+		ExpiresAt:   claims.ExpiresAt.Time,
+	}, nil
+}
@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/s3"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReportType string
+
+const (
+	UsersReport      ReportType = "users"
+	SignupsReport    ReportType = "signups"
+	ModerationReport ReportType = "moderation"
+)
+
+type ReportStatus string
+
+const (
+	ReportPending ReportStatus = "pending"
+	ReportRunning ReportStatus = "running"
+	ReportDone    ReportStatus = "done"
+	ReportFailed  ReportStatus = "failed"
+)
+
+// download links of finished reports are valid for one hour
+const reportDownloadExpiration = 60 * 60
+
+type reportJob struct {
+	Id         string
+	Type       ReportType
+	Status     ReportStatus
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+	ObjectKey  string
+}
+
+var reportJobs = make(map[string]*reportJob)
+var reportJobsMutex sync.Mutex
+
+func setReportStatus(job *reportJob, status ReportStatus) {
+	reportJobsMutex.Lock()
+	defer reportJobsMutex.Unlock()
+
+	job.Status = status
+	if status == ReportDone || status == ReportFailed {
+		now := time.Now()
+		job.FinishedAt = &now
+	}
+}
+
+func writeUsersReport(db *gorm.DB, w *csv.Writer) error {
+	if err := w.Write([]string{"id", "created_at", "email", "name", "email_verified"}); err != nil {
+		return err
+	}
+
+	var users []database.User
+	return db.Select("id", "created_at", "email", "name", "email_verified").FindInBatches(&users, 1000, func(tx *gorm.DB, batch int) error {
+		for _, user := range users {
+			if err := w.Write([]string{user.Id, user.CreatedAt.Format(time.RFC3339), user.Email, user.Name, strconv.FormatBool(user.EmailVerified)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func writeSignupsReport(db *gorm.DB, w *csv.Writer) error {
+	if err := w.Write([]string{"date", "signups"}); err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Date    string
+		Signups int64
+	}
+	if err := db.Model(&database.User{}).Select("DATE(created_at) AS date, COUNT(*) AS signups").Group("DATE(created_at)").Order("date").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := w.Write([]string{row.Date, strconv.FormatInt(row.Signups, 10)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeModerationReport(db *gorm.DB, w *csv.Writer) error {
+	if err := w.Write([]string{"created_at", "action", "chat_id", "user_id", "by", "expires_at", "reason"}); err != nil {
+		return err
+	}
+
+	var bans []database.ChatBan
+	return db.Order("created_at").FindInBatches(&bans, 1000, func(tx *gorm.DB, batch int) error {
+		for _, ban := range bans {
+			expiresAt, reason := "", ""
+			if ban.ExpiresAt != nil {
+				expiresAt = ban.ExpiresAt.Format(time.RFC3339)
+			}
+			if ban.Reason != nil {
+				reason = *ban.Reason
+			}
+			if err := w.Write([]string{ban.CreatedAt.Format(time.RFC3339), "chat_ban", ban.ChatId, ban.UserId, ban.BannedBy, expiresAt, reason}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func runReport(db *gorm.DB, cfg *common.Config, job *reportJob, logger *common.Logger) {
+	setReportStatus(job, ReportRunning)
+
+	// the csv is uploaded while it is written, large reports are never held in memory
+	reader, writer := io.Pipe()
+	go func() {
+		w := csv.NewWriter(writer)
+
+		var err error
+		switch job.Type {
+		case UsersReport:
+			err = writeUsersReport(db, w)
+		case SignupsReport:
+			err = writeSignupsReport(db, w)
+		case ModerationReport:
+			err = writeModerationReport(db, w)
+		}
+		if err == nil {
+			w.Flush()
+			err = w.Error()
+		}
+		if err != nil {
+			logger.PrintfError("Error generating %s report %s: %s", job.Type, job.Id, err)
+		}
+		writer.CloseWithError(err)
+	}()
+
+	e := s3.UploadStream(logger, cfg, cfg.ReportsBucketName, job.ObjectKey, reader, "text/csv")
+	// unblocks the writer if the upload stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	if e != nil {
+		setReportStatus(job, ReportFailed)
+		return
+	}
+
+	setReportStatus(job, ReportDone)
+	logger.Printf("Finished %s report %s", job.Type, job.Id)
+}
+
+// StartReport queues the generation of a report and returns immediately.
+func StartReport(db *gorm.DB, cfg *common.Config, reportType ReportType, logger *common.Logger) (*ReportJobResponse, *api.ApiError) {
+	switch reportType {
+	case UsersReport, SignupsReport, ModerationReport:
+	default:
+		return nil, &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: fmt.Sprintf("Unknown report type: %s", reportType),
+		}
+	}
+
+	if cfg.ReportsBucketName == "" {
+		return nil, &api.ApiError{
+			Code:    http.StatusForbidden,
+			Error:   enum.NotAllowed,
+			Details: "Reports are disabled",
+		}
+	}
+
+	job := &reportJob{
+		Id:        uuid.NewString(),
+		Type:      reportType,
+		Status:    ReportPending,
+		CreatedAt: time.Now(),
+	}
+	job.ObjectKey = fmt.Sprintf("reports/%s/%s.csv", reportType, job.Id)
+
+	reportJobsMutex.Lock()
+	reportJobs[job.Id] = job
+	reportJobsMutex.Unlock()
+
+	go runReport(db, cfg, job, logger.Detached())
+
+	logger.Printf("Started %s report %s", reportType, job.Id)
+
+	return &ReportJobResponse{
+		Id:        job.Id,
+		Type:      job.Type,
+		Status:    job.Status,
+		CreatedAt: job.CreatedAt,
+	}, nil
+}
+
+// GetReport returns the status of a report and a download link once it is done.
+func GetReport(cfg *common.Config, jobId string, logger *common.Logger) (*ReportJobResponse, *api.ApiError) {
+	reportJobsMutex.Lock()
+	job, ok := reportJobs[jobId]
+	var response ReportJobResponse
+	if ok {
+		response = ReportJobResponse{
+			Id:         job.Id,
+			Type:       job.Type,
+			Status:     job.Status,
+			CreatedAt:  job.CreatedAt,
+			FinishedAt: job.FinishedAt,
+		}
+	}
+	reportJobsMutex.Unlock()
+
+	if !ok {
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	if response.Status == ReportDone {
+		url, err := s3.GenerateDownloadURL(logger, cfg, cfg.ReportsBucketName, job.ObjectKey, reportDownloadExpiration)
+		if err != nil {
+			return nil, err
+		}
+		response.DownloadURL = url
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/geoip"
+
+	"gorm.io/gorm"
+)
+
+// Record writes an entry to the audit log. Failing to write the audit log never fails the calling request,
+// the error is only logged.
+func Record(db *gorm.DB, logger *common.Logger, userId string, action enum.AuditAction, client common.ClientInfo, details *string) {
+	if len(client.UserAgent) > 512 {
+		client.UserAgent = client.UserAgent[:512]
+	}
+
+	location := geoip.Lookup(client.IP)
+
+	entry := database.AuditLog{
+		UserId:    userId,
+		Action:    action,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Country:   location.Country,
+		City:      location.City,
+		Details:   details,
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		logger.PrintfError("Could not write audit log entry %s for user: %s. Error: %s", action, userId, err)
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetApiKeysController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	payload, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	keys, err := GetApiKeysService(db, payload.(*JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func CreateApiKeyController(c *gin.Context) {
+	request, logger, db, _, errors := common.SetupEndpoint[CreateApiKeyRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if request == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	payload, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	key, err := CreateApiKeyService(db, payload.(*JWTAccessTokenPayload), request, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func DeleteApiKeyController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	payload, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := DeleteApiKeyService(db, payload.(*JWTAccessTokenPayload), c.Param("id"), logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func GetApiKeyUsageController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	payload, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	var query ApiKeyUsageRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	usage, err := GetApiKeyUsageService(db, cfg, payload.(*JWTAccessTokenPayload), c.Param("id"), &query, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const apiKeyPrefix = "efk_"
+
+func hashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func toApiKeyResponse(key *database.ApiKey) ApiKeyResponse {
+	return ApiKeyResponse{
+		Id:         key.Id,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		ExpiresAt:  key.ExpiresAt,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+	}
+}
+
+// validateApiKey resolves an api key to the token payload of its user.
+func validateApiKey(db *gorm.DB, key string) (*database.ApiKey, *JWTAccessTokenPayload, error) {
+	var apiKey database.ApiKey
+	if err := db.Preload("User").Where("key_hash = ? AND (expires_at IS NULL OR expires_at > ?)", hashApiKey(key), time.Now()).First(&apiKey).Error; err != nil {
+		return nil, nil, err
+	}
+	if apiKey.User.Disabled || apiKey.User.DeletionScheduledAt != nil {
+		return nil, nil, errors.New("user is disabled or deleted")
+	}
+
+	db.Model(&apiKey).Update("last_used_at", time.Now())
+
+	return &apiKey, &JWTAccessTokenPayload{
+		UserId:   apiKey.UserId,
+		Role:     apiKey.User.Role,
+		ApiKeyId: apiKey.Id,
+	}, nil
+}
+
+func GetApiKeysService(db *gorm.DB, payload *JWTAccessTokenPayload, logger *common.Logger) ([]ApiKeyResponse, *api.ApiError) {
+	var keys []database.ApiKey
+	if err := db.Where("user_id = ?", payload.UserId).Order("created_at desc").Find(&keys).Error; err != nil {
+		logger.PrintfError("Could not get api keys: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	response := make([]ApiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, toApiKeyResponse(&key))
+	}
+
+	return response, nil
+}
+
+// CreateApiKeyService creates a new api key, the key itself is only returned here and stored hashed.
+func CreateApiKeyService(db *gorm.DB, payload *JWTAccessTokenPayload, request *CreateApiKeyRequest, logger *common.Logger) (*CreateApiKeyResponse, *api.ApiError) {
+	// payloads of api keys have no refresh session, keys can only be created from a login of a registered user
+	if payload.RefreshRand == nil || payload.Guest {
+		logger.PrintfWarning("Rejected api key creation with an api key")
+		return nil, &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.NotAllowed,
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		logger.PrintfError("Error generating api key: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	key := apiKeyPrefix + hex.EncodeToString(raw)
+
+	apiKey := database.ApiKey{
+		Name:    request.Name,
+		Prefix:  key[:12],
+		KeyHash: hashApiKey(key),
+		UserId:  payload.UserId,
+	}
+	if request.ExpiresIn != nil {
+		expiresAt := time.Now().Add(time.Duration(*request.ExpiresIn) * time.Second)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := db.Create(&apiKey).Error; err != nil {
+		logger.PrintfError("Error saving api key: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("Created api key: %s", apiKey.Id)
+
+	return &CreateApiKeyResponse{
+		ApiKeyResponse: toApiKeyResponse(&apiKey),
+		Key:            key,
+	}, nil
+}
+
+func DeleteApiKeyService(db *gorm.DB, payload *JWTAccessTokenPayload, keyId string, logger *common.Logger) *api.ApiError {
+	res := db.Where("id = ? AND user_id = ?", keyId, payload.UserId).Delete(&database.ApiKey{})
+	if res.Error != nil {
+		logger.PrintfError("Could not delete api key %s: %s", keyId, res.Error)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if res.RowsAffected == 0 {
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	if err := db.Where("api_key_id = ?", keyId).Delete(&database.ApiKeyUsage{}).Error; err != nil {
+		logger.PrintfWarning("Could not delete usage of api key %s: %s", keyId, err)
+	}
+	if err := db.Where("api_key_id = ?", keyId).Delete(&database.ApiKeyQuota{}).Error; err != nil {
+		logger.PrintfWarning("Could not delete quota of api key %s: %s", keyId, err)
+	}
+
+	logger.Printf("Deleted api key: %s", keyId)
+
+	return nil
+}
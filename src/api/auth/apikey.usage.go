@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type usageKey struct {
+	apiKeyId string
+	month    string
+	endpoint string
+}
+
+type usageCount struct {
+	requests int64
+	bytes    int64
+}
+
+// usage is counted in memory and written to the database by flushApiKeyUsage,
+// the quota is counted separately in the database, see consumeApiKeyQuota.
+var pendingUsage = make(map[usageKey]*usageCount)
+var usageMutex sync.Mutex
+
+func usageMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// consumeApiKeyQuota counts a request against the monthly quota of API_KEY_MONTHLY_QUOTA requests and returns an
+// error if the key used it up. The check and the increment are one update, so concurrent requests on any instance
+// cannot overshoot the quota.
+func consumeApiKeyQuota(db *gorm.DB, cfg *common.Config, apiKeyId string) *api.ApiError {
+	if cfg.ApiKeyMonthlyQuota <= 0 {
+		return nil
+	}
+
+	month := usageMonth(time.Now())
+
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&database.ApiKeyQuota{ApiKeyId: apiKeyId, Month: month}).Error; err != nil {
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	res := db.Model(&database.ApiKeyQuota{}).
+		Where("api_key_id = ? AND month = ? AND requests < ?", apiKeyId, month, cfg.ApiKeyMonthlyQuota).
+		Update("requests", gorm.Expr("requests + 1"))
+	if res.Error != nil {
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if res.RowsAffected == 0 {
+		return &api.ApiError{
+			Code:    http.StatusTooManyRequests,
+			Error:   enum.QuotaExceeded,
+			Details: "Monthly request quota of the api key is used up",
+		}
+	}
+
+	return nil
+}
+
+// recordApiKeyUsage counts a request made with an api key, bytes are the sizes of request and response body.
+func recordApiKeyUsage(apiKeyId string, endpoint string, bytes int64) {
+	key := usageKey{apiKeyId: apiKeyId, month: usageMonth(time.Now()), endpoint: endpoint}
+
+	usageMutex.Lock()
+	defer usageMutex.Unlock()
+
+	count, ok := pendingUsage[key]
+	if !ok {
+		count = &usageCount{}
+		pendingUsage[key] = count
+	}
+	count.requests++
+	count.bytes += bytes
+}
+
+// StartApiKeyUsageFlush periodically writes the api key usage counted by this instance to the database.
+func StartApiKeyUsageFlush(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.ApiKeyUsageFlushInterval) * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			flushApiKeyUsage(db, logger)
+		}
+	}()
+}
+
+// flushApiKeyUsage adds the pending counts to the database. Counts that fail to write are kept for the next flush.
+func flushApiKeyUsage(db *gorm.DB, logger *common.Logger) {
+	usageMutex.Lock()
+	pending := pendingUsage
+	pendingUsage = make(map[usageKey]*usageCount)
+	usageMutex.Unlock()
+
+	for key, count := range pending {
+		row := database.ApiKeyUsage{
+			ApiKeyId: key.apiKeyId,
+			Month:    key.month,
+			Endpoint: key.endpoint,
+			Requests: count.requests,
+			Bytes:    count.bytes,
+		}
+
+		err := db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "api_key_id"}, {Name: "month"}, {Name: "endpoint"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"requests": gorm.Expr("requests + ?", count.requests),
+				"bytes":    gorm.Expr("bytes + ?", count.bytes),
+			}),
+		}).Create(&row).Error
+		if err == nil {
+			continue
+		}
+
+		logger.PrintfError("Could not write usage of api key %s: %s", key.apiKeyId, err)
+
+		usageMutex.Lock()
+		current, ok := pendingUsage[key]
+		if !ok {
+			current = &usageCount{}
+			pendingUsage[key] = current
+		}
+		current.requests += count.requests
+		current.bytes += count.bytes
+		usageMutex.Unlock()
+	}
+}
+
+// GetApiKeyUsageService returns the usage of an api key of the user in the given month, including counts not flushed yet.
+func GetApiKeyUsageService(db *gorm.DB, cfg *common.Config, payload *JWTAccessTokenPayload, keyId string, query *ApiKeyUsageRequest, logger *common.Logger) (*ApiKeyUsageResponse, *api.ApiError) {
+	var count int64
+	if err := db.Model(&database.ApiKey{}).Where("id = ? AND user_id = ?", keyId, payload.UserId).Count(&count).Error; err != nil {
+		logger.PrintfError("Could not get api key %s: %s", keyId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if count == 0 {
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	month := query.Month
+	if month == "" {
+		month = usageMonth(time.Now())
+	}
+
+	var rows []database.ApiKeyUsage
+	if err := db.Where("api_key_id = ? AND month = ?", keyId, month).Find(&rows).Error; err != nil {
+		logger.PrintfError("Could not get usage of api key %s: %s", keyId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	endpoints := make(map[string]*EndpointUsage)
+	for _, row := range rows {
+		endpoints[row.Endpoint] = &EndpointUsage{Endpoint: row.Endpoint, Requests: row.Requests, Bytes: row.Bytes}
+	}
+
+	usageMutex.Lock()
+	for key, pending := range pendingUsage {
+		if key.apiKeyId != keyId || key.month != month {
+			continue
+		}
+		entry, ok := endpoints[key.endpoint]
+		if !ok {
+			entry = &EndpointUsage{Endpoint: key.endpoint}
+			endpoints[key.endpoint] = entry
+		}
+		entry.Requests += pending.requests
+		entry.Bytes += pending.bytes
+	}
+	usageMutex.Unlock()
+
+	response := ApiKeyUsageResponse{
+		Month:     month,
+		Quota:     cfg.ApiKeyMonthlyQuota,
+		Endpoints: make([]EndpointUsage, 0, len(endpoints)),
+	}
+	for _, entry := range endpoints {
+		response.Requests += entry.Requests
+		response.Bytes += entry.Bytes
+		response.Endpoints = append(response.Endpoints, *entry)
+	}
+	sort.Slice(response.Endpoints, func(i, j int) bool {
+		return response.Endpoints[i].Requests > response.Endpoints[j].Requests
+	})
+
+	return &response, nil
+}
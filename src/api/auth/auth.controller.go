@@ -3,6 +3,7 @@ package auth
 import (
 	"easyflow-backend/src/api"
 	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
 	"easyflow-backend/src/enum"
 	"easyflow-backend/src/middleware"
 	"net/http"
@@ -17,6 +18,49 @@ func RegisterAuthEndpoints(r *gin.RouterGroup) {
 	r.GET("/check", AuthGuard(), CheckLoginController)
 	r.GET("/refresh", RefreshAuthGuard(), RefreshController)
 	r.GET("/logout", AuthGuard(), LogoutController)
+	r.POST("/logout-all", AuthGuard(), SessionGuard(), LogoutAllController)
+	r.GET("/sessions", AuthGuard(), SessionGuard(), GetSessionsController)
+	r.DELETE("/sessions/:id", AuthGuard(), SessionGuard(), RevokeSessionController)
+	r.GET("/api-keys", AuthGuard(), SessionGuard(), GetApiKeysController)
+	r.POST("/api-keys", AuthGuard(), SessionGuard(), CreateApiKeyController)
+	r.DELETE("/api-keys/:id", AuthGuard(), SessionGuard(), DeleteApiKeyController)
+	r.GET("/api-keys/:id/usage", AuthGuard(), SessionGuard(), GetApiKeyUsageController)
+	r.POST("/webauthn/register/begin", AuthGuard(), SessionGuard(), BeginWebAuthnRegistrationController)
+	r.POST("/webauthn/register/finish", AuthGuard(), SessionGuard(), FinishWebAuthnRegistrationController)
+	r.POST("/webauthn/login/begin", BeginWebAuthnLoginController)
+	r.POST("/webauthn/login/finish", FinishWebAuthnLoginController)
+	r.GET("/oauth/:provider", StartOAuthController)
+	r.GET("/oauth/:provider/callback", OAuthCallbackController)
+	r.GET("/proxy", ProxyAuthGuard(), ProxyLoginController)
+	r.GET("/saml/metadata", SamlMetadataController)
+	r.GET("/saml/login", StartSamlController)
+	r.POST("/saml/acs", SamlAcsController)
+}
+
+// SetAuthCookies stores the token pair of a login in http only cookies.
+func SetAuthCookies(c *gin.Context, cfg *common.Config, tokens JWTPair) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("access_token", tokens.AccessToken, cfg.JwtExpirationTime, "/", cfg.Domain, cfg.Stage == "production", true)
+	c.SetCookie("refresh_token", tokens.RefreshToken, tokens.RefreshExpiresIn, "/", cfg.Domain, cfg.Stage == "production", true)
+}
+
+// WriteTokens answers a login or refresh. Mobile clients that cannot use http only cookies set client=mobile
+// in the query and get the tokens in the body, they send them as bearer token afterwards.
+func WriteTokens(c *gin.Context, cfg *common.Config, tokens JWTPair) {
+	if c.Query("client") == "mobile" {
+		c.JSON(http.StatusOK, TokenResponse{
+			JWTPair:               tokens,
+			AccessTokenExpiresIn:  cfg.JwtExpirationTime,
+			RefreshTokenExpiresIn: tokens.RefreshExpiresIn,
+		})
+		return
+	}
+
+	SetAuthCookies(c, cfg, tokens)
+
+	c.JSON(http.StatusOK, gin.H{
+		"accessTokenExpiresIn": cfg.JwtExpirationTime,
+	})
 }
 
 func LoginController(c *gin.Context) {
@@ -30,19 +74,13 @@ func LoginController(c *gin.Context) {
 		return
 	}
 
-	tokens, err := LoginService(db, cfg, payload, logger)
+	tokens, err := LoginService(db, cfg, payload, common.GetClientInfo(c), logger)
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("access_token", tokens.AccessToken, cfg.JwtExpirationTime, "/", cfg.Domain, cfg.Stage == "production", true)
-	c.SetCookie("refresh_token", tokens.RefreshToken, cfg.RefreshExpirationTime, "/", cfg.Domain, cfg.Stage == "production", true)
-
-	c.JSON(200, gin.H{
-		"accessTokenExpiresIn": cfg.JwtExpirationTime,
-	})
+	WriteTokens(c, cfg, tokens)
 }
 
 func CheckLoginController(c *gin.Context) {
@@ -80,20 +118,23 @@ func RefreshController(c *gin.Context) {
 		return
 	}
 
-	tokens, err := RefreshService(db, cfg, payload.(*JWTAccessTokenPayload), logger)
+	session, ok := c.Get("session")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	tokens, err := RefreshService(db, cfg, payload.(*JWTAccessTokenPayload), session.(*database.UserKeys), common.GetClientInfo(c), logger)
 
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("access_token", tokens.AccessToken, cfg.JwtExpirationTime, "/", cfg.Domain, cfg.Stage == "production", true)
-	c.SetCookie("refresh_token", tokens.RefreshToken, cfg.RefreshExpirationTime, "/", cfg.Domain, cfg.Stage == "production", true)
-
-	c.JSON(200, gin.H{
-		"accessTokenExpiresIn": cfg.JwtExpirationTime,
-	})
+	WriteTokens(c, cfg, tokens)
 }
 
 func LogoutController(c *gin.Context) {
@@ -107,28 +148,73 @@ func LogoutController(c *gin.Context) {
 		return
 	}
 
-	refresh, err := c.Cookie("refresh_token")
-	if err != nil {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	// bearer clients have no refresh cookie, their access token belongs to the same session
+	payload := user.(*JWTAccessTokenPayload)
+	if refresh, err := c.Cookie("refresh_token"); err == nil {
+		payload, err = ValidateToken(cfg, refresh, TokenTypeRefresh)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:    http.StatusInternalServerError,
+				Error:   enum.ApiError,
+				Details: err,
+			})
+			return
+		}
+	}
+
+	// api keys and impersonation tokens have no session
+	if payload.RefreshRand == nil {
 		c.JSON(http.StatusBadRequest, api.ApiError{
-			Code:    http.StatusBadRequest,
-			Error:   enum.InvalidRefreshToken,
-			Details: err,
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidRefreshToken,
 		})
+		return
 	}
 
-	payload, err := ValidateToken(cfg, refresh)
-	if err != nil {
+	e := LogoutService(db, payload, logger)
+	if e != nil {
+		c.JSON(e.Code, e)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("access_token", "", -1, "/", cfg.Domain, cfg.Stage == "production", true)
+	c.SetCookie("refresh_token", "", -1, "/", cfg.Domain, cfg.Stage == "production", true)
+
+	c.JSON(200, gin.H{})
+}
+
+func LogoutAllController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
 		c.JSON(http.StatusInternalServerError, api.ApiError{
 			Code:    http.StatusInternalServerError,
 			Error:   enum.ApiError,
-			Details: err,
+			Details: errors,
 		})
 		return
 	}
 
-	e := LogoutService(db, payload, logger)
-	if e != nil {
-		c.JSON(e.Code, e)
+	payload, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := LogoutAllService(db, payload.(*JWTAccessTokenPayload), common.GetClientInfo(c), logger); err != nil {
+		c.JSON(err.Code, err)
 		return
 	}
 
@@ -138,3 +224,33 @@ func LogoutController(c *gin.Context) {
 
 	c.JSON(200, gin.H{})
 }
+
+func ProxyLoginController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	identity, ok := c.Get("proxyIdentity")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	tokens, err := ProxyLoginService(db, cfg, identity.(*proxyIdentity), common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	SetAuthCookies(c, cfg, tokens)
+	c.Redirect(http.StatusFound, cfg.GetFrontendURL())
+}
@@ -1,11 +1,79 @@
 package auth
 
+import "time"
+
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	// issues a refresh token with REMEMBER_ME_EXPIRATION_TIME instead of REFRESH_EXPIRATION_TIME
+	RememberMe bool `json:"rememberMe"`
+	// answer to a CAPTCHA_REQUIRED response
+	CaptchaToken string `json:"captchaToken,omitempty" validate:"lte=4096"`
 }
 
-type RefreshTokenResponse struct {
+// TokenResponse is the body of logins and refreshes of mobile clients, see WriteTokens
+type TokenResponse struct {
 	JWTPair
-	AccessTokenExpires int `json:"accessTokenExpires"`
+	AccessTokenExpiresIn  int `json:"accessTokenExpiresIn"`
+	RefreshTokenExpiresIn int `json:"refreshTokenExpiresIn"`
+}
+
+type WebAuthnLoginRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type SessionResponse struct {
+	Id         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiredAt  time.Time `json:"expiresAt"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	Current    bool      `json:"current"`
+}
+
+type CreateApiKeyRequest struct {
+	Name string `json:"name" validate:"required,lte=100"`
+	// lifetime of the key in seconds, omitted means the key does not expire
+	ExpiresIn *int `json:"expiresIn" validate:"omitempty,gt=0"`
+}
+
+type ApiKeyResponse struct {
+	Id         string     `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+}
+
+type ApiKeyUsageRequest struct {
+	// YYYY-MM in UTC, defaults to the current month
+	Month string `form:"month" validate:"omitempty,datetime=2006-01"`
+}
+
+type EndpointUsage struct {
+	Endpoint string `json:"endpoint"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type ApiKeyUsageResponse struct {
+	Month    string `json:"month"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+	// monthly request quota, 0 means unlimited
+	Quota     int64           `json:"quota"`
+	Endpoints []EndpointUsage `json:"endpoints"`
+}
+
+type CreateApiKeyResponse struct {
+	ApiKeyResponse
+	// only returned once
+	Key string `json:"key"`
+}
+
+// SamlResponseRequest is the form the idp posts to the assertion consumer service
+type SamlResponseRequest struct {
+	SAMLResponse string `form:"SAMLResponse" validate:"required"`
 }
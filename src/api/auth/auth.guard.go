@@ -7,14 +7,19 @@ import (
 	"easyflow-backend/src/enum"
 	"errors"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// AuthGuard authenticates the request with the access token cookie, an Authorization bearer token (mobile clients)
+// or, for programmatic access, an X-Api-Key header.
+// Impersonation tokens are only accepted as bearer token and every request made with them is logged.
 func AuthGuard() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_, logger, _, cfg, errs := common.SetupEndpoint[any](c)
+		_, logger, db, cfg, errs := common.SetupEndpoint[any](c)
 		if errs != nil {
 			c.JSON(http.StatusInternalServerError, api.ApiError{
 				Code:    http.StatusInternalServerError,
@@ -25,8 +30,39 @@ func AuthGuard() gin.HandlerFunc {
 			return
 		}
 
+		if apiKey := c.GetHeader("X-Api-Key"); apiKey != "" {
+			key, payload, err := validateApiKey(db, apiKey)
+			if err != nil {
+				logger.PrintfDebug("Invalid api key: %s", err.Error())
+				c.JSON(http.StatusUnauthorized, api.ApiError{
+					Code:  http.StatusUnauthorized,
+					Error: enum.Unauthorized,
+				})
+				c.Abort()
+				return
+			}
+
+			if err := consumeApiKeyQuota(db, cfg, key.Id); err != nil {
+				logger.PrintfWarning("Rejected request of api key %s: %s", key.Id, err.Error)
+				c.JSON(err.Code, err)
+				c.Abort()
+				return
+			}
+
+			c.Set("user", payload)
+			c.Set("logger", logger.With(common.Field{Key: "user", Value: payload.UserId}))
+			c.Next()
+
+			recordApiKeyUsage(key.Id, c.FullPath(), max(c.Request.ContentLength, 0)+int64(max(c.Writer.Size(), 0)))
+			return
+		}
+
 		// Get access_token from cookies
 		accessToken, err := c.Cookie("access_token")
+		bearerToken, bearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if bearer {
+			accessToken, err = bearerToken, nil
+		}
 		if err != nil {
 			logger.PrintfDebug("Error while getting access token cookie: %s", err.Error())
 			c.JSON(http.StatusBadRequest, api.ApiError{
@@ -48,7 +84,7 @@ func AuthGuard() gin.HandlerFunc {
 		}
 
 		// Validate token
-		payload, err := ValidateToken(cfg, accessToken)
+		payload, err := ValidateToken(cfg, accessToken, TokenTypeAccess)
 		if err != nil {
 			logger.PrintfDebug("Error validating token: %s", err.Error())
 			if errors.Is(err, jwt.ErrTokenExpired) {
@@ -67,12 +103,66 @@ func AuthGuard() gin.HandlerFunc {
 			return
 		}
 
+		// impersonation tokens are sent as bearer token, so they never replace the cookies of the operator
+		impersonation := payload.ImpersonatedBy != ""
+		if impersonation && !bearer {
+			logger.PrintfWarning("Rejected access token of user: %s, impersonation tokens are only valid as bearer token", payload.UserId)
+			c.JSON(498, api.ApiError{
+				Code:  498, // token expired/invalid
+				Error: enum.InvalidAccessToken,
+			})
+			c.Abort()
+			return
+		}
+
+		if payload.Guest && !getGuestLimiter(cfg, payload.UserId).Allow() {
+			logger.PrintfDebug("Rejected request of guest: %s, rate limit exceeded", payload.UserId)
+			c.JSON(http.StatusTooManyRequests, api.ApiError{
+				Code:  http.StatusTooManyRequests,
+				Error: enum.TooManyAttempts,
+			})
+			c.Abort()
+			return
+		}
+
+		revoked, err := isTokenRevoked(db, payload.ID)
+		if err != nil {
+			logger.PrintfError("Error checking token denylist: %s", err)
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			logger.PrintfDebug("Rejected revoked access token of user: %s", payload.UserId)
+			c.JSON(498, api.ApiError{
+				Code:  498, // token expired/invalid
+				Error: enum.InvalidAccessToken,
+			})
+			c.Abort()
+			return
+		}
+
 		// Set user payload in context
 		c.Set("user", payload)
+		if impersonation {
+			logger = logger.With(common.Field{Key: "impersonatedBy", Value: payload.ImpersonatedBy})
+		}
+		c.Set("logger", logger.With(common.Field{Key: "user", Value: payload.UserId}))
 		c.Next()
+
+		// requests of support operators do not make the user appear online
+		if impersonation {
+			recordImpersonatedRequest(db, logger, payload, c)
+		} else {
+			touchLastSeen(db, payload.UserId, logger)
+		}
 	}
 }
 
+// RefreshAuthGuard authenticates the refresh token of the refresh_token cookie or, for mobile clients, an Authorization bearer token.
 func RefreshAuthGuard() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_, logger, db, cfg, errs := common.SetupEndpoint[any](c)
@@ -87,6 +177,9 @@ func RefreshAuthGuard() gin.HandlerFunc {
 		}
 
 		refreshToken, err := c.Cookie("refresh_token")
+		if bearerToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			refreshToken, err = bearerToken, nil
+		}
 		if err != nil {
 			logger.PrintfDebug("Error while getting refresh token cookie: %s", err.Error())
 			c.JSON(http.StatusBadRequest, api.ApiError{
@@ -107,7 +200,7 @@ func RefreshAuthGuard() gin.HandlerFunc {
 			return
 		}
 
-		token, err := ValidateToken(cfg, refreshToken)
+		token, err := ValidateToken(cfg, refreshToken, TokenTypeRefresh)
 		if err != nil {
 			logger.PrintfError("Error validating token: %s", err.Error())
 			if errors.Is(err, jwt.ErrTokenExpired) {
@@ -126,7 +219,9 @@ func RefreshAuthGuard() gin.HandlerFunc {
 			return
 		}
 
-		if err := db.First(&database.UserKeys{}, "user_id = ? AND random = ?", token.UserId, token.RefreshRand).Error; err != nil {
+		// the session is passed on, so the refresh does not load it again
+		var session database.UserKeys
+		if err := db.First(&session, "user_id = ? AND random = ?", token.UserId, token.RefreshRand).Error; err != nil {
 			logger.PrintfDebug("refresh token not found in db")
 			c.JSON(498, api.ApiError{
 				Code:  498,
@@ -138,6 +233,150 @@ func RefreshAuthGuard() gin.HandlerFunc {
 		}
 
 		c.Set("user", token)
+		c.Set("session", &session)
+		c.Set("logger", logger.With(common.Field{Key: "user", Value: token.UserId}))
+		c.Next()
+	}
+}
+
+// SessionGuard only lets requests authenticated with a login of the user through. It protects credential, session,
+// api key and account routes, so neither a leaked api key nor a support operator with an impersonation token
+// can register a passkey, keep access after the token expired or delete the account.
+// It has to run after the AuthGuard.
+func SessionGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, logger, _, _, errs := common.SetupEndpoint[any](c)
+		if errs != nil {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:    http.StatusInternalServerError,
+				Error:   enum.ApiError,
+				Details: errs,
+			})
+			c.Abort()
+			return
+		}
+
+		payload, ok := c.Get("user")
+		if !ok {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			})
+			c.Abort()
+			return
+		}
+
+		token := payload.(*JWTAccessTokenPayload)
+		if token.ApiKeyId != "" {
+			logger.PrintfWarning("Rejected api key %s on %s, the route requires a login", token.ApiKeyId, c.FullPath())
+			c.JSON(http.StatusForbidden, api.ApiError{
+				Code:  http.StatusForbidden,
+				Error: enum.NotAllowed,
+			})
+			c.Abort()
+			return
+		}
+		if token.ImpersonatedBy != "" {
+			logger.PrintfWarning("Rejected impersonation token of %q on %s, the route requires a login", token.ImpersonatedBy, c.FullPath())
+			c.JSON(http.StatusForbidden, api.ApiError{
+				Code:  http.StatusForbidden,
+				Error: enum.NotAllowed,
+			})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
+
+// VerifiedGuard rejects users that have not verified their email address yet.
+// It has to run after the AuthGuard.
+func VerifiedGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, logger, db, _, errs := common.SetupEndpoint[any](c)
+		if errs != nil {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:    http.StatusInternalServerError,
+				Error:   enum.ApiError,
+				Details: errs,
+			})
+			c.Abort()
+			return
+		}
+
+		payload, ok := c.Get("user")
+		if !ok {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			})
+			c.Abort()
+			return
+		}
+
+		var user database.User
+		if err := db.Select("id", "email_verified").Where("id = ?", payload.(*JWTAccessTokenPayload).UserId).First(&user).Error; err != nil {
+			logger.PrintfWarning("Could not get user: %s", err)
+			c.JSON(http.StatusUnauthorized, api.ApiError{
+				Code:  http.StatusUnauthorized,
+				Error: enum.Unauthorized,
+			})
+			c.Abort()
+			return
+		}
+
+		if !user.EmailVerified {
+			logger.PrintfDebug("Rejected unverified user")
+			c.JSON(http.StatusForbidden, api.ApiError{
+				Code:  http.StatusForbidden,
+				Error: enum.EmailNotVerified,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole only lets users with one of the given roles through.
+// It has to run after the AuthGuard. The role is taken from the access token,
+// so role changes apply once the token is refreshed.
+func RequireRole(roles ...enum.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, logger, _, _, errs := common.SetupEndpoint[any](c)
+		if errs != nil {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:    http.StatusInternalServerError,
+				Error:   enum.ApiError,
+				Details: errs,
+			})
+			c.Abort()
+			return
+		}
+
+		payload, ok := c.Get("user")
+		if !ok {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			})
+			c.Abort()
+			return
+		}
+
+		role := payload.(*JWTAccessTokenPayload).Role
+		if slices.Contains(roles, role) {
+			c.Next()
+			return
+		}
+
+		logger.PrintfWarning("Rejected user with role %q, requires one of %v", role, roles)
+		c.JSON(http.StatusForbidden, api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.NotAllowed,
+		})
+		c.Abort()
+	}
+}
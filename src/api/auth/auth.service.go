@@ -2,14 +2,17 @@ package auth
 
 import (
 	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
 	"easyflow-backend/src/api/utils"
 	"easyflow-backend/src/common"
 	"easyflow-backend/src/database"
 	"easyflow-backend/src/enum"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -19,8 +22,14 @@ import (
 )
 
 func generateJwt[T interface{ jwt.Claims }](cfg *common.Config, payload T) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	signedToken, err := token.SignedString([]byte(cfg.JwtSecret))
+	kid, key, err := signingKey(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, payload)
+	token.Header["kid"] = kid
+	signedToken, err := token.SignedString(key.sign)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -28,70 +37,182 @@ func generateJwt[T interface{ jwt.Claims }](cfg *common.Config, payload T) (stri
 	return signedToken, nil
 }
 
-func ValidateToken(cfg *common.Config, token string) (*JWTAccessTokenPayload, error) {
+// ValidateToken verifies the token and that it is of the expected type.
+func ValidateToken(cfg *common.Config, token string, expected TokenType) (*JWTAccessTokenPayload, error) {
 	var claims JWTAccessTokenPayload
-	_, err := jwt.ParseWithClaims(
-		token,
-		&claims,
-		func(token *jwt.Token) (interface{}, error) {
-			// Verify that the signing method is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(cfg.JwtSecret), nil
-		},
+	_, err := jwt.ParseWithClaims(token, &claims, keyFunc(cfg),
+		jwt.WithIssuer(cfg.JwtIssuer),
+		jwt.WithAudience(cfg.JwtAudience),
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// tokens issued before the typ claim are told apart by the jti and their lifetime, access tokens issued before
+	// they had a jti carry the same session random as refresh tokens but live only JWT_EXPIRATION_TIME
+	typ := claims.Type
+	if typ == "" {
+		typ = TokenTypeAccess
+		if claims.ID == "" && claims.IssuedAt != nil && claims.ExpiresAt != nil &&
+			claims.ExpiresAt.Sub(claims.IssuedAt.Time) > time.Duration(cfg.JwtExpirationTime)*time.Second {
+			typ = TokenTypeRefresh
+		}
+		if typ == TokenTypeRefresh && time.Now().Unix() > int64(cfg.JwtUntypedTokensUntil) {
+			return nil, errors.New("untyped refresh tokens are no longer accepted")
+		}
+	}
+	if typ != expected {
+		return nil, fmt.Errorf("expected %s token, got %s token", expected, typ)
+	}
+
 	return &claims, nil
 }
 
-func LoginService(db *gorm.DB, cfg *common.Config, payload *LoginRequest, logger *common.Logger) (JWTPair, *api.ApiError) {
+var dummyPasswordHash []byte
+var dummyPasswordHashOnce sync.Once
+
+// getDummyPasswordHash is compared against for unknown emails, so they take as long as a wrong password.
+func getDummyPasswordHash(cfg *common.Config) []byte {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte(uuid.NewString()), cfg.SaltRounds)
+	})
+	return dummyPasswordHash
+}
+
+// wrongCredentials leaves out the reason with EMAIL_ENUMERATION_PROTECTION, it tells unknown emails and wrong passwords apart.
+func wrongCredentials(cfg *common.Config, err error) *api.ApiError {
+	apiErr := &api.ApiError{
+		Code:  http.StatusUnauthorized,
+		Error: enum.WrongCredentials,
+	}
+	if !cfg.EmailEnumerationProtection {
+		apiErr.Details = err
+	}
+	return apiErr
+}
+
+func LoginService(db *gorm.DB, cfg *common.Config, payload *LoginRequest, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
+	if cfg.EmailEnumerationProtection {
+		defer common.PadDuration(time.Now(), time.Duration(cfg.EnumerationMinResponseTime)*time.Millisecond)
+	}
+
+	if err := checkEmailRate(cfg, payload.Email); err != nil {
+		logger.PrintfWarning("Rejected login for email: %s, too many attempts", payload.Email)
+		return JWTPair{}, err
+	}
+
 	var user database.User
 	if err := db.Where("email = ?", payload.Email).First(&user).Error; err != nil {
 		logger.PrintfWarning("User with email: %s not found", payload.Email)
-		return JWTPair{}, &api.ApiError{
-			Code:    http.StatusUnauthorized,
-			Error:   enum.WrongCredentials,
-			Details: err,
+		if cfg.EmailEnumerationProtection {
+			bcrypt.CompareHashAndPassword(getDummyPasswordHash(cfg), []byte(payload.Password))
+		}
+		return JWTPair{}, wrongCredentials(cfg, err)
+	}
+
+	if err := checkLockout(&user); err != nil {
+		logger.PrintfWarning("Rejected login for locked user: %s", user.Id)
+		// only registered emails can be locked
+		if cfg.EmailEnumerationProtection {
+			return JWTPair{}, wrongCredentials(cfg, nil)
 		}
+		return JWTPair{}, err
 	}
 
 	//check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password)); err != nil {
 		logger.PrintfWarning("Wrong password for user with email: %s", payload.Email)
+		audit.Record(db, logger, user.Id, enum.LoginFailed, client, nil)
+		recordFailedLogin(db, cfg, &user, logger)
+		return JWTPair{}, wrongCredentials(cfg, err)
+	}
+
+	// after the password check, so the decision does not tell whether an account exists for the email
+	if err := checkLoginRisk(db, cfg, &user, client, payload.CaptchaToken, logger); err != nil {
+		return JWTPair{}, err
+	}
+
+	resetFailedLogins(db, &user, logger)
+
+	lifetime := cfg.RefreshExpirationTime
+	if payload.RememberMe {
+		lifetime = cfg.RememberMeExpirationTime
+	}
+
+	return completeLogin(db, cfg, &user, client, lifetime, logger)
+}
+
+// sessionLifetime returns the refresh token lifetime of a session. Sessions keep the lifetime chosen at login,
+// bounded by the current configuration so lowering it also shortens existing sessions.
+func sessionLifetime(cfg *common.Config, lifetime int) int {
+	if lifetime <= 0 {
+		return cfg.RefreshExpirationTime
+	}
+	return min(lifetime, max(cfg.RefreshExpirationTime, cfg.RememberMeExpirationTime))
+}
+
+// LoginUser logs in a user that was authenticated outside of this package, e.g. a newly created guest.
+func LoginUser(db *gorm.DB, cfg *common.Config, user *database.User, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
+	return completeLogin(db, cfg, user, client, cfg.RefreshExpirationTime, logger)
+}
+
+// completeLogin issues a new token pair for an authenticated user and stores the refresh session.
+// It is shared by all login methods, lifetime is the refresh token lifetime in seconds.
+func completeLogin(db *gorm.DB, cfg *common.Config, user *database.User, client common.ClientInfo, lifetime int, logger *common.Logger) (JWTPair, *api.ApiError) {
+	if user.Disabled {
+		logger.PrintfWarning("Rejected login of disabled user: %s", user.Id)
 		return JWTPair{}, &api.ApiError{
-			Code:    http.StatusUnauthorized,
-			Error:   enum.WrongCredentials,
-			Details: err,
+			Code:  http.StatusForbidden,
+			Error: enum.AccountDisabled,
+		}
+	}
+
+	if user.DeletionScheduledAt != nil {
+		if err := db.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+			logger.PrintfError("Could not restore deleted user: %s. Error: %s", user.Id, err)
+			return JWTPair{}, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
 		}
+		audit.Record(db, logger, user.Id, enum.AccountRestored, client, nil)
+		logger.Printf("Restored deleted user: %s", user.Id)
 	}
 
+	lifetime = sessionLifetime(cfg, lifetime)
 	random := uuid.New()
 	expires := time.Now().Add(time.Duration(cfg.JwtExpirationTime) * time.Second)
-	refreshExpires := time.Now().Add(time.Duration(cfg.RefreshExpirationTime) * time.Second)
+	refreshExpires := time.Now().Add(time.Duration(lifetime) * time.Second)
 
+	accessTokenId := uuid.NewString()
 	accessTokenPayload := JWTAccessTokenPayload{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        accessTokenId,
 			ExpiresAt: jwt.NewNumericDate(expires),
-			Issuer:    "easyflow",
+			Issuer:    cfg.JwtIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JwtAudience},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		UserId:      user.Id,
+		Role:        user.Role,
 		RefreshRand: &random,
+		Guest:       user.Guest,
+		Type:        TokenTypeAccess,
 	}
 
 	refreshTokenPayload := JWTAccessTokenPayload{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(refreshExpires),
-			Issuer:    "easyflow",
+			Issuer:    cfg.JwtIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JwtAudience},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		UserId:      user.Id,
+		Role:        user.Role,
 		RefreshRand: &random,
+		Guest:       user.Guest,
+		Type:        TokenTypeRefresh,
 	}
 
 	accessToken, err := generateJwt[JWTAccessTokenPayload](cfg, accessTokenPayload)
@@ -118,9 +239,16 @@ func LoginService(db *gorm.DB, cfg *common.Config, payload *LoginRequest, logger
 
 	//write refresh token to db
 	entry := database.UserKeys{
-		Random:    random.String(),
-		ExpiredAt: refreshExpires,
-		UserId:    user.Id,
+		Random:          random.String(),
+		ExpiredAt:       refreshExpires,
+		LastUsedAt:      time.Now(),
+		Lifetime:        lifetime,
+		AccessTokenId:   accessTokenId,
+		AccessExpiresAt: expires,
+		IP:              client.IP,
+		UserAgent:       client.UserAgent,
+		DeviceHash:      sessionFingerprint(client),
+		UserId:          user.Id,
 	}
 
 	if err := db.Save(&entry).Error; err != nil {
@@ -133,7 +261,7 @@ func LoginService(db *gorm.DB, cfg *common.Config, payload *LoginRequest, logger
 	}
 
 	if user.ProfilePicture == nil {
-		utils.GenerateNewProfilePictureUrl(logger, cfg, db, &user)
+		utils.GenerateNewProfilePictureUrl(logger, cfg, db, user)
 	} else {
 		expired := false
 
@@ -157,20 +285,25 @@ func LoginService(db *gorm.DB, cfg *common.Config, payload *LoginRequest, logger
 		}
 
 		if expired {
-			utils.GenerateNewProfilePictureUrl(logger, cfg, db, &user)
+			utils.GenerateNewProfilePictureUrl(logger, cfg, db, user)
 		}
 
 	}
 
+	audit.Record(db, logger, user.Id, enum.LoginSucceeded, client, nil)
+	checkNewDevice(db, cfg, user, client, logger)
+
 	logger.Printf("Logged in user: %s", user.Id)
 
 	return JWTPair{
-		RefreshToken: refreshToken,
-		AccessToken:  accessToken,
+		RefreshToken:     refreshToken,
+		AccessToken:      accessToken,
+		RefreshExpiresIn: lifetime,
 	}, nil
 }
 
-func RefreshService(db *gorm.DB, cfg *common.Config, payload *JWTAccessTokenPayload, logger *common.Logger) (JWTPair, *api.ApiError) {
+// RefreshService rotates the refresh token of session, the session loaded by the RefreshAuthGuard.
+func RefreshService(db *gorm.DB, cfg *common.Config, payload *JWTAccessTokenPayload, session *database.UserKeys, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
 	//get user from db
 	var user database.User
 	if err := db.First(&user, "id = ?", payload.UserId).Error; err != nil {
@@ -182,28 +315,63 @@ func RefreshService(db *gorm.DB, cfg *common.Config, payload *JWTAccessTokenPayl
 		}
 	}
 
+	if user.Disabled {
+		logger.PrintfWarning("Rejected refresh of disabled user: %s", user.Id)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.AccountDisabled,
+		}
+	}
+
+	// keep the lifetime chosen at login
+	lifetime := sessionLifetime(cfg, session.Lifetime)
+
+	// sessions created before device binding adopt the device of their next refresh
+	fingerprint := sessionFingerprint(client)
+	if cfg.RefreshDeviceBinding && session.DeviceHash != "" && session.DeviceHash != fingerprint {
+		logger.PrintfWarning("Rejected refresh of session: %s of user: %s from another device", session.Id, payload.UserId)
+		// the refresh token was probably copied, so it must not work on the original device either
+		if _, err := EndSessions(db, db.Where("id = ?", session.Id)); err != nil {
+			logger.PrintfError("Could not end session: %s. Error: %s", session.Id, err)
+		}
+		return JWTPair{}, &api.ApiError{
+			Code:  498, // token expired/invalid
+			Error: enum.DeviceMismatch,
+		}
+	}
+
 	random := uuid.New()
 	expires := time.Now().Add(time.Duration(cfg.JwtExpirationTime) * time.Second)
-	refreshExpires := time.Now().Add(time.Duration(cfg.RefreshExpirationTime) * time.Second)
+	refreshExpires := time.Now().Add(time.Duration(lifetime) * time.Second)
 
+	accessTokenId := uuid.NewString()
 	accessTokenPayload := JWTAccessTokenPayload{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        accessTokenId,
 			ExpiresAt: jwt.NewNumericDate(expires),
-			Issuer:    "easyflow",
+			Issuer:    cfg.JwtIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JwtAudience},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		UserId:      user.Id,
+		Role:        user.Role,
 		RefreshRand: &random,
+		Guest:       user.Guest,
+		Type:        TokenTypeAccess,
 	}
 
 	refreshTokenPayload := JWTAccessTokenPayload{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(refreshExpires),
-			Issuer:    "easyflow",
+			Issuer:    cfg.JwtIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JwtAudience},
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 		UserId:      user.Id,
+		Role:        user.Role,
 		RefreshRand: &random,
+		Guest:       user.Guest,
+		Type:        TokenTypeRefresh,
 	}
 
 	accessToken, err := generateJwt(cfg, &accessTokenPayload)
@@ -226,33 +394,55 @@ func RefreshService(db *gorm.DB, cfg *common.Config, payload *JWTAccessTokenPayl
 		}
 	}
 
-	//write refresh token random to db
-	err = db.Model(database.UserKeys{}).Where(
-		&database.UserKeys{
-			UserId: payload.UserId,
-			Random: payload.RefreshRand.String(),
-		},
-	).Updates(
-		database.UserKeys{
-			Random:    random.String(),
-			ExpiredAt: refreshExpires,
-		}).Error
+	// rotate the refresh token random, a concurrent refresh with the same token finds the old random gone
+	var rotated int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(database.UserKeys{}).Where("id = ? AND random = ?", session.Id, session.Random).Updates(
+			database.UserKeys{
+				Random:          random.String(),
+				ExpiredAt:       refreshExpires,
+				LastUsedAt:      time.Now(),
+				AccessTokenId:   accessTokenId,
+				AccessExpiresAt: expires,
+				IP:              client.IP,
+				UserAgent:       client.UserAgent,
+				DeviceHash:      fingerprint,
+			})
+		rotated = res.RowsAffected
+		if res.Error != nil || rotated == 0 {
+			return res.Error
+		}
 
+		// the session only remembers its newest access token, so the replaced one could not be revoked later
+		return revokeToken(tx, session.AccessTokenId, session.AccessExpiresAt)
+	})
 	if err != nil {
-		logger.PrintfError("Error updating user key with user id: %s and random: %s", payload.UserId, payload.RefreshRand)
+		logger.PrintfError("Error updating user key with user id: %s and random: %s. Error: %s", payload.UserId, payload.RefreshRand, err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
 
+	if rotated == 0 {
+		logger.PrintfWarning("Refresh token of session: %s of user: %s was already used", session.Id, payload.UserId)
+		return JWTPair{}, &api.ApiError{
+			Code:  498, // token expired/invalid
+			Error: enum.InvalidRefreshToken,
+		}
 	}
 
 	logger.Printf("Refreshed token for user with id: %s", payload.UserId)
 
 	return JWTPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshExpiresIn: lifetime,
 	}, nil
 }
 
 func LogoutService(db *gorm.DB, payload *JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
-	if err := db.Delete(&database.UserKeys{}, payload.RefreshRand).Error; err != nil {
+	if _, err := EndSessions(db, db.Where("user_id = ? AND random = ?", payload.UserId, payload.RefreshRand.String())); err != nil {
 		logger.PrintfError("Could not delete Refresh Token with random: %s and user id: %s", payload.RefreshRand, payload.UserId)
 		return &api.ApiError{
 			Code:    http.StatusInternalServerError,
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"easyflow-backend/src/common"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// the keyring is loaded once per process, so every test uses the same key settings
+func testConfig() *common.Config {
+	return &common.Config{
+		JwtSecret:         "test-secret",
+		JwtKeyId:          "test",
+		JwtIssuer:         "easyflow",
+		JwtAudience:       "easyflow-api",
+		JwtAlgorithm:      "HS256",
+		JwtExpirationTime: 600,
+	}
+}
+
+func testToken(t *testing.T, cfg *common.Config, typ TokenType, jti string, lifetime time.Duration) string {
+	t.Helper()
+	random := uuid.New()
+	issuedAt := time.Now().Add(-time.Minute)
+	token, err := generateJwt(cfg, &JWTAccessTokenPayload{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(lifetime)),
+			Issuer:    cfg.JwtIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JwtAudience},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+		},
+		UserId:      uuid.NewString(),
+		RefreshRand: &random,
+		Type:        typ,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateTokenType(t *testing.T) {
+	access := 10 * time.Minute
+	refresh := 24 * time.Hour
+	future := int(time.Now().Add(time.Hour).Unix())
+	past := int(time.Now().Add(-time.Hour).Unix())
+
+	tests := []struct {
+		name     string
+		typ      TokenType
+		jti      string
+		lifetime time.Duration
+		// JWT_UNTYPED_TOKENS_UNTIL
+		untypedUntil int
+		expected     TokenType
+		valid        bool
+	}{
+		{"access as access", TokenTypeAccess, "jti", access, 0, TokenTypeAccess, true},
+		{"access as refresh", TokenTypeAccess, "jti", access, 0, TokenTypeRefresh, false},
+		{"refresh as refresh", TokenTypeRefresh, "", refresh, 0, TokenTypeRefresh, true},
+		{"refresh as access", TokenTypeRefresh, "", refresh, 0, TokenTypeAccess, false},
+		{"untyped with jti as access", "", "jti", access, future, TokenTypeAccess, true},
+		{"untyped with jti as refresh", "", "jti", refresh, future, TokenTypeRefresh, false},
+		{"untyped access without jti as access", "", "", access, future, TokenTypeAccess, true},
+		{"untyped access without jti as refresh", "", "", access, future, TokenTypeRefresh, false},
+		{"untyped refresh before the cutoff", "", "", refresh, future, TokenTypeRefresh, true},
+		{"untyped refresh after the cutoff", "", "", refresh, past, TokenTypeRefresh, false},
+		{"untyped refresh without cutoff", "", "", refresh, 0, TokenTypeRefresh, false},
+		{"untyped refresh as access", "", "", refresh, future, TokenTypeAccess, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.JwtUntypedTokensUntil = tt.untypedUntil
+
+			_, err := ValidateToken(cfg, testToken(t, cfg, tt.typ, tt.jti, tt.lifetime), tt.expected)
+			if tt.valid && err != nil {
+				t.Errorf("expected the token to be valid, got %s", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestValidateTokenClaims(t *testing.T) {
+	cfg := testConfig()
+	token := testToken(t, cfg, TokenTypeAccess, "jti", 10*time.Minute)
+
+	other := testConfig()
+	other.JwtIssuer = "someone-else"
+	if _, err := ValidateToken(other, token, TokenTypeAccess); err == nil {
+		t.Error("expected a token of another issuer to be rejected")
+	}
+
+	other = testConfig()
+	other.JwtAudience = "another-api"
+	if _, err := ValidateToken(other, token, TokenTypeAccess); err == nil {
+		t.Error("expected a token for another audience to be rejected")
+	}
+
+	expired := testToken(t, cfg, TokenTypeAccess, "jti", 30*time.Second)
+	if _, err := ValidateToken(cfg, expired, TokenTypeAccess); err == nil {
+		t.Error("expected an expired token to be rejected")
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"easyflow-backend/src/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// isTokenRevoked checks the denylist for the jti of an access token.
+// Tokens issued before access tokens carried a jti cannot be revoked and expire on their own.
+func isTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	var count int64
+	if err := db.Model(&database.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// revokeToken puts a single access token on the denylist until its expiry.
+func revokeToken(tx *gorm.DB, jti string, expiresAt time.Time) error {
+	if jti == "" || !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&database.RevokedToken{
+		Jti:       jti,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// EndSessions deletes the sessions matched by query and puts their current access tokens on the denylist,
+// so they stop working immediately instead of at their expiry.
+func EndSessions(tx *gorm.DB, query *gorm.DB) (int64, error) {
+	var sessions []database.UserKeys
+	if err := query.Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+
+	if len(sessions) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	revoked := make([]database.RevokedToken, 0, len(sessions))
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+		if session.AccessTokenId != "" && session.AccessExpiresAt.After(now) {
+			revoked = append(revoked, database.RevokedToken{
+				Jti:       session.AccessTokenId,
+				ExpiresAt: session.AccessExpiresAt,
+			})
+		}
+	}
+
+	if len(revoked) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	// entries are only needed until the token would have expired anyway
+	if err := tx.Where("expires_at < ?", now).Delete(&database.RevokedToken{}).Error; err != nil {
+		return 0, err
+	}
+
+	res := tx.Where("id IN ?", ids).Delete(&database.UserKeys{})
+	return res.RowsAffected, res.Error
+}
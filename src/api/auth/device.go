@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/geoip"
+	"easyflow-backend/src/mail"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// deviceFingerprint is deliberately coarse: the user agent and the country of the client.
+// Changing IPs within a country or app updates are not reported as a new device.
+func deviceFingerprint(userAgent string, country string) string {
+	hash := sha256.Sum256([]byte(userAgent + "\n" + country))
+	return hex.EncodeToString(hash[:])
+}
+
+// versionPattern matches version numbers in user agents
+var versionPattern = regexp.MustCompile(`[0-9][0-9._]*`)
+
+// sessionFingerprint identifies the device a session is bound to. Version numbers are ignored,
+// so browser and app updates keep the session while another browser, OS or device type does not.
+func sessionFingerprint(client common.ClientInfo) string {
+	userAgent := versionPattern.ReplaceAllString(strings.ToLower(client.UserAgent), "")
+	hash := sha256.Sum256([]byte(userAgent + "\n" + strings.ToLower(client.ClientHints)))
+	return hex.EncodeToString(hash[:])
+}
+
+// checkNewDevice records the device of a login and notifies the user when it was not seen before.
+// The first device of a user is recorded silently.
+func checkNewDevice(db *gorm.DB, cfg *common.Config, user *database.User, client common.ClientInfo, logger *common.Logger) {
+	// guests have no mailbox to notify
+	if user.Guest {
+		return
+	}
+
+	if len(client.UserAgent) > 512 {
+		client.UserAgent = client.UserAgent[:512]
+	}
+	location := geoip.Lookup(client.IP)
+	fingerprint := deviceFingerprint(client.UserAgent, location.Country)
+
+	var device database.KnownDevice
+	err := db.Where("user_id = ? AND fingerprint = ?", user.Id, fingerprint).First(&device).Error
+	if err == nil {
+		if err := db.Model(&device).Update("last_seen_at", time.Now()).Error; err != nil {
+			logger.PrintfError("Could not update known device %s: %s", device.Id, err)
+		}
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.PrintfError("Could not check known devices of user: %s. Error: %s", user.Id, err)
+		return
+	}
+
+	var known int64
+	if err := db.Model(&database.KnownDevice{}).Where("user_id = ?", user.Id).Count(&known).Error; err != nil {
+		logger.PrintfError("Could not count known devices of user: %s. Error: %s", user.Id, err)
+		return
+	}
+
+	if err := db.Create(&database.KnownDevice{
+		Fingerprint: fingerprint,
+		UserAgent:   client.UserAgent,
+		Country:     location.Country,
+		LastSeenAt:  time.Now(),
+		UserId:      user.Id,
+	}).Error; err != nil {
+		logger.PrintfError("Could not record new device of user: %s. Error: %s", user.Id, err)
+		return
+	}
+
+	if known == 0 {
+		return
+	}
+
+	audit.Record(db, logger, user.Id, enum.NewDeviceLogin, client, &client.UserAgent)
+	logger.PrintfInfo("Login of user: %s from a new device", user.Id)
+
+	if !cfg.NewDeviceNotification {
+		return
+	}
+
+	where := location.Country
+	if location.City != "" {
+		where = fmt.Sprintf("%s, %s", location.City, location.Country)
+	}
+	if where == "" {
+		where = "unknown location"
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nyour account was just used to log in from a new device:\n\n%s\n%s (%s)\n\nIf this was not you, change your password and end the unknown session in the app.",
+		user.Name, client.UserAgent, where, client.IP)
+
+	// security notifications are transactional and ignore mail preferences
+	logger = logger.Detached()
+	go func() {
+		if err := mail.Send(cfg, logger, user.Email, "New login to your account", body); err != nil {
+			logger.PrintfError("Could not send new device mail to user: %s. Error: %s", user.Id, err)
+		}
+	}()
+}
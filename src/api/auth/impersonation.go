@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IssueImpersonationToken creates a short lived access token for user that is flagged with the operator.
+// It has no refresh session, so it cannot be refreshed and it is only accepted as bearer token.
+func IssueImpersonationToken(cfg *common.Config, user *database.User, operator string) (string, *JWTAccessTokenPayload, error) {
+	expires := time.Now().Add(time.Duration(cfg.ImpersonationTokenLifetime) * time.Second)
+	payload := JWTAccessTokenPayload{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expires),
+			Issuer:    cfg.JwtIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JwtAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserId:         user.Id,
+		Role:           user.Role,
+		Guest:          user.Guest,
+		ImpersonatedBy: operator,
+		Type:           TokenTypeAccess,
+	}
+
+	token, err := generateJwt(cfg, &payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &payload, nil
+}
+
+// recordImpersonatedRequest writes a request made with an impersonation token to the impersonation log.
+// It runs after the handler, so the response status is known.
+func recordImpersonatedRequest(db *gorm.DB, logger *common.Logger, payload *JWTAccessTokenPayload, c *gin.Context) {
+	path := c.Request.URL.Path
+	if len(path) > 2048 {
+		path = path[:2048]
+	}
+
+	entry := database.ImpersonationLog{
+		TokenId:  payload.ID,
+		Operator: payload.ImpersonatedBy,
+		UserId:   payload.UserId,
+		Method:   c.Request.Method,
+		Path:     path,
+		Status:   c.Writer.Status(),
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		logger.PrintfError("Could not write impersonation log of user: %s. Error: %s", payload.UserId, err)
+	}
+}
@@ -1,17 +1,36 @@
 package auth
 
 import (
+	"easyflow-backend/src/enum"
+
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
+)
+
 type JWTAccessTokenPayload struct {
 	jwt.RegisteredClaims
 	UserId      string     `json:"userId"`
+	Role        enum.Role  `json:"role"`
 	RefreshRand *uuid.UUID `json:"refreshRand"`
+	Guest       bool       `json:"guest,omitempty"`
+	// user id of the admin for tokens issued through the admin impersonation endpoint
+	ImpersonatedBy string `json:"impersonatedBy,omitempty"`
+	// access tokens are rejected by the RefreshAuthGuard and refresh tokens by the AuthGuard
+	Type TokenType `json:"typ,omitempty"`
+	// id of the api key the request was authenticated with, never part of a token
+	ApiKeyId string `json:"-"`
 }
 
 type JWTPair struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+	// lifetime of the refresh token in seconds
+	RefreshExpiresIn int `json:"-"`
 }
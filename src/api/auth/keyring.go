@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"easyflow-backend/src/common"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtKey struct {
+	method jwt.SigningMethod
+	// nil when only the public key is known
+	sign   interface{}
+	verify interface{}
+}
+
+type keyring struct {
+	kid      string
+	current  jwtKey
+	previous map[string]jwtKey
+	// key of tokens issued before key ids were introduced, nil unless JWT_SECRET is set explicitly
+	legacy *jwtKey
+}
+
+// default of JWT_SECRET, it is public so tokens without key id signed with it are never accepted
+const defaultJwtSecret = "public_secret"
+
+var loadedKeyring *keyring
+var keyringErr error
+var keyringOnce sync.Once
+
+func hmacKey(secret string) jwtKey {
+	return jwtKey{
+		method: jwt.SigningMethodHS256,
+		sign:   []byte(secret),
+		verify: []byte(secret),
+	}
+}
+
+func loadPrivateKey(algorithm string, path string) (jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return jwtKey{}, err
+	}
+
+	switch algorithm {
+	case "RS256":
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return jwtKey{}, err
+		}
+		return jwtKey{method: jwt.SigningMethodRS256, sign: key, verify: &key.PublicKey}, nil
+	case "EdDSA":
+		key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return jwtKey{}, err
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return jwtKey{}, errors.New("invalid ed25519 private key")
+		}
+		return jwtKey{method: jwt.SigningMethodEdDSA, sign: edKey, verify: edKey.Public()}, nil
+	default:
+		return jwtKey{}, fmt.Errorf("unsupported jwt algorithm: %s", algorithm)
+	}
+}
+
+// loadPublicKey loads a PEM encoded RSA or Ed25519 public key.
+func loadPublicKey(path string) (jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return jwtKey{}, err
+	}
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return jwtKey{method: jwt.SigningMethodRS256, verify: key}, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return jwtKey{method: jwt.SigningMethodEdDSA, verify: key}, nil
+	}
+
+	return jwtKey{}, fmt.Errorf("%s is neither a RSA nor an Ed25519 public key", path)
+}
+
+// newKeyring builds the keys from the config.
+// With an asymmetric algorithm tokens are signed with JWT_PRIVATE_KEY_FILE. Services that only validate tokens
+// can be given JWT_PUBLIC_KEY_FILE instead, so the signing key never has to be shared.
+func newKeyring(cfg *common.Config) (*keyring, error) {
+	kr := &keyring{
+		kid:      cfg.JwtKeyId,
+		previous: make(map[string]jwtKey),
+	}
+	if cfg.JwtSecret != "" && cfg.JwtSecret != defaultJwtSecret {
+		legacy := hmacKey(cfg.JwtSecret)
+		kr.legacy = &legacy
+	}
+
+	switch {
+	case cfg.JwtAlgorithm == "" || cfg.JwtAlgorithm == "HS256":
+		kr.current = hmacKey(cfg.JwtSecret)
+	case cfg.JwtPrivateKeyFile != "":
+		key, err := loadPrivateKey(cfg.JwtAlgorithm, cfg.JwtPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jwt private key: %w", err)
+		}
+		kr.current = key
+	case cfg.JwtPublicKeyFile != "":
+		key, err := loadPublicKey(cfg.JwtPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jwt public key: %w", err)
+		}
+		if key.method.Alg() != cfg.JwtAlgorithm {
+			return nil, fmt.Errorf("jwt public key does not match algorithm %s", cfg.JwtAlgorithm)
+		}
+		kr.current = key
+	default:
+		return nil, fmt.Errorf("jwt algorithm %s requires a private or public key file", cfg.JwtAlgorithm)
+	}
+
+	// previous keys are "kid:secret" pairs for HS256 and "kid:path" pairs of public keys
+	for _, entry := range cfg.JwtPreviousKeys {
+		if id, secret, ok := strings.Cut(entry, ":"); ok {
+			kr.previous[id] = hmacKey(secret)
+		}
+	}
+	for _, entry := range cfg.JwtPreviousPublicKeys {
+		if id, path, ok := strings.Cut(entry, ":"); ok {
+			key, err := loadPublicKey(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load previous jwt public key %s: %w", id, err)
+			}
+			kr.previous[id] = key
+		}
+	}
+
+	return kr, nil
+}
+
+func getKeyring(cfg *common.Config) (*keyring, error) {
+	keyringOnce.Do(func() {
+		loadedKeyring, keyringErr = newKeyring(cfg)
+	})
+	return loadedKeyring, keyringErr
+}
+
+// signingKey returns the key id and key new tokens are signed with.
+func signingKey(cfg *common.Config) (string, jwtKey, error) {
+	kr, err := getKeyring(cfg)
+	if err != nil {
+		return "", jwtKey{}, err
+	}
+	if kr.current.sign == nil {
+		return "", jwtKey{}, errors.New("no jwt signing key configured")
+	}
+	return kr.kid, kr.current, nil
+}
+
+// verificationKey returns the key for the given key id.
+// Previous keys stay valid until they are removed from the config,
+// which allows rotating keys without logging out every user.
+func verificationKey(cfg *common.Config, kid string) (jwtKey, error) {
+	kr, err := getKeyring(cfg)
+	if err != nil {
+		return jwtKey{}, err
+	}
+
+	// tokens issued before key ids were introduced carry no kid
+	if kid == "" {
+		if kr.legacy == nil {
+			return jwtKey{}, errors.New("token has no key id")
+		}
+		return *kr.legacy, nil
+	}
+	if kid == kr.kid {
+		return kr.current, nil
+	}
+	if key, ok := kr.previous[kid]; ok {
+		return key, nil
+	}
+
+	return jwtKey{}, fmt.Errorf("unknown key id: %s", kid)
+}
+
+func keyFunc(cfg *common.Config) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := verificationKey(cfg, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		// the algorithm is pinned to the key type, so e.g. a public key is never used as HMAC secret
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.verify, nil
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// limiters of login attempts per target email, independent of the client IP
+var emailLimiterMap = make(map[string]*rate.Limiter)
+var emailLimiterMapMutex sync.Mutex
+
+// above this size limiters that are full again are dropped, they behave like new ones
+const emailLimiterMapPruneSize = 10000
+
+// limiters of requests per guest account, guests get a lower limit than registered users
+var guestLimiterMap = make(map[string]*rate.Limiter)
+var guestLimiterMapMutex sync.Mutex
+
+// above this size guest limiters that are full again are dropped, like the email limiters
+const guestLimiterMapPruneSize = 10000
+
+// returns the request rate limiter for the guest.
+func getGuestLimiter(cfg *common.Config, userId string) *rate.Limiter {
+	guestLimiterMapMutex.Lock()
+	defer guestLimiterMapMutex.Unlock()
+
+	limiter, ok := guestLimiterMap[userId]
+	if !ok {
+		if len(guestLimiterMap) >= guestLimiterMapPruneSize {
+			for key, l := range guestLimiterMap {
+				if l.Tokens() >= float64(l.Burst()) {
+					delete(guestLimiterMap, key)
+				}
+			}
+		}
+
+		limiter = rate.NewLimiter(rate.Limit(cfg.GuestRateLimit), cfg.GuestRateBurst)
+		guestLimiterMap[userId] = limiter
+	}
+	return limiter
+}
+
+// returns the login rate limiter for the email.
+func getEmailLimiter(cfg *common.Config, email string) *rate.Limiter {
+	emailLimiterMapMutex.Lock()
+	defer emailLimiterMapMutex.Unlock()
+
+	limiter, ok := emailLimiterMap[email]
+	if !ok {
+		if len(emailLimiterMap) >= emailLimiterMapPruneSize {
+			for key, l := range emailLimiterMap {
+				if l.Tokens() >= float64(l.Burst()) {
+					delete(emailLimiterMap, key)
+				}
+			}
+		}
+
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(cfg.LoginEmailRate)), cfg.LoginEmailBurst)
+		emailLimiterMap[email] = limiter
+	}
+	return limiter
+}
+
+// checkEmailRate limits login attempts to one account, so attackers rotating IPs cannot brute force it.
+// It applies to unknown emails as well to not reveal which accounts exist.
+func checkEmailRate(cfg *common.Config, email string) *api.ApiError {
+	if cfg.LoginEmailRate <= 0 {
+		return nil
+	}
+
+	limiter := getEmailLimiter(cfg, strings.ToLower(email))
+	if limiter.Allow() {
+		return nil
+	}
+
+	return &api.ApiError{
+		Code:    http.StatusTooManyRequests,
+		Error:   enum.TooManyAttempts,
+		Details: map[string]int{"retryAfter": int(math.Ceil(float64(time.Minute/time.Duration(cfg.LoginEmailRate)) / float64(time.Second)))},
+	}
+}
+
+// checkLockout rejects logins to accounts that are locked after too many failed attempts.
+func checkLockout(user *database.User) *api.ApiError {
+	if user.LockedUntil == nil || !user.LockedUntil.After(time.Now()) {
+		return nil
+	}
+
+	return &api.ApiError{
+		Code:    http.StatusTooManyRequests,
+		Error:   enum.TooManyAttempts,
+		Details: map[string]int{"retryAfter": int(math.Ceil(time.Until(*user.LockedUntil).Seconds()))},
+	}
+}
+
+// recordFailedLogin counts a failed login and locks the account once the threshold is reached.
+// Every further failure doubles the lock duration up to the configured maximum.
+// This works per account, independent of the IP based rate limiter.
+func recordFailedLogin(db *gorm.DB, cfg *common.Config, user *database.User, logger *common.Logger) {
+	// concurrent failures must not overwrite each other's count, the counter is incremented in the database
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", user.Id).
+			Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1")).Error; err != nil {
+			return err
+		}
+
+		var attempts int
+		if err := tx.Model(&database.User{}).Where("id = ?", user.Id).Pluck("failed_login_attempts", &attempts).Error; err != nil {
+			return err
+		}
+
+		if cfg.LoginLockoutThreshold <= 0 || attempts < cfg.LoginLockoutThreshold {
+			return nil
+		}
+
+		duration := time.Duration(cfg.LoginLockoutDuration) * time.Second
+		maxDuration := time.Duration(cfg.LoginLockoutMaxDuration) * time.Second
+		for i := cfg.LoginLockoutThreshold; i < attempts && duration < maxDuration; i++ {
+			duration *= 2
+		}
+		duration = min(duration, maxDuration)
+
+		if err := tx.Model(&database.User{}).Where("id = ?", user.Id).Update("locked_until", time.Now().Add(duration)).Error; err != nil {
+			return err
+		}
+		logger.PrintfWarning("Locked user: %s for %s after %d failed logins", user.Id, duration, attempts)
+		return nil
+	})
+	if err != nil {
+		logger.PrintfError("Could not record failed login for user: %s. Error: %s", user.Id, err)
+	}
+}
+
+// resetFailedLogins clears the failed login counter after a successful login.
+func resetFailedLogins(db *gorm.DB, user *database.User, logger *common.Logger) {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return
+	}
+
+	if err := db.Model(&database.User{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error; err != nil {
+		logger.PrintfError("Could not reset failed logins for user: %s. Error: %s", user.Id, err)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oauthStateCookie = "oauth_state"
+
+func StartOAuthController(c *gin.Context) {
+	_, logger, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+	state := hex.EncodeToString(raw)
+
+	redirectURL, err := GetOAuthRedirectURL(cfg, c.Param("provider"), state, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, 60*10, "/auth/oauth", cfg.Domain, cfg.Stage == "production", true)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+func OAuthCallbackController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	state, _ := c.Cookie(oauthStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, "/auth/oauth", cfg.Domain, cfg.Stage == "production", true)
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		logger.PrintfWarning("OAuth state mismatch")
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidCookie,
+		})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	tokens, err := OAuthLoginService(db, cfg, c.Param("provider"), code, common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	SetAuthCookies(c, cfg, tokens)
+	c.Redirect(http.StatusFound, cfg.GetFrontendURL())
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"context"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+	"gorm.io/gorm"
+)
+
+var errAccountExists = errors.New("account exists outside of the linked domains")
+
+// linksDomain reports whether an existing account with the email is linked to an external identity. Anyone who
+// controls the identity provider can assert any email, so only domains it is trusted with are linked, including
+// their subdomains.
+func linksDomain(domains []string, email string) bool {
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, entry := range domains {
+		entry = strings.ToLower(entry)
+		if domain == entry || strings.HasSuffix(domain, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// oauthIdentity is the subset of the provider profile needed to link or create a user.
+type oauthIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type oauthProvider struct {
+	config    *oauth2.Config
+	fetchUser func(ctx context.Context, client *http.Client) (*oauthIdentity, error)
+}
+
+func getOAuthProvider(cfg *common.Config, name string) (*oauthProvider, bool) {
+	redirectURL := fmt.Sprintf("%s/auth/oauth/%s/callback", cfg.BackendURL, name)
+
+	switch name {
+	case "google":
+		if cfg.OAuthGoogleClientId == "" {
+			return nil, false
+		}
+		return &oauthProvider{
+			config: &oauth2.Config{
+				ClientID:     cfg.OAuthGoogleClientId,
+				ClientSecret: cfg.OAuthGoogleClientSecret,
+				Endpoint:     endpoints.Google,
+				RedirectURL:  redirectURL,
+				Scopes:       []string{"openid", "email", "profile"},
+			},
+			fetchUser: fetchGoogleUser,
+		}, true
+	case "github":
+		if cfg.OAuthGithubClientId == "" {
+			return nil, false
+		}
+		return &oauthProvider{
+			config: &oauth2.Config{
+				ClientID:     cfg.OAuthGithubClientId,
+				ClientSecret: cfg.OAuthGithubClientSecret,
+				Endpoint:     endpoints.GitHub,
+				RedirectURL:  redirectURL,
+				Scopes:       []string{"read:user", "user:email"},
+			},
+			fetchUser: fetchGithubUser,
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// EnabledOAuthProviders returns the names of all configured oauth providers.
+func EnabledOAuthProviders(cfg *common.Config) []string {
+	providers := []string{}
+	for _, name := range []string{"google", "github"} {
+		if _, ok := getOAuthProvider(cfg, name); ok {
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+func fetchGoogleUser(ctx context.Context, client *http.Client) (*oauthIdentity, error) {
+	var profile struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := getJSON(ctx, client, "https://openidconnect.googleapis.com/v1/userinfo", &profile); err != nil {
+		return nil, err
+	}
+
+	return &oauthIdentity{
+		Subject:       profile.Sub,
+		Email:         profile.Email,
+		EmailVerified: profile.EmailVerified,
+		Name:          profile.Name,
+	}, nil
+}
+
+func fetchGithubUser(ctx context.Context, client *http.Client) (*oauthIdentity, error) {
+	var profile struct {
+		Id    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user", &profile); err != nil {
+		return nil, err
+	}
+
+	// the public profile email is optional and not necessarily verified
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &oauthIdentity{
+		Subject: strconv.FormatInt(profile.Id, 10),
+		Name:    profile.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+
+	return identity, nil
+}
+
+// GetOAuthRedirectURL returns the URL of the provider's consent page.
+func GetOAuthRedirectURL(cfg *common.Config, providerName string, state string, logger *common.Logger) (string, *api.ApiError) {
+	provider, ok := getOAuthProvider(cfg, providerName)
+	if !ok {
+		logger.PrintfWarning("OAuth provider %s is not configured", providerName)
+		return "", &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	return provider.config.AuthCodeURL(state), nil
+}
+
+// OAuthLoginService exchanges the code, links the provider account to a user (creating one if needed)
+// and issues the same token pair as a password login.
+func OAuthLoginService(db *gorm.DB, cfg *common.Config, providerName string, code string, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
+	provider, ok := getOAuthProvider(cfg, providerName)
+	if !ok {
+		logger.PrintfWarning("OAuth provider %s is not configured", providerName)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	ctx := context.Background()
+	token, err := provider.config.Exchange(ctx, code)
+	if err != nil {
+		logger.PrintfWarning("Could not exchange %s oauth code: %s", providerName, err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+
+	identity, err := provider.fetchUser(ctx, provider.config.Client(ctx, token))
+	if err != nil {
+		logger.PrintfError("Could not fetch %s user profile: %s", providerName, err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusBadGateway,
+			Error: enum.ApiError,
+		}
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		logger.PrintfWarning("%s account %s has no verified email", providerName, identity.Subject)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.EmailNotVerified,
+		}
+	}
+
+	var user database.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var account database.OAuthAccount
+		err := tx.Preload("User").Where("provider = ? AND subject = ?", providerName, identity.Subject).First(&account).Error
+		if err == nil {
+			user = account.User
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// link to an existing account with the same verified email within OAUTH_LINK_DOMAINS or create a new one
+		err = tx.Where("email = ?", identity.Email).First(&user).Error
+		if err == nil && !linksDomain(cfg.OAuthLinkDomains, identity.Email) {
+			return errAccountExists
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = database.User{
+				Email:         identity.Email,
+				EmailVerified: true,
+				Name:          identity.Name,
+			}
+			if len(user.Name) > 50 {
+				user.Name = user.Name[:50]
+			}
+			err = tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&database.OAuthAccount{
+			Provider: providerName,
+			Subject:  identity.Subject,
+			UserId:   user.Id,
+		}).Error
+	})
+	if errors.Is(err, errAccountExists) {
+		logger.PrintfWarning("%s account %s matches an existing account outside of the linked domains", providerName, identity.Subject)
+		return JWTPair{}, &api.ApiError{
+			Code:    http.StatusConflict,
+			Error:   enum.AlreadyExists,
+			Details: "An account with this email already exists",
+		}
+	}
+	if err != nil {
+		logger.PrintfError("Error linking %s account %s: %s", providerName, identity.Subject, err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.PrintfInfo("User: %s authenticated with %s", user.Id, providerName)
+
+	return completeLogin(db, cfg, &user, client, cfg.RefreshExpirationTime, logger)
+}
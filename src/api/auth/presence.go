@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// last seen is written at most once per interval and user, presence does not need more precision
+const lastSeenWriteInterval = time.Minute
+
+var lastSeenWrites = make(map[string]time.Time)
+var lastSeenMutex sync.Mutex
+var lastSeenPrune time.Time
+
+// touchLastSeen records an authenticated request of the user for the presence api.
+func touchLastSeen(db *gorm.DB, userId string, logger *common.Logger) {
+	now := time.Now()
+
+	lastSeenMutex.Lock()
+	if now.Sub(lastSeenWrites[userId]) < lastSeenWriteInterval {
+		lastSeenMutex.Unlock()
+		return
+	}
+	lastSeenWrites[userId] = now
+	if now.Sub(lastSeenPrune) > lastSeenWriteInterval {
+		lastSeenPrune = now
+		for id, written := range lastSeenWrites {
+			if now.Sub(written) > lastSeenWriteInterval {
+				delete(lastSeenWrites, id)
+			}
+		}
+	}
+	lastSeenMutex.Unlock()
+
+	// keeps updated_at, it tracks changes of the profile
+	if err := db.Model(&database.User{}).Where("id = ?", userId).
+		UpdateColumns(map[string]interface{}{"last_seen_at": now, "updated_at": gorm.Expr("updated_at")}).Error; err != nil {
+		logger.PrintfError("Could not update last seen of user: %s. Error: %s", userId, err)
+	}
+}
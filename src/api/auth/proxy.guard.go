@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// proxyIdentity is the user a trusted reverse proxy authenticated.
+type proxyIdentity struct {
+	Email string
+	Name  string
+}
+
+// proxySignature is the hex encoded HMAC-SHA256 of "<email>\n<name>\n<timestamp>" with the shared proxy secret.
+func proxySignature(secret string, email string, name string, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(email + "\n" + name + "\n" + timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ProxyAuthGuard accepts identities injected by a trusted reverse proxy (e.g. oauth2-proxy or Authelia).
+// The proxy has to sign the identity headers together with a unix timestamp in X-Proxy-Timestamp
+// and send the signature in X-Proxy-Signature, so the headers cannot be forged by clients.
+// The guard is disabled when no proxy secret is configured.
+func ProxyAuthGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, logger, _, cfg, errs := common.SetupEndpoint[any](c)
+		if errs != nil {
+			c.JSON(http.StatusInternalServerError, api.ApiError{
+				Code:    http.StatusInternalServerError,
+				Error:   enum.ApiError,
+				Details: errs,
+			})
+			c.Abort()
+			return
+		}
+
+		if cfg.ProxyAuthSecret == "" {
+			c.JSON(http.StatusNotFound, api.ApiError{
+				Code:  http.StatusNotFound,
+				Error: enum.NotFound,
+			})
+			c.Abort()
+			return
+		}
+
+		email := c.GetHeader(cfg.ProxyAuthEmailHeader)
+		name := c.GetHeader(cfg.ProxyAuthNameHeader)
+		timestamp := c.GetHeader("X-Proxy-Timestamp")
+		signature := c.GetHeader("X-Proxy-Signature")
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		valid := err == nil && email != "" &&
+			math.Abs(time.Since(time.Unix(unix, 0)).Seconds()) <= float64(cfg.ProxyAuthMaxSkew) &&
+			hmac.Equal([]byte(signature), []byte(proxySignature(cfg.ProxyAuthSecret, email, name, timestamp)))
+		if !valid {
+			logger.PrintfWarning("Rejected proxy identity from %s", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, api.ApiError{
+				Code:  http.StatusUnauthorized,
+				Error: enum.Unauthorized,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("proxyIdentity", &proxyIdentity{Email: email, Name: name})
+		c.Next()
+	}
+}
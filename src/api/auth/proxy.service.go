@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// provider name of proxy identities in the oauth accounts table, the subject is the email
+const proxyProviderName = "proxy"
+
+// ProxyLoginService logs in the user authenticated by the reverse proxy and creates the account on first login.
+// Existing accounts that never logged in through the proxy are only linked within PROXY_LINK_DOMAINS.
+func ProxyLoginService(db *gorm.DB, cfg *common.Config, identity *proxyIdentity, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
+	var user database.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var account database.OAuthAccount
+		err := tx.Preload("User").Where("provider = ? AND subject = ?", proxyProviderName, identity.Email).First(&account).Error
+		if err == nil {
+			user = account.User
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.Where("email = ?", identity.Email).First(&user).Error
+		if err == nil && !linksDomain(cfg.ProxyLinkDomains, identity.Email) {
+			return errAccountExists
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			name := identity.Name
+			if name == "" {
+				name, _, _ = strings.Cut(identity.Email, "@")
+			}
+			if len(name) > 50 {
+				name = name[:50]
+			}
+
+			// the proxy verified the identity, so the email counts as verified
+			user = database.User{
+				Email:         identity.Email,
+				EmailVerified: true,
+				Name:          name,
+			}
+			err = tx.Create(&user).Error
+			if err == nil {
+				logger.PrintfInfo("Provisioned user: %s from proxy identity", user.Id)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&database.OAuthAccount{
+			Provider: proxyProviderName,
+			Subject:  identity.Email,
+			UserId:   user.Id,
+		}).Error
+	})
+	if errors.Is(err, errAccountExists) {
+		logger.PrintfWarning("Proxy identity %s matches an existing account outside of the linked domains", identity.Email)
+		return JWTPair{}, &api.ApiError{
+			Code:    http.StatusConflict,
+			Error:   enum.AlreadyExists,
+			Details: "An account with this email already exists",
+		}
+	}
+	if err != nil {
+		logger.PrintfError("Error loading proxy user %s: %s", identity.Email, err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return completeLogin(db, cfg, &user, client, cfg.RefreshExpirationTime, logger)
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/geoip"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// failed logins from one IP are counted over this window for the velocity signal
+const riskVelocityWindow = 10 * time.Minute
+
+// RiskDecision is the outcome of a login risk assessment.
+type RiskDecision string
+
+const (
+	RiskAllow            RiskDecision = "ALLOW"
+	RiskRequireCaptcha   RiskDecision = "REQUIRE_CAPTCHA"
+	RiskRequireTwoFactor RiskDecision = "REQUIRE_2FA"
+	RiskBlock            RiskDecision = "BLOCK"
+)
+
+// RiskSignals are the inputs of a login risk assessment. IP reputation is left to the scorer, it gets the IP.
+type RiskSignals struct {
+	UserId   string
+	IP       string
+	Location geoip.Location
+	// the user agent and country were not seen for this user before
+	NewDevice bool
+	// failed logins from the IP within the last 10 minutes, for any account
+	RecentFailures int64
+	// the last login came from another country within LOGIN_RISK_TRAVEL_WINDOW
+	ImpossibleTravel bool
+	// passkeys are the second factor, a user without one cannot satisfy REQUIRE_2FA
+	HasPasskey bool
+	// sent by the client after a REQUIRE_CAPTCHA response, verifying it is up to the scorer
+	CaptchaToken string
+}
+
+// RiskAssessment is a decision and the reason that is written to the audit log.
+type RiskAssessment struct {
+	Decision RiskDecision
+	Reason   string
+}
+
+// RiskScorer assesses password logins once the password was checked.
+type RiskScorer interface {
+	Score(signals RiskSignals) RiskAssessment
+}
+
+var riskScorer RiskScorer
+var riskScorerMutex sync.RWMutex
+
+// SetRiskScorer replaces the scorer used for password logins. A nil scorer disables risk scoring,
+// LOGIN_RISK_SCORING installs the rule based scorer at startup.
+func SetRiskScorer(scorer RiskScorer) {
+	riskScorerMutex.Lock()
+	defer riskScorerMutex.Unlock()
+
+	riskScorer = scorer
+}
+
+func getRiskScorer() RiskScorer {
+	riskScorerMutex.RLock()
+	defer riskScorerMutex.RUnlock()
+
+	return riskScorer
+}
+
+// ruleScorer is the built in scorer. It has no captcha provider, so it never asks for one.
+type ruleScorer struct {
+	velocityThreshold int64
+}
+
+// NewRuleScorer blocks IPs with velocityThreshold or more recent failed logins (0 disables it) and
+// asks for a passkey on impossible travel.
+func NewRuleScorer(velocityThreshold int) RiskScorer {
+	return &ruleScorer{velocityThreshold: int64(velocityThreshold)}
+}
+
+func (s *ruleScorer) Score(signals RiskSignals) RiskAssessment {
+	if s.velocityThreshold > 0 && signals.RecentFailures >= s.velocityThreshold {
+		return RiskAssessment{RiskBlock, fmt.Sprintf("%d failed logins from the ip", signals.RecentFailures)}
+	}
+
+	// without a passkey the user could not log in at all, the login is only audited
+	if signals.ImpossibleTravel && signals.HasPasskey {
+		return RiskAssessment{RiskRequireTwoFactor, "impossible travel"}
+	}
+	if signals.ImpossibleTravel {
+		return RiskAssessment{RiskAllow, "impossible travel, no passkey"}
+	}
+
+	return RiskAssessment{Decision: RiskAllow}
+}
+
+// collectRiskSignals gathers the signals of a password login. Signals that cannot be loaded are left empty.
+func collectRiskSignals(db *gorm.DB, cfg *common.Config, user *database.User, client common.ClientInfo, captchaToken string, logger *common.Logger) RiskSignals {
+	location := geoip.Lookup(client.IP)
+	signals := RiskSignals{
+		UserId:       user.Id,
+		IP:           client.IP,
+		Location:     location,
+		CaptchaToken: captchaToken,
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	var devices int64
+	var known int64
+	if err := db.Model(&database.KnownDevice{}).Where("user_id = ?", user.Id).Count(&devices).Error; err != nil {
+		logger.PrintfError("Could not count known devices of user: %s. Error: %s", user.Id, err)
+	} else if err := db.Model(&database.KnownDevice{}).Where("user_id = ? AND fingerprint = ?", user.Id, deviceFingerprint(userAgent, location.Country)).
+		Count(&known).Error; err != nil {
+		logger.PrintfError("Could not check known devices of user: %s. Error: %s", user.Id, err)
+	} else {
+		signals.NewDevice = devices > 0 && known == 0
+	}
+
+	if err := db.Model(&database.AuditLog{}).Where("ip = ? AND action = ? AND created_at > ?", client.IP, enum.LoginFailed, time.Now().Add(-riskVelocityWindow)).
+		Count(&signals.RecentFailures).Error; err != nil {
+		logger.PrintfError("Could not count failed logins of ip: %s. Error: %s", client.IP, err)
+	}
+
+	// geolocation has no coordinates, a login from another country within the window counts as impossible travel
+	if location.Country != "" && cfg.LoginRiskTravelWindow > 0 {
+		var last database.AuditLog
+		err := db.Where("user_id = ? AND action = ? AND created_at > ?", user.Id, enum.LoginSucceeded, time.Now().Add(-time.Duration(cfg.LoginRiskTravelWindow)*time.Second)).
+			Order("created_at desc").First(&last).Error
+		signals.ImpossibleTravel = err == nil && last.Country != "" && last.Country != location.Country
+	}
+
+	var passkeys int64
+	if err := db.Model(&database.WebAuthnCredential{}).Where("user_id = ?", user.Id).Count(&passkeys).Error; err != nil {
+		logger.PrintfError("Could not count passkeys of user: %s. Error: %s", user.Id, err)
+	}
+	signals.HasPasskey = passkeys > 0
+
+	return signals
+}
+
+// checkLoginRisk scores a password login and turns decisions other than ALLOW into errors.
+// Decisions with a reason are written to the audit log of the user.
+func checkLoginRisk(db *gorm.DB, cfg *common.Config, user *database.User, client common.ClientInfo, captchaToken string, logger *common.Logger) *api.ApiError {
+	scorer := getRiskScorer()
+	if scorer == nil {
+		return nil
+	}
+
+	assessment := scorer.Score(collectRiskSignals(db, cfg, user, client, captchaToken, logger))
+	if assessment.Decision != RiskAllow || assessment.Reason != "" {
+		details := fmt.Sprintf("%s: %s", assessment.Decision, assessment.Reason)
+		audit.Record(db, logger, user.Id, enum.LoginRisk, client, &details)
+		logger.PrintfWarning("Login risk of user: %s assessed as %s", user.Id, details)
+	}
+
+	switch assessment.Decision {
+	case RiskRequireCaptcha:
+		return &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.CaptchaRequired,
+		}
+	case RiskRequireTwoFactor:
+		return &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.PasskeyRequired,
+		}
+	case RiskBlock:
+		return &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.LoginBlocked,
+		}
+	}
+
+	return nil
+}
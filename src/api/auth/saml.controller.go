@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"encoding/xml"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const samlRequestCookie = "saml_request"
+
+func SamlMetadataController(c *gin.Context) {
+	_, logger, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	metadata, err := GetSamlMetadata(cfg, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	body, marshalErr := xml.MarshalIndent(metadata, "", "  ")
+	if marshalErr != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", body)
+}
+
+func StartSamlController(c *gin.Context) {
+	_, logger, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	redirectURL, requestId, err := GetSamlRedirectURL(cfg, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	// the idp posts the response cross site, a lax cookie would not be sent with it.
+	// Browsers drop SameSite=None cookies without Secure, they accept secure cookies from localhost over http.
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, requestId, 60*10, "/auth/saml", cfg.Domain, true, true)
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+func SamlAcsController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[SamlResponseRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	requestId, _ := c.Cookie(samlRequestCookie)
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, "", -1, "/auth/saml", cfg.Domain, true, true)
+
+	if requestId == "" {
+		logger.PrintfWarning("Saml response without a pending request")
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidCookie,
+		})
+		return
+	}
+
+	tokens, err := SamlLoginService(db, cfg, c.Request, requestId, common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	SetAuthCookies(c, cfg, tokens)
+	c.Redirect(http.StatusFound, cfg.GetFrontendURL())
+}
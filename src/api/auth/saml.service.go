@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"gorm.io/gorm"
+)
+
+// provider name of saml identities in the oauth accounts table
+const samlProviderName = "saml"
+
+var samlProvider *saml.ServiceProvider
+var samlProviderMutex sync.Mutex
+
+// SamlEnabled reports whether an identity provider is configured.
+func SamlEnabled(cfg *common.Config) bool {
+	return cfg.SamlIdpMetadataURL != ""
+}
+
+// getSamlProvider builds the service provider on first use. Fetching the idp metadata is retried
+// on the next login after a failure, so a temporarily unreachable idp does not need a restart.
+func getSamlProvider(cfg *common.Config) (*saml.ServiceProvider, error) {
+	samlProviderMutex.Lock()
+	defer samlProviderMutex.Unlock()
+
+	if samlProvider != nil {
+		return samlProvider, nil
+	}
+
+	metadataURL, err := url.Parse(cfg.SamlIdpMetadataURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	idpMetadata, err := samlsp.FetchMetadata(ctx, http.DefaultClient, *metadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch idp metadata: %w", err)
+	}
+
+	spMetadataURL, _ := url.Parse(cfg.BackendURL + "/auth/saml/metadata")
+	acsURL, _ := url.Parse(cfg.BackendURL + "/auth/saml/acs")
+
+	provider := &saml.ServiceProvider{
+		EntityID:    cfg.SamlEntityId,
+		MetadataURL: *spMetadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: idpMetadata,
+	}
+	if provider.EntityID == "" {
+		provider.EntityID = spMetadataURL.String()
+	}
+
+	// the key pair is optional, it signs authentication requests and decrypts encrypted assertions
+	if cfg.SamlCertificateFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(cfg.SamlCertificateFile, cfg.SamlPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load saml key pair: %w", err)
+		}
+		signer, ok := keyPair.PrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("saml private key cannot sign")
+		}
+		certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		provider.Key = signer
+		provider.Certificate = certificate
+	}
+
+	samlProvider = provider
+	return samlProvider, nil
+}
+
+// samlAttribute returns the first value of the attribute with the name or friendly name.
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if (strings.EqualFold(attribute.Name, name) || strings.EqualFold(attribute.FriendlyName, name)) && len(attribute.Values) > 0 {
+				return strings.TrimSpace(attribute.Values[0].Value)
+			}
+		}
+	}
+	return ""
+}
+
+// loadSamlProvider returns the service provider, or NotFound when saml is not configured.
+func loadSamlProvider(cfg *common.Config, logger *common.Logger) (*saml.ServiceProvider, *api.ApiError) {
+	if !SamlEnabled(cfg) {
+		logger.PrintfWarning("Saml is not configured")
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	provider, err := getSamlProvider(cfg)
+	if err != nil {
+		logger.PrintfError("Could not load saml service provider: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusBadGateway,
+			Error: enum.ApiError,
+		}
+	}
+
+	return provider, nil
+}
+
+// GetSamlMetadata returns the service provider metadata to register with the idp.
+func GetSamlMetadata(cfg *common.Config, logger *common.Logger) (*saml.EntityDescriptor, *api.ApiError) {
+	provider, apiErr := loadSamlProvider(cfg, logger)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	return provider.Metadata(), nil
+}
+
+// GetSamlRedirectURL creates an authentication request and returns the idp URL to send the user to,
+// along with the request id that the response has to refer to.
+func GetSamlRedirectURL(cfg *common.Config, logger *common.Logger) (string, string, *api.ApiError) {
+	provider, apiErr := loadSamlProvider(cfg, logger)
+	if apiErr != nil {
+		return "", "", apiErr
+	}
+
+	request, err := provider.MakeAuthenticationRequest(provider.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		logger.PrintfError("Could not create saml authentication request: %s", err)
+		return "", "", &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	redirectURL, err := request.Redirect("", provider)
+	if err != nil {
+		logger.PrintfError("Could not create saml redirect: %s", err)
+		return "", "", &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return redirectURL.String(), request.ID, nil
+}
+
+// SamlLoginService verifies the idp response to requestId, maps the assertion to a user (creating one if needed)
+// and issues the same token pair as a password login. Identities are linked by their name id like oauth accounts,
+// existing accounts with the same email only within SAML_LINK_DOMAINS.
+func SamlLoginService(db *gorm.DB, cfg *common.Config, req *http.Request, requestId string, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
+	provider, apiErr := loadSamlProvider(cfg, logger)
+	if apiErr != nil {
+		return JWTPair{}, apiErr
+	}
+
+	assertion, err := provider.ParseResponse(req, []string{requestId})
+	if err != nil {
+		// the reason is only in the private error of the library
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		logger.PrintfWarning("Rejected saml response: %s", err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		logger.PrintfWarning("Saml assertion has no name id")
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+	subject := assertion.Subject.NameID.Value
+
+	email := samlAttribute(assertion, cfg.SamlEmailAttribute)
+	if email == "" && api.Validate.Var(subject, "email") == nil {
+		email = subject
+	}
+	if api.Validate.Var(email, "required,email") != nil {
+		logger.PrintfWarning("Saml identity %s has no email", subject)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.EmailNotVerified,
+		}
+	}
+
+	name := samlAttribute(assertion, cfg.SamlNameAttribute)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	if len(name) > 50 {
+		name = name[:50]
+	}
+
+	var user database.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var account database.OAuthAccount
+		err := tx.Preload("User").Where("provider = ? AND subject = ?", samlProviderName, subject).First(&account).Error
+		if err == nil {
+			user = account.User
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// the idp verified the identity, so the email counts as verified
+		err = tx.Where("email = ?", email).First(&user).Error
+		if err == nil && !linksDomain(cfg.SamlLinkDomains, email) {
+			return errAccountExists
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = database.User{
+				Email:         email,
+				EmailVerified: true,
+				Name:          name,
+			}
+			err = tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&database.OAuthAccount{
+			Provider: samlProviderName,
+			Subject:  subject,
+			UserId:   user.Id,
+		}).Error
+	})
+	if errors.Is(err, errAccountExists) {
+		logger.PrintfWarning("Saml identity %s matches an existing account outside of the linked domains", subject)
+		return JWTPair{}, &api.ApiError{
+			Code:    http.StatusConflict,
+			Error:   enum.AlreadyExists,
+			Details: "An account with this email already exists",
+		}
+	}
+	if err != nil {
+		logger.PrintfError("Error linking saml identity %s: %s", subject, err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.PrintfInfo("User: %s authenticated with saml", user.Id)
+
+	return completeLogin(db, cfg, &user, client, cfg.RefreshExpirationTime, logger)
+}
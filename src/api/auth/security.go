@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"easyflow-backend/src/enum"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SecurityEventHandler is notified after a security sensitive change of a user, e.g. to close its live connections.
+type SecurityEventHandler func(userId string, action enum.AuditAction)
+
+var securityEventHandlers []SecurityEventHandler
+
+// OnSecurityEvent registers a handler for security events. Handlers are registered at startup only.
+func OnSecurityEvent(handler SecurityEventHandler) {
+	securityEventHandlers = append(securityEventHandlers, handler)
+}
+
+// SecurityEvent ends every session of the user except the one with the refresh random current and notifies the
+// registered handlers. It is called in the transaction of the change, so the change and the ended sessions are
+// rolled back together. Handlers run before the commit and must not assume the change is stored.
+func SecurityEvent(tx *gorm.DB, userId string, action enum.AuditAction, current *uuid.UUID) error {
+	sessions := tx.Where("user_id = ?", userId)
+	if current != nil {
+		sessions = sessions.Where("random <> ?", current.String())
+	}
+
+	if _, err := EndSessions(tx, sessions); err != nil {
+		return err
+	}
+
+	for _, handler := range securityEventHandlers {
+		handler(userId, action)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GetSessionsService lists all active refresh sessions of the user.
+// The session the request was made with is marked as current.
+func GetSessionsService(db *gorm.DB, payload *JWTAccessTokenPayload, logger *common.Logger) ([]SessionResponse, *api.ApiError) {
+	var keys []database.UserKeys
+	if err := db.Where("user_id = ? AND expired_at > ?", payload.UserId, time.Now()).Order("last_used_at DESC").Find(&keys).Error; err != nil {
+		logger.PrintfError("Could not get sessions: %s", err)
+		return nil, &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	sessions := make([]SessionResponse, 0, len(keys))
+	for _, key := range keys {
+		sessions = append(sessions, SessionResponse{
+			Id:         key.Id,
+			CreatedAt:  key.CreatedAt,
+			LastUsedAt: key.LastUsedAt,
+			ExpiredAt:  key.ExpiredAt,
+			IP:         key.IP,
+			UserAgent:  key.UserAgent,
+			Current:    payload.RefreshRand != nil && key.Random == payload.RefreshRand.String(),
+		})
+	}
+
+	return sessions, nil
+}
+
+// RevokeSessionService ends a single session of the user.
+// The device loses access once its current access token expires.
+func RevokeSessionService(db *gorm.DB, payload *JWTAccessTokenPayload, sessionId string, client common.ClientInfo, logger *common.Logger) *api.ApiError {
+	ended, err := EndSessions(db, db.Where("id = ? AND user_id = ?", sessionId, payload.UserId))
+	if err != nil {
+		logger.PrintfError("Could not revoke session %s: %s", sessionId, err)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	if ended == 0 {
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	audit.Record(db, logger, payload.UserId, enum.SessionRevoked, client, &sessionId)
+
+	logger.Printf("Revoked session %s", sessionId)
+
+	return nil
+}
+
+// LogoutAllService ends every session of the user, including the current one.
+func LogoutAllService(db *gorm.DB, payload *JWTAccessTokenPayload, client common.ClientInfo, logger *common.Logger) *api.ApiError {
+	ended, err := EndSessions(db, db.Where("user_id = ?", payload.UserId))
+	if err != nil {
+		logger.PrintfError("Could not end sessions of user: %s. Error: %s", payload.UserId, err)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	details := "all"
+	audit.Record(db, logger, payload.UserId, enum.SessionRevoked, client, &details)
+
+	logger.Printf("Ended %d sessions of user: %s", ended, payload.UserId)
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"easyflow-backend/src/common"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// UploadTokenPayload binds a presigned upload to one user, object, size limit and content type.
+// The jti is the nonce of the upload, it can be confirmed once.
+type UploadTokenPayload struct {
+	jwt.RegisteredClaims
+	UserId      string `json:"userId"`
+	Bucket      string `json:"bucket"`
+	ObjectKey   string `json:"objectKey"`
+	MaxSize     int64  `json:"maxSize"`
+	ContentType string `json:"contentType"`
+	// base64 SHA-256 declared by the client, optional
+	Checksum *string `json:"checksum,omitempty"`
+}
+
+// upload tokens have their own audience, so ValidateToken does not accept them as access tokens
+func uploadAudience(cfg *common.Config) string {
+	return cfg.JwtAudience + "/upload"
+}
+
+// IssueUploadToken signs payload with the nonce as jti, valid for lifetime seconds.
+func IssueUploadToken(cfg *common.Config, nonce string, payload UploadTokenPayload, lifetime int) (string, error) {
+	payload.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        nonce,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(lifetime) * time.Second)),
+		Issuer:    cfg.JwtIssuer,
+		Audience:  jwt.ClaimStrings{uploadAudience(cfg)},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
+	return generateJwt(cfg, &payload)
+}
+
+func ValidateUploadToken(cfg *common.Config, token string) (*UploadTokenPayload, error) {
+	var claims UploadTokenPayload
+	_, err := jwt.ParseWithClaims(token, &claims, keyFunc(cfg),
+		jwt.WithIssuer(cfg.JwtIssuer),
+		jwt.WithAudience(uploadAudience(cfg)),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUploadToken(t *testing.T) {
+	cfg := testConfig()
+	checksum := "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg="
+
+	token, err := IssueUploadToken(cfg, "nonce", UploadTokenPayload{
+		UserId:      "user",
+		Bucket:      "bucket",
+		ObjectKey:   "user/object",
+		MaxSize:     1024,
+		ContentType: "image/png",
+		Checksum:    &checksum,
+	}, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := ValidateUploadToken(cfg, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != "nonce" || payload.UserId != "user" || payload.ObjectKey != "user/object" ||
+		payload.MaxSize != 1024 || payload.ContentType != "image/png" || payload.Checksum == nil || *payload.Checksum != checksum {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+
+	// the audiences keep upload and access tokens apart
+	if _, err := ValidateToken(cfg, token, TokenTypeAccess); err == nil {
+		t.Error("expected an upload token to be rejected as access token")
+	}
+	if _, err := ValidateUploadToken(cfg, testToken(t, cfg, TokenTypeAccess, "jti", 10*time.Minute)); err == nil {
+		t.Error("expected an access token to be rejected as upload token")
+	}
+
+	expired, err := IssueUploadToken(cfg, "nonce", UploadTokenPayload{UserId: "user"}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateUploadToken(cfg, expired); err == nil {
+		t.Error("expected an expired upload token to be rejected")
+	}
+}
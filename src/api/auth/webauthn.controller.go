@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"bytes"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const webAuthnSessionCookie = "webauthn_session"
+
+func setWebAuthnSessionCookie(c *gin.Context, cfg *common.Config, sessionId string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(webAuthnSessionCookie, sessionId, int(webAuthnSessionTimeout.Seconds()), "/auth/webauthn", cfg.Domain, cfg.Stage == "production", true)
+}
+
+func clearWebAuthnSessionCookie(c *gin.Context, cfg *common.Config) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(webAuthnSessionCookie, "", -1, "/auth/webauthn", cfg.Domain, cfg.Stage == "production", true)
+}
+
+// readRawBody reads the request body and puts it back so it can still be bound afterwards.
+// The webauthn responses have to be parsed from the raw body.
+func readRawBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func BeginWebAuthnRegistrationController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	creation, sessionId, err := BeginWebAuthnRegistration(db, cfg, user.(*JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	setWebAuthnSessionCookie(c, cfg, sessionId)
+	c.JSON(http.StatusOK, creation)
+}
+
+func FinishWebAuthnRegistrationController(c *gin.Context) {
+	body, e := readRawBody(c)
+	if e != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	_, logger, db, cfg, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	sessionId, _ := c.Cookie(webAuthnSessionCookie)
+	clearWebAuthnSessionCookie(c, cfg)
+
+	err := FinishWebAuthnRegistration(db, cfg, sessionId, c.Query("name"), body, user.(*JWTAccessTokenPayload), common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{})
+}
+
+func BeginWebAuthnLoginController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[WebAuthnLoginRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	assertion, sessionId, err := BeginWebAuthnLogin(db, cfg, payload, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	setWebAuthnSessionCookie(c, cfg, sessionId)
+	c.JSON(http.StatusOK, assertion)
+}
+
+func FinishWebAuthnLoginController(c *gin.Context) {
+	body, e := readRawBody(c)
+	if e != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	_, logger, db, cfg, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	sessionId, _ := c.Cookie(webAuthnSessionCookie)
+	clearWebAuthnSessionCookie(c, cfg)
+
+	tokens, err := FinishWebAuthnLogin(db, cfg, sessionId, body, common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	WriteTokens(c, cfg, tokens)
+}
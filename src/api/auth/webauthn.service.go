@@ -0,0 +1,375 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ceremonies have to be finished within this time
+const webAuthnSessionTimeout = 5 * time.Minute
+
+type webAuthnUser struct {
+	user        *database.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.Id)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+type webAuthnSession struct {
+	data      webauthn.SessionData
+	userId    string
+	expiresAt time.Time
+}
+
+var webAuthnSessions = make(map[string]*webAuthnSession)
+var webAuthnSessionsMutex sync.Mutex
+
+func storeWebAuthnSession(userId string, data *webauthn.SessionData) string {
+	webAuthnSessionsMutex.Lock()
+	defer webAuthnSessionsMutex.Unlock()
+
+	// drop finished or abandoned ceremonies
+	for id, session := range webAuthnSessions {
+		if time.Now().After(session.expiresAt) {
+			delete(webAuthnSessions, id)
+		}
+	}
+
+	id := uuid.NewString()
+	webAuthnSessions[id] = &webAuthnSession{
+		data:      *data,
+		userId:    userId,
+		expiresAt: time.Now().Add(webAuthnSessionTimeout),
+	}
+	return id
+}
+
+// takeWebAuthnSession returns and removes the session so every challenge can only be answered once.
+func takeWebAuthnSession(id string) (*webAuthnSession, bool) {
+	webAuthnSessionsMutex.Lock()
+	defer webAuthnSessionsMutex.Unlock()
+
+	session, ok := webAuthnSessions[id]
+	if !ok {
+		return nil, false
+	}
+	delete(webAuthnSessions, id)
+
+	if time.Now().After(session.expiresAt) {
+		return nil, false
+	}
+	return session, true
+}
+
+func newWebAuthn(cfg *common.Config) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnRPOrigins,
+	})
+}
+
+func loadWebAuthnUser(db *gorm.DB, user *database.User) (*webAuthnUser, []database.WebAuthnCredential, error) {
+	var stored []database.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.Id).Find(&stored).Error; err != nil {
+		return nil, nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, entry := range stored {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(entry.Data), &credential); err != nil {
+			return nil, nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, stored, nil
+}
+
+func webAuthnError(logger *common.Logger, message string, err error) *api.ApiError {
+	logger.PrintfError("%s: %s", message, err)
+	return &api.ApiError{
+		Code:  http.StatusInternalServerError,
+		Error: enum.ApiError,
+	}
+}
+
+// BeginWebAuthnRegistration creates the credential creation options for the logged in user.
+// The returned session id has to be sent back when finishing the registration.
+func BeginWebAuthnRegistration(db *gorm.DB, cfg *common.Config, jwtPayload *JWTAccessTokenPayload, logger *common.Logger) (*protocol.CredentialCreation, string, *api.ApiError) {
+	var user database.User
+	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
+		logger.PrintfError("Error getting user: %s", err)
+		return nil, "", &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.UserNotFound,
+		}
+	}
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return nil, "", webAuthnError(logger, "Invalid webauthn configuration", err)
+	}
+
+	waUser, _, err := loadWebAuthnUser(db, &user)
+	if err != nil {
+		return nil, "", webAuthnError(logger, "Error loading webauthn credentials", err)
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(waUser.credentials))
+	for _, credential := range waUser.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := w.BeginRegistration(waUser,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, "", webAuthnError(logger, "Error beginning webauthn registration", err)
+	}
+
+	logger.PrintfInfo("Started passkey registration for user: %s", user.Id)
+
+	return creation, storeWebAuthnSession(user.Id, session), nil
+}
+
+// FinishWebAuthnRegistration verifies the attestation and stores the new credential.
+func FinishWebAuthnRegistration(db *gorm.DB, cfg *common.Config, sessionId string, name string, body []byte, jwtPayload *JWTAccessTokenPayload, client common.ClientInfo, logger *common.Logger) *api.ApiError {
+	session, ok := takeWebAuthnSession(sessionId)
+	if !ok || session.userId != jwtPayload.UserId {
+		logger.PrintfWarning("Invalid or expired webauthn registration session")
+		return &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidWebAuthnSession,
+		}
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(body))
+	if err != nil {
+		logger.PrintfWarning("Could not parse webauthn registration response: %s", err)
+		return &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		}
+	}
+
+	var user database.User
+	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
+		logger.PrintfError("Error getting user: %s", err)
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.UserNotFound,
+		}
+	}
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return webAuthnError(logger, "Invalid webauthn configuration", err)
+	}
+
+	waUser, _, err := loadWebAuthnUser(db, &user)
+	if err != nil {
+		return webAuthnError(logger, "Error loading webauthn credentials", err)
+	}
+
+	credential, err := w.CreateCredential(waUser, session.data, parsed)
+	if err != nil {
+		logger.PrintfWarning("Webauthn registration for user: %s failed: %s", user.Id, err)
+		return &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.WebAuthnFailed,
+		}
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return webAuthnError(logger, "Error encoding webauthn credential", err)
+	}
+
+	entry := database.WebAuthnCredential{
+		Name:         name,
+		CredentialId: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Data:         string(data),
+		UserId:       user.Id,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return webAuthnError(logger, "Error saving webauthn credential", err)
+	}
+
+	audit.Record(db, logger, user.Id, enum.PasskeyRegistered, client, &entry.Name)
+
+	logger.Printf("Registered passkey: %s for user: %s", entry.Id, user.Id)
+
+	return nil
+}
+
+// key of the credential ids of dummy assertions, the ids only have to be stable while the process runs
+var dummyCredentialKey []byte
+var dummyCredentialKeyOnce sync.Once
+
+// dummyWebAuthnUser stands in for unknown emails and users without passkeys. Its credential id is derived from the
+// email, so repeated requests get the same answer as for a real user with one passkey.
+func dummyWebAuthnUser(email string) *webAuthnUser {
+	dummyCredentialKeyOnce.Do(func() {
+		dummyCredentialKey = make([]byte, 32)
+		rand.Read(dummyCredentialKey)
+	})
+
+	mac := hmac.New(sha256.New, dummyCredentialKey)
+	mac.Write([]byte(strings.ToLower(email)))
+
+	return &webAuthnUser{
+		user:        &database.User{Email: email, Name: email},
+		credentials: []webauthn.Credential{{ID: mac.Sum(nil)}},
+	}
+}
+
+// BeginWebAuthnLogin creates the assertion options for the passkeys of the user with the given email.
+// Unknown emails and users without passkeys get options for a dummy passkey, the login fails when it is finished.
+func BeginWebAuthnLogin(db *gorm.DB, cfg *common.Config, payload *WebAuthnLoginRequest, logger *common.Logger) (*protocol.CredentialAssertion, string, *api.ApiError) {
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return nil, "", webAuthnError(logger, "Invalid webauthn configuration", err)
+	}
+
+	var waUser *webAuthnUser
+	var user database.User
+	if err := db.Where("email = ?", payload.Email).First(&user).Error; err != nil {
+		logger.PrintfWarning("User with email: %s not found", payload.Email)
+	} else if waUser, _, err = loadWebAuthnUser(db, &user); err != nil {
+		return nil, "", webAuthnError(logger, "Error loading webauthn credentials", err)
+	} else if len(waUser.credentials) == 0 {
+		logger.PrintfWarning("User: %s has no passkeys registered", user.Id)
+		waUser = nil
+	}
+
+	// the session of a dummy assertion has no user, finishing it fails like a wrong passkey
+	userId := ""
+	if waUser == nil {
+		waUser = dummyWebAuthnUser(payload.Email)
+	} else {
+		userId = user.Id
+	}
+
+	assertion, session, err := w.BeginLogin(waUser)
+	if err != nil {
+		return nil, "", webAuthnError(logger, "Error beginning webauthn login", err)
+	}
+
+	return assertion, storeWebAuthnSession(userId, session), nil
+}
+
+// FinishWebAuthnLogin verifies the assertion and logs the user in like a password login.
+func FinishWebAuthnLogin(db *gorm.DB, cfg *common.Config, sessionId string, body []byte, client common.ClientInfo, logger *common.Logger) (JWTPair, *api.ApiError) {
+	session, ok := takeWebAuthnSession(sessionId)
+	if !ok {
+		logger.PrintfWarning("Invalid or expired webauthn login session")
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidWebAuthnSession,
+		}
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(body))
+	if err != nil {
+		logger.PrintfWarning("Could not parse webauthn login response: %s", err)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		}
+	}
+
+	var user database.User
+	if session.userId == "" {
+		logger.PrintfWarning("Webauthn login with a dummy passkey")
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+	if err := db.Where("id = ?", session.userId).First(&user).Error; err != nil {
+		logger.PrintfWarning("Could not get user with id: %s", session.userId)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+
+	w, err := newWebAuthn(cfg)
+	if err != nil {
+		return JWTPair{}, webAuthnError(logger, "Invalid webauthn configuration", err)
+	}
+
+	waUser, stored, err := loadWebAuthnUser(db, &user)
+	if err != nil {
+		return JWTPair{}, webAuthnError(logger, "Error loading webauthn credentials", err)
+	}
+
+	credential, err := w.ValidateLogin(waUser, session.data, parsed)
+	if err != nil {
+		logger.PrintfWarning("Webauthn login for user: %s failed: %s", user.Id, err)
+		audit.Record(db, logger, user.Id, enum.LoginFailed, client, nil)
+		return JWTPair{}, &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+
+	// persist the new sign count so cloned authenticators can be detected
+	credentialId := base64.RawURLEncoding.EncodeToString(credential.ID)
+	for _, entry := range stored {
+		if entry.CredentialId != credentialId {
+			continue
+		}
+
+		data, err := json.Marshal(credential)
+		if err != nil {
+			return JWTPair{}, webAuthnError(logger, "Error encoding webauthn credential", err)
+		}
+
+		now := time.Now()
+		if err := db.Model(&entry).Updates(database.WebAuthnCredential{Data: string(data), LastUsedAt: &now}).Error; err != nil {
+			logger.PrintfWarning("Could not update passkey: %s. Error: %s", entry.Id, err)
+		}
+	}
+
+	if credential.Authenticator.CloneWarning {
+		logger.PrintfWarning("Possible cloned authenticator used for user: %s", user.Id)
+	}
+
+	return completeLogin(db, cfg, &user, client, cfg.RefreshExpirationTime, logger)
+}
@@ -0,0 +1,398 @@
+package chat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"easyflow-backend/src/api/s3"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// number of archived messages deleted from the messages table per query
+const archiveDeleteBatchSize = 1000
+
+// named lock that keeps archivers of several instances from rewriting the same objects
+const archiveLockName = "easyflow_message_archiver"
+
+// decoded archives kept in memory, chats with few live messages read their latest archive whenever they are opened
+const archiveCacheSize = 64
+
+type archiveCacheEntry struct {
+	// an archive that was rewritten since it was cached has another update time
+	updatedAt time.Time
+	messages  []archivedMessage
+}
+
+var archiveCache = make(map[string]*archiveCacheEntry)
+var archiveCacheMutex sync.Mutex
+
+// archivedMessage is a message as it is stored in an archive object
+type archivedMessage struct {
+	Id              string    `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+	Content         string    `json:"content"`
+	Iv              string    `json:"iv"`
+	SenderId        string    `json:"senderId"`
+	ClientMessageId *string   `json:"clientMessageId,omitempty"`
+	Imported        bool      `json:"imported,omitempty"`
+	ImportedBy      *string   `json:"importedBy,omitempty"`
+}
+
+func (m *archivedMessage) toMessage() database.Message {
+	return database.Message{
+		Id:              m.Id,
+		CreatedAt:       m.CreatedAt,
+		UpdatedAt:       m.UpdatedAt,
+		Content:         m.Content,
+		Iv:              m.Iv,
+		SenderId:        m.SenderId,
+		ClientMessageId: m.ClientMessageId,
+		Imported:        m.Imported,
+		ImportedBy:      m.ImportedBy,
+	}
+}
+
+// after reports whether the message comes after the cursor in (created_at, id) order
+func (m *archivedMessage) after(cursor *database.Message) bool {
+	if cursor == nil || m.CreatedAt.After(cursor.CreatedAt) {
+		return true
+	}
+	return m.CreatedAt.Equal(cursor.CreatedAt) && m.Id > cursor.Id
+}
+
+// StartMessageArchiver periodically moves messages older than MESSAGE_ARCHIVE_AFTER to the archive bucket.
+// Archiving is disabled without a bucket.
+func StartMessageArchiver(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	if cfg.MessageArchiveBucketName == "" || cfg.MessageArchiveAfter <= 0 {
+		return
+	}
+
+	go func() {
+		withLock(db, archiveLockName, logger, func() {
+			indexArchives(db, cfg, logger)
+		})
+
+		ticker := time.NewTicker(time.Duration(cfg.MessageArchiveInterval) * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			withLock(db, archiveLockName, logger, func() {
+				archiveMessages(db, cfg, logger)
+			})
+		}
+	}()
+}
+
+// withLock runs fn unless a job of another instance holds the named lock.
+// GET_LOCK belongs to a connection, so the lock is taken and released on one pinned connection.
+func withLock(db *gorm.DB, name string, logger *common.Logger, fn func()) {
+	err := db.Connection(func(conn *gorm.DB) error {
+		var locked int
+		if err := conn.Raw("SELECT COALESCE(GET_LOCK(?, 0), 0)", name).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if locked != 1 {
+			logger.PrintfDebug("Lock %s is held by another instance, skipping this run", name)
+			return nil
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", name)
+
+		fn()
+		return nil
+	})
+	if err != nil {
+		logger.PrintfError("Could not take lock %s: %s", name, err)
+	}
+}
+
+// indexArchives adds the messages of archives written before the message index existed to the index.
+func indexArchives(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	var archives []database.MessageArchive
+	if err := db.Where("messages <> (SELECT COUNT(*) FROM archived_messages " +
+		"WHERE archived_messages.chat_id = message_archives.chat_id AND archived_messages.month = message_archives.month)").
+		Find(&archives).Error; err != nil {
+		logger.PrintfError("Could not get unindexed message archives: %s", err)
+		return
+	}
+
+	for i := range archives {
+		messages, err := loadArchive(cfg, &archives[i], logger)
+		if err == nil {
+			err = indexMessages(db, archives[i].ChatId, archives[i].Month, messages)
+		}
+		if err != nil {
+			logger.PrintfError("Could not index message archive %s. Error: %s", archives[i].ObjectKey, err)
+		}
+	}
+
+	if len(archives) > 0 {
+		logger.Printf("Indexed %d message archives", len(archives))
+	}
+}
+
+// indexMessages records the archive month of the messages, messages that are already indexed are skipped.
+func indexMessages(db *gorm.DB, chatId string, month string, messages []archivedMessage) error {
+	for batch := range slices.Chunk(messages, archiveDeleteBatchSize) {
+		rows := make([]database.ArchivedMessage, 0, len(batch))
+		for _, message := range batch {
+			rows = append(rows, database.ArchivedMessage{Id: message.Id, ChatId: chatId, Month: month})
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveMessages archives whole months only, so every archive object covers exactly one month of a chat.
+func archiveMessages(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	cutoff := time.Now().UTC().Add(-time.Duration(cfg.MessageArchiveAfter) * time.Second)
+	end := time.Date(cutoff.Year(), cutoff.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var months []struct {
+		ChatId string
+		Month  string
+	}
+	if err := db.Model(&database.Message{}).
+		Select("chat_id, DATE_FORMAT(created_at, '%Y-%m') AS month").
+		Where("created_at < ? AND expires_at IS NULL", end).
+		Group("chat_id, month").
+		Scan(&months).Error; err != nil {
+		logger.PrintfError("Could not get messages to archive: %s", err)
+		return
+	}
+
+	for _, month := range months {
+		archived, err := archiveMonth(db, cfg, month.ChatId, month.Month, logger)
+		if err != nil {
+			logger.PrintfError("Could not archive messages of chat: %s in %s. Error: %s", month.ChatId, month.Month, err)
+			continue
+		}
+
+		invalidateStats(month.ChatId)
+		logger.Printf("Archived %d messages of chat: %s in %s", archived, month.ChatId, month.Month)
+	}
+}
+
+// archiveMonth merges the messages of the chat in month into its archive object and deletes them from the table.
+// The object is written before the rows are deleted, a failed run leaves the rows in place and merges them again next time.
+func archiveMonth(db *gorm.DB, cfg *common.Config, chatId string, month string, logger *common.Logger) (int, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return 0, err
+	}
+
+	var messages []database.Message
+	// self destructing messages stay in the table until the expiry job deletes them
+	if err := db.Where("chat_id = ? AND created_at >= ? AND created_at < ? AND expires_at IS NULL", chatId, start, start.AddDate(0, 1, 0)).Find(&messages).Error; err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	archive := database.MessageArchive{
+		ChatId:    chatId,
+		Month:     month,
+		ObjectKey: fmt.Sprintf("%s/%s.json.gz", chatId, month),
+	}
+
+	var archived []archivedMessage
+	var existing database.MessageArchive
+	if err := db.Where("chat_id = ? AND month = ?", chatId, month).First(&existing).Error; err == nil {
+		archived, err = loadArchive(cfg, &existing, logger)
+		if err != nil {
+			return 0, err
+		}
+		// the decoded archive is shared with the cache
+		archived = slices.Clone(archived)
+	}
+
+	// retried imports can add a message again after the first copy was archived
+	stored := map[string]bool{}
+	for _, message := range archived {
+		stored[message.Id] = true
+		if message.ClientMessageId != nil {
+			stored[message.SenderId+"/"+*message.ClientMessageId] = true
+		}
+	}
+
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.Id)
+		if stored[message.Id] || (message.ClientMessageId != nil && stored[message.SenderId+"/"+*message.ClientMessageId]) {
+			continue
+		}
+
+		archived = append(archived, archivedMessage{
+			Id:              message.Id,
+			CreatedAt:       message.CreatedAt,
+			UpdatedAt:       message.UpdatedAt,
+			Content:         message.Content,
+			Iv:              message.Iv,
+			SenderId:        message.SenderId,
+			ClientMessageId: message.ClientMessageId,
+			Imported:        message.Imported,
+			ImportedBy:      message.ImportedBy,
+		})
+	}
+
+	slices.SortFunc(archived, func(a, b archivedMessage) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(archived); err != nil {
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := s3.UploadObject(logger, cfg, cfg.MessageArchiveBucketName, archive.ObjectKey, buf.Bytes(), "application/gzip"); err != nil {
+		return 0, fmt.Errorf("upload failed: %v", err.Details)
+	}
+
+	archive.Messages = len(archived)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&archive).Error; err != nil {
+			return err
+		}
+
+		if err := indexMessages(tx, chatId, month, archived); err != nil {
+			return err
+		}
+
+		for batch := range slices.Chunk(ids, archiveDeleteBatchSize) {
+			if err := tx.Where("id IN ?", batch).Delete(&database.Message{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return len(ids), err
+}
+
+// loadArchive downloads and decodes the messages of an archive object, oldest first.
+// The result is shared with the archive cache and must not be modified.
+func loadArchive(cfg *common.Config, archive *database.MessageArchive, logger *common.Logger) ([]archivedMessage, error) {
+	archiveCacheMutex.Lock()
+	entry, ok := archiveCache[archive.ObjectKey]
+	archiveCacheMutex.Unlock()
+	if ok && entry.updatedAt.Equal(archive.UpdatedAt) {
+		return entry.messages, nil
+	}
+
+	content, apiErr := s3.GetObject(logger, cfg, cfg.MessageArchiveBucketName, archive.ObjectKey)
+	if apiErr != nil {
+		return nil, fmt.Errorf("download of %s failed: %v", archive.ObjectKey, apiErr.Details)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []archivedMessage
+	if err := json.Unmarshal(decompressed, &messages); err != nil {
+		return nil, err
+	}
+
+	archiveCacheMutex.Lock()
+	defer archiveCacheMutex.Unlock()
+
+	// evicts an arbitrary entry, the cache only has to bound the memory
+	if _, ok := archiveCache[archive.ObjectKey]; !ok && len(archiveCache) >= archiveCacheSize {
+		for key := range archiveCache {
+			delete(archiveCache, key)
+			break
+		}
+	}
+	archiveCache[archive.ObjectKey] = &archiveCacheEntry{updatedAt: archive.UpdatedAt, messages: messages}
+
+	return messages, nil
+}
+
+// getArchives returns the archive objects of the chat, oldest first.
+func getArchives(db *gorm.DB, chatId string) ([]database.MessageArchive, error) {
+	var archives []database.MessageArchive
+	err := db.Where("chat_id = ?", chatId).Order("month").Find(&archives).Error
+	return archives, err
+}
+
+// findArchivedMessage looks up a message that is no longer in the messages table.
+// Only the archive of the month the index points to is read.
+func findArchivedMessage(db *gorm.DB, cfg *common.Config, chatId string, messageId string, logger *common.Logger) (*database.Message, error) {
+	var archive database.MessageArchive
+	err := db.Where("chat_id = ? AND month = (?)", chatId,
+		db.Model(&database.ArchivedMessage{}).Select("month").Where("id = ? AND chat_id = ?", messageId, chatId)).
+		First(&archive).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := loadArchive(cfg, &archive, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range messages {
+		if message.Id == messageId {
+			found := message.toMessage()
+			return &found, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// latestArchivedMessages returns up to limit of the newest archived messages of the chat, newest first.
+// Archives that cannot be read are skipped, the chat is still usable without its old messages.
+func latestArchivedMessages(db *gorm.DB, cfg *common.Config, chatId string, limit int, logger *common.Logger) []database.Message {
+	archives, err := getArchives(db, chatId)
+	if err != nil {
+		logger.PrintfError("Error getting message archives of chat: %s. Error: %s", chatId, err)
+		return nil
+	}
+
+	result := []database.Message{}
+	for i := len(archives) - 1; i >= 0 && len(result) < limit; i-- {
+		messages, err := loadArchive(cfg, &archives[i], logger)
+		if err != nil {
+			logger.PrintfWarning("Could not read message archive of chat: %s. Error: %s", chatId, err)
+			continue
+		}
+
+		for j := len(messages) - 1; j >= 0 && len(result) < limit; j-- {
+			result = append(result, messages[j].toMessage())
+		}
+	}
+
+	return result
+}
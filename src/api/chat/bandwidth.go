@@ -0,0 +1,193 @@
+package chat
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errStreamBandwidthUsedUp = errors.New("daily stream bandwidth is used up")
+
+type bandwidthKey struct {
+	userId string
+	day    string
+}
+
+type dailyBandwidth struct {
+	day   string
+	bytes int64
+}
+
+// stream bandwidth is counted in memory and written to the database by flushStreamBandwidth,
+// daily totals are kept per user so the cap is not checked against the database on every batch.
+var pendingBandwidth = make(map[bandwidthKey]int64)
+var dailyBandwidthTotals = make(map[string]*dailyBandwidth)
+var bandwidthMutex sync.Mutex
+
+func bandwidthDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// streamBandwidthUsedUp reports whether the user reached STREAM_DAILY_BANDWIDTH today.
+func streamBandwidthUsedUp(db *gorm.DB, cfg *common.Config, userId string) (bool, error) {
+	if cfg.StreamDailyBandwidth <= 0 {
+		return false, nil
+	}
+
+	day := bandwidthDay(time.Now())
+
+	bandwidthMutex.Lock()
+	total, ok := dailyBandwidthTotals[userId]
+	bandwidthMutex.Unlock()
+
+	if !ok || total.day != day {
+		var bytes int64
+		if err := db.Model(&database.StreamBandwidth{}).Where("user_id = ? AND day = ?", userId, day).
+			Select("COALESCE(SUM(bytes), 0)").Scan(&bytes).Error; err != nil {
+			return false, err
+		}
+
+		bandwidthMutex.Lock()
+		bytes += pendingBandwidth[bandwidthKey{userId: userId, day: day}]
+		total = &dailyBandwidth{day: day, bytes: bytes}
+		dailyBandwidthTotals[userId] = total
+		bandwidthMutex.Unlock()
+	}
+
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+	return total.bytes >= cfg.StreamDailyBandwidth, nil
+}
+
+// checkStreamBandwidth refuses a stream when the user used up the daily bandwidth.
+func checkStreamBandwidth(db *gorm.DB, cfg *common.Config, userId string, logger *common.Logger) *api.ApiError {
+	usedUp, err := streamBandwidthUsedUp(db, cfg, userId)
+	if err != nil {
+		logger.PrintfError("Could not get stream bandwidth of user: %s. Error: %s", userId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if usedUp {
+		return &api.ApiError{
+			Code:    http.StatusTooManyRequests,
+			Error:   enum.QuotaExceeded,
+			Details: "Daily stream bandwidth is used up, use GET /chat/:chatId instead",
+		}
+	}
+
+	return nil
+}
+
+// recordStreamBandwidth counts bytes streamed to the user.
+func recordStreamBandwidth(userId string, bytes int64) {
+	key := bandwidthKey{userId: userId, day: bandwidthDay(time.Now())}
+
+	bandwidthMutex.Lock()
+	defer bandwidthMutex.Unlock()
+
+	pendingBandwidth[key] += bytes
+	if total, ok := dailyBandwidthTotals[userId]; ok && total.day == key.day {
+		total.bytes += bytes
+	}
+}
+
+// StartStreamBandwidthFlush periodically writes the stream bandwidth counted by this instance to the database.
+func StartStreamBandwidthFlush(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.StreamBandwidthFlushInterval) * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			flushStreamBandwidth(db, logger)
+		}
+	}()
+}
+
+// flushStreamBandwidth adds the pending counts to the database. Counts that fail to write are kept for the next flush.
+func flushStreamBandwidth(db *gorm.DB, logger *common.Logger) {
+	bandwidthMutex.Lock()
+	pending := pendingBandwidth
+	pendingBandwidth = make(map[bandwidthKey]int64)
+	// totals of past days are not needed anymore
+	today := bandwidthDay(time.Now())
+	for userId, total := range dailyBandwidthTotals {
+		if total.day != today {
+			delete(dailyBandwidthTotals, userId)
+		}
+	}
+	bandwidthMutex.Unlock()
+
+	for key, bytes := range pending {
+		row := database.StreamBandwidth{
+			UserId: key.userId,
+			Day:    key.day,
+			Bytes:  bytes,
+		}
+
+		err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"bytes": gorm.Expr("bytes + ?", bytes)}),
+		}).Create(&row).Error
+		if err == nil {
+			continue
+		}
+
+		logger.PrintfError("Could not write stream bandwidth of user %s: %s", key.userId, err)
+
+		bandwidthMutex.Lock()
+		pendingBandwidth[key] += bytes
+		bandwidthMutex.Unlock()
+	}
+}
+
+// GetStreamBandwidth returns the stream bandwidth of the user over the last days, including counts not flushed yet.
+func GetStreamBandwidth(db *gorm.DB, cfg *common.Config, userId string, days int, logger *common.Logger) (*StreamBandwidthResponse, *api.ApiError) {
+	since := bandwidthDay(time.Now().AddDate(0, 0, -days+1))
+
+	var rows []database.StreamBandwidth
+	if err := db.Where("user_id = ? AND day >= ?", userId, since).Find(&rows).Error; err != nil {
+		logger.PrintfError("Could not get stream bandwidth of user: %s. Error: %s", userId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	byDay := make(map[string]int64)
+	for _, row := range rows {
+		byDay[row.Day] += row.Bytes
+	}
+
+	bandwidthMutex.Lock()
+	for key, bytes := range pendingBandwidth {
+		if key.userId == userId && key.day >= since {
+			byDay[key.day] += bytes
+		}
+	}
+	bandwidthMutex.Unlock()
+
+	response := StreamBandwidthResponse{
+		DailyLimit: cfg.StreamDailyBandwidth,
+		Days:       make([]DailyBandwidth, 0, len(byDay)),
+	}
+	for day, bytes := range byDay {
+		response.Days = append(response.Days, DailyBandwidth{Day: day, Bytes: bytes})
+	}
+	sort.Slice(response.Days, func(i, j int) bool {
+		return response.Days[i].Day > response.Days[j].Day
+	})
+
+	return &response, nil
+}
@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+type chatCacheEntry struct {
+	chat      CreateChatResponse
+	users     []UserEntry
+	expiresAt time.Time
+}
+
+var chatCache = make(map[string]*chatCacheEntry)
+var chatCacheMutex sync.Mutex
+
+// returns the cached chat metadata and member list if it has not expired yet.
+func getCachedChat(chatId string) (*chatCacheEntry, bool) {
+	chatCacheMutex.Lock()
+	defer chatCacheMutex.Unlock()
+
+	entry, ok := chatCache[chatId]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(chatCache, chatId)
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func setCachedChat(chatId string, chat CreateChatResponse, users []UserEntry, ttl int) {
+	if ttl <= 0 {
+		return
+	}
+
+	chatCacheMutex.Lock()
+	defer chatCacheMutex.Unlock()
+
+	chatCache[chatId] = &chatCacheEntry{
+		chat:      chat,
+		users:     users,
+		expiresAt: time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+type statsCacheEntry struct {
+	stats     ChatStatsResponse
+	expiresAt time.Time
+}
+
+var statsCache = make(map[string]*statsCacheEntry)
+var statsCacheMutex sync.Mutex
+
+func getCachedStats(chatId string) (*ChatStatsResponse, bool) {
+	statsCacheMutex.Lock()
+	defer statsCacheMutex.Unlock()
+
+	entry, ok := statsCache[chatId]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(statsCache, chatId)
+		return nil, false
+	}
+
+	return &entry.stats, true
+}
+
+func setCachedStats(chatId string, stats ChatStatsResponse, ttl int) {
+	if ttl <= 0 {
+		return
+	}
+
+	statsCacheMutex.Lock()
+	defer statsCacheMutex.Unlock()
+
+	statsCache[chatId] = &statsCacheEntry{
+		stats:     stats,
+		expiresAt: time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+func invalidateStats(chatId string) {
+	statsCacheMutex.Lock()
+	defer statsCacheMutex.Unlock()
+
+	delete(statsCache, chatId)
+}
+
+// InvalidateChat removes a chat from the cache so the next read hits the database.
+func InvalidateChat(chatId string) {
+	chatCacheMutex.Lock()
+	defer chatCacheMutex.Unlock()
+
+	delete(chatCache, chatId)
+}
+
+// InvalidateChatsOfUser removes every cached chat the user is a member of.
+// It has to be called whenever user data that is part of the member list changes.
+func InvalidateChatsOfUser(userId string) {
+	chatCacheMutex.Lock()
+	defer chatCacheMutex.Unlock()
+
+	for chatId, entry := range chatCache {
+		for _, user := range entry.users {
+			if user.Id == userId {
+				delete(chatCache, chatId)
+				break
+			}
+		}
+	}
+}
@@ -1,27 +1,49 @@
 package chat
 
 import (
+	"crypto/sha256"
 	"easyflow-backend/src/api"
 	"easyflow-backend/src/api/auth"
 	"easyflow-backend/src/common"
 	"easyflow-backend/src/enum"
 	"easyflow-backend/src/middleware"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterChatEndpoints(r *gin.RouterGroup) {
-	r.Use(auth.AuthGuard())
 	r.Use(middleware.LoggerMiddleware("Chat"))
+	r.Use(auth.AuthGuard())
+	r.Use(auth.VerifiedGuard())
 	r.Use(middleware.RateLimiter(1, 5))
 	r.POST("", CreateChatController)
 	r.GET("/preview", GetChatPreviewsController)
+	r.GET("/discover", DiscoverChatsController)
 	r.GET("/:chatId", GetChatByIdController)
+	r.DELETE("/:chatId", DeleteChatController)
+	r.PUT("/:chatId/owners", SetOwnersController)
+	r.PUT("/:chatId/presence", SetChatPresenceController)
+	r.GET("/:chatId/webhooks", GetWebhooksController)
+	r.POST("/:chatId/webhooks", CreateWebhookController)
+	r.DELETE("/:chatId/webhooks/:webhookId", DeleteWebhookController)
+	r.GET("/:chatId/translate", TranslateChatController)
+	r.GET("/:chatId/keys", GetChatMemberKeysController)
+	r.POST("/:chatId/import", ImportMessagesController)
+	r.GET("/:chatId/stats", GetChatStatsController)
+	r.GET("/:chatId/messages/stream", StreamMessagesController)
+	r.POST("/:chatId/join", JoinChatController)
+	r.POST("/:chatId/kick/:userId", KickMemberController)
+	r.POST("/:chatId/ban/:userId", BanMemberController)
+	r.DELETE("/:chatId/ban/:userId", UnbanMemberController)
 }
 
 func CreateChatController(c *gin.Context) {
-	payload, logger, db, _, errors := common.SetupEndpoint[CreateChatRequest](c)
+	payload, logger, db, cfg, errors := common.SetupEndpoint[CreateChatRequest](c)
 	if errors != nil {
 		c.JSON(http.StatusInternalServerError, api.ApiError{
 			Code:    http.StatusInternalServerError,
@@ -40,7 +62,7 @@ func CreateChatController(c *gin.Context) {
 		return
 	}
 
-	chat, err := CreateChat(db, payload, user.(*auth.JWTAccessTokenPayload), logger)
+	chat, err := CreateChat(db, cfg, payload, user.(*auth.JWTAccessTokenPayload), logger)
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
@@ -79,7 +101,7 @@ func GetChatPreviewsController(c *gin.Context) {
 }
 
 func GetChatByIdController(c *gin.Context) {
-	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
 	if errors != nil {
 		c.JSON(http.StatusInternalServerError, api.ApiError{
 			Code:    http.StatusInternalServerError,
@@ -100,7 +122,7 @@ func GetChatByIdController(c *gin.Context) {
 
 	chatId := c.Param("chatId")
 
-	chat, err := GetChatById(db, chatId, user.(*auth.JWTAccessTokenPayload), logger)
+	chat, err := GetChatById(db, cfg, chatId, user.(*auth.JWTAccessTokenPayload), logger)
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
@@ -108,3 +130,606 @@ func GetChatByIdController(c *gin.Context) {
 
 	c.JSON(http.StatusOK, chat)
 }
+
+func DiscoverChatsController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	var query DiscoverChatsRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	chats, err := DiscoverChats(db, &query, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chats)
+}
+
+func JoinChatController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[JoinChatRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	err := JoinChat(db, c.Param("chatId"), payload, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func KickMemberController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	err := KickMember(db, cfg, c.Param("chatId"), c.Param("userId"), user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func BanMemberController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[BanMemberRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	// no body means a permanent ban without a reason
+	if payload == nil {
+		payload = &BanMemberRequest{}
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	err := BanMember(db, c.Param("chatId"), c.Param("userId"), payload, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func UnbanMemberController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	err := UnbanMember(db, c.Param("chatId"), c.Param("userId"), user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func GetChatMemberKeysController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	keys, err := GetChatMemberKeys(db, c.Param("chatId"), user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	body, e := json.Marshal(keys)
+	if e != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	// the keys only change when members join, leave or rotate their keys
+	hash := sha256.Sum256(body)
+	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(hash[:16]))
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+func ImportMessagesController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[ImportMessagesRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	result, err := ImportMessages(db, c.Param("chatId"), payload, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func GetChatStatsController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	stats, err := GetChatStats(db, cfg, c.Param("chatId"), user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// StreamMessagesController writes the messages as newline delimited json, one message per line.
+func StreamMessagesController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	var query StreamMessagesRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	jwtPayload := user.(*auth.JWTAccessTokenPayload)
+	encoder := json.NewEncoder(c.Writer)
+	controller := http.NewResponseController(c.Writer)
+	write := func(entries []MessageEntry) error {
+		written := max(c.Writer.Size(), 0)
+		// every batch gets the full write timeout, so long streams are not cut off
+		if err := controller.SetWriteDeadline(time.Now().Add(time.Duration(cfg.WriteTimeout) * time.Second)); err != nil {
+			logger.PrintfDebug("Could not extend write deadline: %s", err)
+		}
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+
+		recordStreamBandwidth(jwtPayload.UserId, int64(c.Writer.Size()-written))
+		// the stream ends once the daily bandwidth is used up, resuming it is refused
+		if usedUp, _ := streamBandwidthUsedUp(db, cfg, jwtPayload.UserId); usedUp {
+			return errStreamBandwidthUsedUp
+		}
+		return c.Request.Context().Err()
+	}
+
+	if err := StreamMessages(db, cfg, c.Param("chatId"), &query, jwtPayload, write, logger); err != nil {
+		c.JSON(err.Code, err)
+	}
+}
+
+func SetOwnersController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[SetOwnersRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	owners, err := SetOwners(db, c.Param("chatId"), payload, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, owners)
+}
+
+// DeleteChatController answers 202 while the deletion still waits for approvals of other owners.
+func DeleteChatController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	res, err := DeleteChat(db, cfg, c.Param("chatId"), user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	if !res.Deleted {
+		c.JSON(http.StatusAccepted, res)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func GetWebhooksController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	webhooks, err := GetWebhooks(db, c.Param("chatId"), user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func CreateWebhookController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[CreateWebhookRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	webhook, err := CreateWebhook(db, cfg, c.Param("chatId"), payload, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func DeleteWebhookController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := DeleteWebhook(db, c.Param("chatId"), c.Param("webhookId"), user.(*auth.JWTAccessTokenPayload), logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func SetChatPresenceController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[SetChatPresenceRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := SetChatPresence(db, c.Param("chatId"), payload, user.(*auth.JWTAccessTokenPayload), logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func TranslateChatController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	var query TranslateChatRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	translated, err := TranslateChat(c.Request.Context(), db, cfg, c.Param("chatId"), &query, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, translated)
+}
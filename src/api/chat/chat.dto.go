@@ -1,8 +1,12 @@
 package chat
 
+import "time"
+
 type UserKeyEntry struct {
-	UserID string `json:"userId" validate:"required"`
+	UserID string `json:"userId" validate:"required_without=Username"`
 	Key    string `json:"key" validate:"required"`
+	// instead of the user id, so users can be invited by handle without knowing their email
+	Username string `json:"username,omitempty" validate:"omitempty,max=30"`
 }
 
 type UserEntry struct {
@@ -18,13 +22,22 @@ type MessageEntry struct {
 	Content   string `json:"content"`
 	Iv        string `json:"iv"`
 	SenderId  string `json:"sender_id"`
+	Imported  bool   `json:"imported"`
+	// admin of the chat that imported the message
+	ImportedBy *string `json:"importedBy,omitempty"`
+	// self destructing messages are deleted for everyone at this time
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 type CreateChatRequest struct {
 	Name        string         `json:"name" validate:"required"`
 	Picture     *string        `json:"picture" validate:"omitempty,url"`
 	Description *string        `json:"description" validate:"omitempty"`
+	IsPublic    bool           `json:"isPublic"`
+	Category    *string        `json:"category" validate:"omitempty,lte=50"`
 	UserKeys    []UserKeyEntry `json:"userKeys" validate:"required,dive"`
+	// languages of the name and description, e.g. ["en", "de-CH"], five tags of up to 19 characters fit the column
+	Languages []string `json:"languages" validate:"omitempty,lte=5,dive,max=19,bcp47_language_tag"`
 }
 
 type CreateChatResponse struct {
@@ -34,6 +47,8 @@ type CreateChatResponse struct {
 	Name        string  `json:"name"`
 	Picture     *string `json:"picture"`
 	Description *string `json:"description"`
+	// hint for clients, see GET /chat/:chatId/translate
+	Languages []string `json:"languages"`
 }
 
 type GetChatPreviewResponse struct {
@@ -47,3 +62,153 @@ type GetChatByIdResponse struct {
 	Messages []MessageEntry `json:"messages"`
 	Users    []UserEntry    `json:"users"`
 }
+
+type DiscoverChatsRequest struct {
+	Query    string `form:"q" validate:"omitempty,lte=255"`
+	Category string `form:"category" validate:"omitempty,lte=50"`
+	// pages of 50 chats, deep pages are not offered because every page counts the members of all matching chats
+	Page int `form:"page" validate:"omitempty,gte=0,lte=20"`
+}
+
+type DiscoverChatResponse struct {
+	CreateChatResponse
+	Category    *string `json:"category"`
+	MemberCount int64   `json:"memberCount"`
+}
+
+type JoinChatRequest struct {
+	Key string `json:"key" validate:"required"`
+}
+
+type BanMemberRequest struct {
+	// duration of the ban in seconds, omitted means permanent
+	Duration *int    `json:"duration" validate:"omitempty,gt=0"`
+	Reason   *string `json:"reason" validate:"omitempty,lte=1000"`
+}
+
+type SetOwnersRequest struct {
+	// user ids of the new owners, all of them have to be members and every current owner except the caller has to stay
+	Owners []string `json:"owners" validate:"required,gte=1,lte=100,dive,uuid"`
+}
+
+type OwnersResponse struct {
+	Owners []string `json:"owners"`
+}
+
+type DeleteChatResponse struct {
+	Deleted bool `json:"deleted"`
+	// owners that approved the deletion within the last 24 hours and the number of approvals needed
+	Approvals int `json:"approvals"`
+	Required  int `json:"required"`
+}
+
+type CreateWebhookRequest struct {
+	Url string `json:"url" validate:"required,url,lte=2048"`
+	// events to deliver, empty delivers all events
+	Events []WebhookEvent `json:"events" validate:"lte=10,dive,oneof=member.joined member.kicked member.banned member.unbanned owners.changed messages.imported messages.expired"`
+}
+
+type WebhookResponse struct {
+	Id        string         `json:"id"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Url       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	CreatedBy string         `json:"createdBy"`
+}
+
+type CreateWebhookResponse struct {
+	WebhookResponse
+	// only returned once, deliveries are signed with it
+	Secret string `json:"secret"`
+}
+
+type SetChatPresenceRequest struct {
+	Hidden bool `json:"hidden"`
+}
+
+type TranslateChatRequest struct {
+	Target string `form:"target" validate:"required,bcp47_language_tag"`
+}
+
+// TranslateChatResponse holds the translated name and description, the description is nil when the chat has none.
+type TranslateChatResponse struct {
+	Target      string  `json:"target"`
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+type MemberKeyEntry struct {
+	UserId    string `json:"userId"`
+	PublicKey string `json:"publicKey"`
+}
+
+// ImportMessageEntry is a message from another platform, encrypted by the client like any other message.
+type ImportMessageEntry struct {
+	SenderId string `json:"senderId" validate:"required"`
+	// optional, makes retries of an import idempotent
+	ClientMessageId *string   `json:"clientMessageId" validate:"omitempty,uuid"`
+	Content         string    `json:"content" validate:"required"`
+	Iv              string    `json:"iv" validate:"required,lte=25"`
+	CreatedAt       time.Time `json:"createdAt" validate:"required"`
+	// optional, the message is deleted for everyone at this time
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type ImportMessagesRequest struct {
+	// members of the other platform that are added to the chat before the messages, with the chat key encrypted for them
+	Members  []UserKeyEntry       `json:"members" validate:"omitempty,max=1000,dive"`
+	Messages []ImportMessageEntry `json:"messages" validate:"required,min=1,max=1000,dive"`
+}
+
+type ImportedMessage struct {
+	ClientMessageId string `json:"clientMessageId"`
+	Id              string `json:"id"`
+	// the message was already stored by an earlier request
+	Duplicate bool `json:"duplicate"`
+}
+
+type ImportMessagesResponse struct {
+	Imported int `json:"imported"`
+	// user ids of the members that were added, members of the chat already are skipped
+	AddedMembers []string `json:"addedMembers"`
+	// server ids of the messages with a client message id, in request order
+	Messages []ImportedMessage `json:"messages"`
+}
+
+type StreamMessagesRequest struct {
+	// id of the last message already received, the stream continues after it
+	After string `form:"after" validate:"omitempty,uuid"`
+}
+
+type ParticipantStats struct {
+	UserId       string `json:"userId"`
+	Name         string `json:"name"`
+	MessageCount int64  `json:"messageCount"`
+}
+
+type DayActivity struct {
+	// day the messages were sent, formatted as YYYY-MM-DD
+	Day          string `json:"day"`
+	MessageCount int64  `json:"messageCount"`
+}
+
+type ChatStatsResponse struct {
+	TotalMessages int64              `json:"totalMessages"`
+	Participants  []ParticipantStats `json:"participants"`
+	// days without messages are omitted
+	Activity   []DayActivity `json:"activity"`
+	ComputedAt time.Time     `json:"computedAt"`
+}
+
+type DailyBandwidth struct {
+	// formatted as YYYY-MM-DD in UTC
+	Day   string `json:"day"`
+	Bytes int64  `json:"bytes"`
+}
+
+type StreamBandwidthResponse struct {
+	// bytes per day, 0 means unlimited
+	DailyLimit int64 `json:"dailyLimit"`
+	// newest first, days without streams are omitted
+	Days []DailyBandwidth `json:"days"`
+}
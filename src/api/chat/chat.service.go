@@ -1,27 +1,62 @@
 package chat
 
 import (
+	"easyflow-backend/src/antiabuse"
 	"easyflow-backend/src/api"
 	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/moderation"
 	"easyflow-backend/src/common"
 	"easyflow-backend/src/database"
 	"easyflow-backend/src/enum"
+	"easyflow-backend/src/metrics"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*CreateChatResponse, *api.ApiError) {
+func CreateChat(db *gorm.DB, cfg *common.Config, payload *CreateChatRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*CreateChatResponse, *api.ApiError) {
+	fields := map[string]string{"name": payload.Name}
+	if payload.Description != nil {
+		fields["description"] = *payload.Description
+	}
+	if err := moderation.CheckFields(cfg, logger, fields); err != nil {
+		return nil, err
+	}
+
+	if antiabuse.CheckChatCreation(db, cfg, jwtPayload.UserId, payload.Name, logger) == antiabuse.Throttle {
+		return nil, &api.ApiError{
+			Code:  http.StatusTooManyRequests,
+			Error: enum.TooManyAttempts,
+		}
+	}
+
 	var users []database.User
 	var userKeys []UserKeyEntry
 
 	// Start a transaction
+	txStart := time.Now()
 	tx := db.Begin()
 
 	//get users from payload.UserKeys
 	for _, userKey := range payload.UserKeys {
 		user := database.User{}
-		if err := tx.Where("id = ?", userKey.UserID).First(&user).Error; err != nil {
+		if userKey.UserID == "" {
+			if err := tx.Where("username = ?", strings.ToLower(userKey.Username)).First(&user).Error; err != nil {
+				tx.Rollback()
+				logger.PrintfWarning("User with username: %s not found", userKey.Username)
+				return nil, &api.ApiError{
+					Code:  http.StatusNotFound,
+					Error: enum.UserNotFound,
+				}
+			}
+			userKey.UserID = user.Id
+		} else if err := tx.Where("id = ?", userKey.UserID).First(&user).Error; err != nil {
 			tx.Rollback()
 			logger.PrintfError("Error getting user with id: %s", userKey.UserID)
 			return nil, &api.ApiError{
@@ -29,6 +64,12 @@ func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAcc
 				Error: enum.ApiError,
 			}
 		}
+		if user.Id != jwtPayload.UserId {
+			if err := checkChatInvite(tx, &user, jwtPayload.UserId, logger); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
 		users = append(users, user)
 		userKeys = append(userKeys, userKey)
 	}
@@ -55,6 +96,9 @@ func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAcc
 		Name:        payload.Name,
 		Picture:     payload.Picture,
 		Description: payload.Description,
+		IsPublic:    payload.IsPublic,
+		Languages:   joinLanguages(payload.Languages),
+		Category:    payload.Category,
 		Messages:    nil,
 	}
 
@@ -69,9 +113,10 @@ func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAcc
 
 	for i, user := range users {
 		chatUserKeys := &database.ChatUserKeys{
-			ChatId: chat.Id,
-			UserId: user.Id,
-			Key:    userKeys[i].Key,
+			ChatId:  chat.Id,
+			UserId:  user.Id,
+			Key:     userKeys[i].Key,
+			IsAdmin: user.Id == jwtPayload.UserId,
 		}
 
 		if err := tx.Create(chatUserKeys).Error; err != nil {
@@ -84,7 +129,9 @@ func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAcc
 		}
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	err := tx.Commit().Error
+	metrics.ObserveDatabase("transaction", txStart, err)
+	if err != nil {
 		logger.PrintfError("Error committing transaction: %s", err)
 		return nil, &api.ApiError{
 			Code:  http.StatusInternalServerError,
@@ -92,6 +139,8 @@ func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAcc
 		}
 	}
 
+	antiabuse.RecordChatCreation(db, cfg, jwtPayload.UserId, payload.Name, logger)
+
 	logger.Printf("Successfully created chat with id: %s", chat.Id)
 
 	return &CreateChatResponse{
@@ -101,6 +150,7 @@ func CreateChat(db *gorm.DB, payload *CreateChatRequest, jwtPayload *auth.JWTAcc
 		Name:        chat.Name,
 		Picture:     chat.Picture,
 		Description: chat.Description,
+		Languages:   chatLanguages(chat),
 	}, nil
 }
 
@@ -128,7 +178,7 @@ func GetChatPreviews(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger
 		}
 
 		var lastMessage *database.Message = nil
-		if err := db.Where("chat_id = ?", chatUserKey.ChatId).Order("created_at desc").First(&lastMessage).Error; err != nil {
+		if err := db.Scopes(notExpired).Where("chat_id = ?", chatUserKey.ChatId).Order("created_at desc").First(&lastMessage).Error; err != nil {
 			if err != gorm.ErrRecordNotFound {
 				// If there's another error, log it and return
 				logger.PrintfError("Error getting last message for chat with id: %s. Error: %s", chatUserKey.ChatId, err.Error())
@@ -146,6 +196,7 @@ func GetChatPreviews(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger
 					Name:        chat.Name,
 					Picture:     chat.Picture,
 					Description: chat.Description,
+					Languages:   chatLanguages(&chat),
 				},
 				LastMessage: &lastMessage.Content,
 			}
@@ -159,14 +210,67 @@ func GetChatPreviews(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger
 	return chatPreviews, nil
 }
 
-func GetChatById(db *gorm.DB, chatId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*GetChatByIdResponse, *api.ApiError) {
-	var chat database.Chat
-	if err := db.Where("id = ?", chatId).First(&chat).Error; err != nil {
-		logger.PrintfError("Error getting chat with id: %s. Error: %s", chatId, err)
-		return nil, &api.ApiError{
-			Code:  http.StatusInternalServerError,
-			Error: enum.ApiError,
+// number of latest messages returned with a chat
+const chatPreviewMessages = 50
+
+func GetChatById(db *gorm.DB, cfg *common.Config, chatId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*GetChatByIdResponse, *api.ApiError) {
+	if err := checkNotBanned(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	// the cached member list is shared between all members, so membership is checked before it is served
+	if err := checkChatMember(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	var chatResponse CreateChatResponse
+	var usersEntries []UserEntry
+
+	if cached, ok := getCachedChat(chatId); ok {
+		logger.PrintfDebug("Serving chat with id: %s from cache", chatId)
+		chatResponse = cached.chat
+		usersEntries = cached.users
+	} else {
+		var chat database.Chat
+		if err := db.Where("id = ?", chatId).First(&chat).Error; err != nil {
+			logger.PrintfError("Error getting chat with id: %s. Error: %s", chatId, err)
+			return nil, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+
+		var members []database.ChatUserKeys
+		if err := db.Preload("User").Where("chat_id = ?", chatId).Find(&members).Error; err != nil {
+			logger.PrintfError("Error getting members for chat with id: %s. Error: %s", chatId, err)
+			return nil, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
 		}
+
+		chatResponse = CreateChatResponse{
+			Id:          chat.Id,
+			CreatedAt:   chat.CreatedAt.String(),
+			UpdateAt:    chat.UpdatedAt.String(),
+			Name:        chat.Name,
+			Picture:     chat.Picture,
+			Description: chat.Description,
+			Languages:   chatLanguages(&chat),
+		}
+
+		usersEntries = []UserEntry{}
+		for _, member := range members {
+			usersEntries = append(usersEntries,
+				UserEntry{
+					Id:   member.User.Id,
+					Name: member.User.Name,
+					Bio:  member.User.Bio,
+				},
+			)
+		}
+
+		setCachedChat(chatId, chatResponse, usersEntries, cfg.ChatCacheTTL)
 	}
 
 	var chatUserKeys []database.ChatUserKeys
@@ -179,7 +283,7 @@ func GetChatById(db *gorm.DB, chatId string, jwtPayload *auth.JWTAccessTokenPayl
 	}
 
 	var Messages []database.Message
-	if err := db.Where("chat_id = ?", chatId).Order("created_at desc").Find(&Messages).Limit(50).Error; err != nil {
+	if err := db.Scopes(notExpired).Where("chat_id = ?", chatId).Order("created_at desc").Limit(chatPreviewMessages).Find(&Messages).Error; err != nil {
 		logger.PrintfError("Error getting messages for chat with id: %s. Error: %s", chatId, err)
 		return nil, &api.ApiError{
 			Code:  http.StatusInternalServerError,
@@ -187,25 +291,8 @@ func GetChatById(db *gorm.DB, chatId string, jwtPayload *auth.JWTAccessTokenPayl
 		}
 	}
 
-	// Mappings
-	usersEntries := []UserEntry{}
-	for _, chatUserKey := range chatUserKeys {
-		var user database.User
-		if err := db.Where("id = ?", chatUserKey.UserId).First(&user).Error; err != nil {
-			logger.PrintfError("Error getting user with id: %s. Error: %s", chatUserKey.UserId, err)
-			return nil, &api.ApiError{
-				Code:  http.StatusInternalServerError,
-				Error: enum.ApiError,
-			}
-		}
-
-		usersEntries = append(usersEntries,
-			UserEntry{
-				Id:   user.Id,
-				Name: user.Name,
-				Bio:  user.Bio,
-			},
-		)
+	if len(Messages) < chatPreviewMessages {
+		Messages = append(Messages, latestArchivedMessages(db, cfg, chatId, chatPreviewMessages-len(Messages), logger)...)
 	}
 
 	// TODO: Just make one object for user keys not array
@@ -221,32 +308,783 @@ func GetChatById(db *gorm.DB, chatId string, jwtPayload *auth.JWTAccessTokenPayl
 
 	messageEntries := []MessageEntry{}
 	for _, message := range Messages {
-		messageEntries = append(messageEntries,
-			MessageEntry{
-				Id:        message.Id,
-				CreatedAt: message.CreatedAt.String(),
-				UpdatedAt: message.UpdatedAt.String(),
-				Content:   message.Content,
-				Iv:        message.Iv,
-				SenderId:  message.SenderId,
-			},
-		)
+		messageEntries = append(messageEntries, toMessageEntry(&message))
 	}
 
 	logger.Printf("Successfully got chat with id: %s", chatId)
 
 	return &GetChatByIdResponse{
-		CreateChatResponse: CreateChatResponse{
-			Id:          chat.Id,
-			CreatedAt:   chat.CreatedAt.String(),
-			UpdateAt:    chat.UpdatedAt.String(),
-			Name:        chat.Name,
-			Picture:     chat.Picture,
-			Description: chat.Description,
-		},
-		Users:    usersEntries,
-		UserKeys: userKeyEntries,
-		Messages: messageEntries,
+		CreateChatResponse: chatResponse,
+		Users:              usersEntries,
+		UserKeys:           userKeyEntries,
+		Messages:           messageEntries,
 	}, nil
 
 }
+
+// escapes the wildcards of LIKE patterns, backslash is the default escape character of MySQL
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func DiscoverChats(db *gorm.DB, query *DiscoverChatsRequest, logger *common.Logger) ([]DiscoverChatResponse, *api.ApiError) {
+	const pageSize = 50
+
+	type chatWithMemberCount struct {
+		database.Chat
+		MemberCount int64
+	}
+
+	tx := db.Model(&database.Chat{}).
+		Select("chats.*, COUNT(chat_user_keys.id) AS member_count").
+		Joins("LEFT JOIN chat_user_keys ON chat_user_keys.chat_id = chats.id").
+		Where("chats.is_public = ?", true)
+
+	if query.Query != "" {
+		tx = tx.Where("chats.name LIKE ?", "%"+likeEscaper.Replace(query.Query)+"%")
+	}
+	if query.Category != "" {
+		tx = tx.Where("chats.category = ?", query.Category)
+	}
+
+	var chats []chatWithMemberCount
+	if err := tx.Group("chats.id").Order("member_count desc").Limit(pageSize).Offset(query.Page * pageSize).Scan(&chats).Error; err != nil {
+		logger.PrintfError("Error discovering public chats: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	result := []DiscoverChatResponse{}
+	for _, chat := range chats {
+		result = append(result, DiscoverChatResponse{
+			CreateChatResponse: CreateChatResponse{
+				Id:          chat.Id,
+				CreatedAt:   chat.CreatedAt.String(),
+				UpdateAt:    chat.UpdatedAt.String(),
+				Name:        chat.Name,
+				Picture:     chat.Picture,
+				Description: chat.Description,
+				Languages:   chatLanguages(&chat.Chat),
+			},
+			Category:    chat.Category,
+			MemberCount: chat.MemberCount,
+		})
+	}
+
+	logger.Printf("Successfully discovered %d public chats", len(result))
+
+	return result, nil
+}
+
+func JoinChat(db *gorm.DB, chatId string, payload *JoinChatRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	var chat database.Chat
+	if err := db.Where("id = ?", chatId).First(&chat).Error; err != nil {
+		logger.PrintfWarning("Chat with id: %s not found", chatId)
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	if err := checkNotBanned(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return err
+	}
+
+	if !chat.IsPublic {
+		logger.PrintfWarning("User: %s tried to join non public chat: %s", jwtPayload.UserId, chatId)
+		return &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.NotAllowed,
+		}
+	}
+
+	var count int64
+	if err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id = ?", chatId, jwtPayload.UserId).Count(&count).Error; err != nil {
+		logger.PrintfError("Error checking membership of user: %s in chat: %s. Error: %s", jwtPayload.UserId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	if count > 0 {
+		return &api.ApiError{
+			Code:  http.StatusConflict,
+			Error: enum.AlreadyExists,
+		}
+	}
+
+	if err := db.Create(&database.ChatUserKeys{
+		ChatId: chatId,
+		UserId: jwtPayload.UserId,
+		Key:    payload.Key,
+	}).Error; err != nil {
+		logger.PrintfError("Error adding user: %s to chat: %s. Error: %s", jwtPayload.UserId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	InvalidateChat(chatId)
+
+	dispatchWebhook(db, logger, chatId, WebhookMemberJoined, memberEventData{UserId: jwtPayload.UserId})
+
+	logger.Printf("User: %s joined public chat: %s", jwtPayload.UserId, chatId)
+
+	return nil
+}
+
+// checkNotBanned returns an error if the user has an active ban in the chat.
+func checkNotBanned(db *gorm.DB, chatId string, userId string, logger *common.Logger) *api.ApiError {
+	var ban database.ChatBan
+	err := db.Where("chat_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)", chatId, userId, time.Now()).
+		Order("expires_at IS NULL desc, expires_at desc").First(&ban).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		logger.PrintfError("Error checking bans of user: %s in chat: %s. Error: %s", userId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.PrintfWarning("Banned user: %s tried to access chat: %s", userId, chatId)
+
+	details := gin.H{"reason": ban.Reason}
+	if ban.ExpiresAt != nil {
+		details["expiresAt"] = ban.ExpiresAt
+	}
+	return &api.ApiError{
+		Code:    http.StatusForbidden,
+		Error:   enum.BannedFromChat,
+		Details: details,
+	}
+}
+
+// checkChatMember hides chats from non-members by answering with not found.
+func checkChatMember(db *gorm.DB, chatId string, userId string, logger *common.Logger) *api.ApiError {
+	var count int64
+	if err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id = ?", chatId, userId).Count(&count).Error; err != nil {
+		logger.PrintfError("Error checking membership of user: %s in chat: %s. Error: %s", userId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if count == 0 {
+		logger.PrintfWarning("User: %s is not a member of chat: %s", userId, chatId)
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	return nil
+}
+
+// checkChatAdmin returns an error if the user is not an admin of the chat.
+func checkChatAdmin(db *gorm.DB, chatId string, userId string, logger *common.Logger) *api.ApiError {
+	var count int64
+	if err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id = ? AND is_admin = ?", chatId, userId, true).Count(&count).Error; err != nil {
+		logger.PrintfError("Error checking admin rights of user: %s in chat: %s. Error: %s", userId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if count == 0 {
+		logger.PrintfWarning("User: %s is not an admin of chat: %s", userId, chatId)
+		return &api.ApiError{
+			Code:  http.StatusForbidden,
+			Error: enum.NotAllowed,
+		}
+	}
+
+	return nil
+}
+
+// checkChatInvite applies the chat invite setting of a user that is added to a new chat by the inviter.
+func checkChatInvite(db *gorm.DB, user *database.User, inviterId string, logger *common.Logger) *api.ApiError {
+	notAllowed := &api.ApiError{
+		Code:    http.StatusForbidden,
+		Error:   enum.NotAllowed,
+		Details: fmt.Sprintf("User %s does not accept chat invitations from you", user.Id),
+	}
+
+	switch user.ChatInvites {
+	case enum.ChatInvitesNobody:
+		logger.PrintfWarning("User: %s does not accept chat invitations, rejected invite by: %s", user.Id, inviterId)
+		return notAllowed
+	case enum.ChatInvitesContacts:
+		var count int64
+		if err := db.Model(&database.ChatUserKeys{}).
+			Joins("JOIN chat_user_keys AS own ON own.chat_id = chat_user_keys.chat_id AND own.user_id = ?", inviterId).
+			Where("chat_user_keys.user_id = ?", user.Id).Count(&count).Error; err != nil {
+			logger.PrintfError("Error checking shared chats of user: %s and user: %s. Error: %s", user.Id, inviterId, err)
+			return &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+		if count == 0 {
+			logger.PrintfWarning("User: %s only accepts chat invitations from contacts, rejected invite by: %s", user.Id, inviterId)
+			return notAllowed
+		}
+	}
+
+	return nil
+}
+
+// removeMember bans the user for the given duration (nil is permanent) and removes the membership.
+func removeMember(db *gorm.DB, chatId string, userId string, duration *int, reason *string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return err
+	}
+
+	if userId == jwtPayload.UserId {
+		return &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: "You can not remove yourself",
+		}
+	}
+
+	// admins are the owners of the chat, they can only be removed by stepping down, see SetOwners
+	var admins int64
+	if err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id = ? AND is_admin = ?", chatId, userId, true).Count(&admins).Error; err != nil {
+		logger.PrintfError("Error checking admin rights of user: %s in chat: %s. Error: %s", userId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	if admins > 0 {
+		logger.PrintfWarning("User: %s tried to remove admin: %s of chat: %s", jwtPayload.UserId, userId, chatId)
+		return &api.ApiError{
+			Code:    http.StatusForbidden,
+			Error:   enum.NotAllowed,
+			Details: "Admins of the chat can not be removed",
+		}
+	}
+
+	ban := database.ChatBan{
+		ChatId:   chatId,
+		UserId:   userId,
+		Reason:   reason,
+		BannedBy: jwtPayload.UserId,
+	}
+	if duration != nil {
+		expiresAt := time.Now().Add(time.Duration(*duration) * time.Second)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ban).Error; err != nil {
+			return err
+		}
+		return tx.Where("chat_id = ? AND user_id = ?", chatId, userId).Delete(&database.ChatUserKeys{}).Error
+	})
+	if err != nil {
+		logger.PrintfError("Error removing user: %s from chat: %s. Error: %s", userId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	InvalidateChat(chatId)
+
+	return nil
+}
+
+func KickMember(db *gorm.DB, cfg *common.Config, chatId string, userId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	cooldown := cfg.KickCooldown
+	if err := removeMember(db, chatId, userId, &cooldown, nil, jwtPayload, logger); err != nil {
+		return err
+	}
+
+	dispatchWebhook(db, logger, chatId, WebhookMemberKicked, memberEventData{UserId: userId, By: jwtPayload.UserId})
+
+	logger.Printf("Kicked user: %s from chat: %s", userId, chatId)
+
+	return nil
+}
+
+func BanMember(db *gorm.DB, chatId string, userId string, payload *BanMemberRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	if err := removeMember(db, chatId, userId, payload.Duration, payload.Reason, jwtPayload, logger); err != nil {
+		return err
+	}
+
+	dispatchWebhook(db, logger, chatId, WebhookMemberBanned, memberEventData{UserId: userId, By: jwtPayload.UserId})
+
+	logger.Printf("Banned user: %s from chat: %s", userId, chatId)
+
+	return nil
+}
+
+func UnbanMember(db *gorm.DB, chatId string, userId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return err
+	}
+
+	if err := db.Where("chat_id = ? AND user_id = ?", chatId, userId).Delete(&database.ChatBan{}).Error; err != nil {
+		logger.PrintfError("Error unbanning user: %s from chat: %s. Error: %s", userId, chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	dispatchWebhook(db, logger, chatId, WebhookMemberUnbanned, memberEventData{UserId: userId, By: jwtPayload.UserId})
+
+	logger.Printf("Unbanned user: %s from chat: %s", userId, chatId)
+
+	return nil
+}
+
+// SetChatPresence hides or shows the presence of the user to the other members of the chat.
+func SetChatPresence(db *gorm.DB, chatId string, payload *SetChatPresenceRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	res := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id = ?", chatId, jwtPayload.UserId).
+		Update("hide_presence", payload.Hidden)
+	if res.Error != nil {
+		logger.PrintfError("Error setting presence of user: %s in chat: %s. Error: %s", jwtPayload.UserId, chatId, res.Error)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	if res.RowsAffected == 0 {
+		return checkChatMember(db, chatId, jwtPayload.UserId, logger)
+	}
+
+	logger.Printf("User: %s set presence hidden to %t in chat: %s", jwtPayload.UserId, payload.Hidden, chatId)
+
+	return nil
+}
+
+// GetChatMemberKeys returns the public keys of all members of the chat in one call.
+func GetChatMemberKeys(db *gorm.DB, chatId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) ([]MemberKeyEntry, *api.ApiError) {
+	if err := checkChatMember(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	keys := []MemberKeyEntry{}
+	if err := db.Model(&database.ChatUserKeys{}).
+		Select("users.id AS user_id, users.public_key").
+		Joins("JOIN users ON users.id = chat_user_keys.user_id").
+		Where("chat_user_keys.chat_id = ?", chatId).
+		Order("users.id").Scan(&keys).Error; err != nil {
+		logger.PrintfError("Error getting member keys of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return keys, nil
+}
+
+// ImportMessages inserts historical messages with their original timestamps. Only chat admins can import, the
+// members of the other platform are added first and every sender has to be a member afterwards. The messages keep
+// the admin that imported them, so members can tell imported history from what they wrote themselves.
+func ImportMessages(db *gorm.DB, chatId string, payload *ImportMessagesRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*ImportMessagesResponse, *api.ApiError) {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	var members []string
+	if err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ?", chatId).Pluck("user_id", &members).Error; err != nil {
+		logger.PrintfError("Error getting members of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, member := range members {
+		isMember[member] = true
+	}
+
+	// members are added like in CreateChat, so bans and invite settings apply to imports as well
+	newMembers := []database.ChatUserKeys{}
+	for i, entry := range payload.Members {
+		var user database.User
+		var err error
+		if entry.UserID == "" {
+			err = db.Where("username = ?", strings.ToLower(entry.Username)).First(&user).Error
+		} else {
+			err = db.Where("id = ?", entry.UserID).First(&user).Error
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &api.ApiError{
+				Code:    http.StatusNotFound,
+				Error:   enum.UserNotFound,
+				Details: fmt.Sprintf("Member %d not found", i),
+			}
+		}
+		if err != nil {
+			logger.PrintfError("Error getting member %d of import into chat: %s. Error: %s", i, chatId, err)
+			return nil, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+
+		if isMember[user.Id] {
+			continue
+		}
+		if err := checkNotBanned(db, chatId, user.Id, logger); err != nil {
+			return nil, err
+		}
+		if err := checkChatInvite(db, &user, jwtPayload.UserId, logger); err != nil {
+			return nil, err
+		}
+
+		isMember[user.Id] = true
+		newMembers = append(newMembers, database.ChatUserKeys{
+			ChatId: chatId,
+			UserId: user.Id,
+			Key:    entry.Key,
+		})
+	}
+
+	// messages already stored by an earlier attempt, keyed by sender and client message id
+	clientMessageIds := []string{}
+	for _, entry := range payload.Messages {
+		if entry.ClientMessageId != nil {
+			clientMessageIds = append(clientMessageIds, *entry.ClientMessageId)
+		}
+	}
+	stored := make(map[string]string)
+	if len(clientMessageIds) > 0 {
+		var existing []database.Message
+		if err := db.Select("id", "sender_id", "client_message_id").Where("chat_id = ? AND client_message_id IN ?", chatId, clientMessageIds).Find(&existing).Error; err != nil {
+			logger.PrintfError("Error getting imported messages of chat: %s. Error: %s", chatId, err)
+			return nil, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+		for _, message := range existing {
+			stored[message.SenderId+"/"+*message.ClientMessageId] = message.Id
+		}
+	}
+
+	now := time.Now()
+	messages := make([]database.Message, 0, len(payload.Messages))
+	// index into messages for new entries, -1 for duplicates
+	created := make([]int, len(payload.Messages))
+	pending := make(map[string]int)
+	for i, entry := range payload.Messages {
+		if !isMember[entry.SenderId] {
+			return nil, &api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Sender %s of message %d is not a member of the chat", entry.SenderId, i),
+			}
+		}
+		if entry.CreatedAt.After(now) {
+			return nil, &api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Message %d has a timestamp in the future", i),
+			}
+		}
+		if entry.ExpiresAt != nil && !entry.ExpiresAt.After(now) {
+			return nil, &api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Message %d already expired", i),
+			}
+		}
+
+		created[i] = -1
+		if entry.ClientMessageId != nil {
+			key := entry.SenderId + "/" + *entry.ClientMessageId
+			if _, ok := stored[key]; ok {
+				continue
+			}
+			if _, ok := pending[key]; ok {
+				continue
+			}
+			pending[key] = len(messages)
+		}
+
+		created[i] = len(messages)
+		messages = append(messages, database.Message{
+			CreatedAt:       entry.CreatedAt,
+			UpdatedAt:       entry.CreatedAt,
+			Content:         entry.Content,
+			Iv:              entry.Iv,
+			ChatId:          chatId,
+			SenderId:        entry.SenderId,
+			ClientMessageId: entry.ClientMessageId,
+			Imported:        true,
+			ImportedBy:      &jwtPayload.UserId,
+			ExpiresAt:       entry.ExpiresAt,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(newMembers) > 0 {
+			if err := tx.Create(&newMembers).Error; err != nil {
+				return err
+			}
+		}
+		// gorm rejects empty batches, everything may have been imported before
+		if len(messages) > 0 {
+			return tx.CreateInBatches(&messages, 100).Error
+		}
+		return nil
+	})
+	if err != nil {
+		logger.PrintfError("Error importing messages into chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	response := ImportMessagesResponse{
+		Imported:     len(messages),
+		AddedMembers: []string{},
+		Messages:     []ImportedMessage{},
+	}
+	for _, member := range newMembers {
+		response.AddedMembers = append(response.AddedMembers, member.UserId)
+	}
+	for i, entry := range payload.Messages {
+		if entry.ClientMessageId == nil {
+			continue
+		}
+
+		key := entry.SenderId + "/" + *entry.ClientMessageId
+		if created[i] >= 0 {
+			response.Messages = append(response.Messages, ImportedMessage{ClientMessageId: *entry.ClientMessageId, Id: messages[created[i]].Id})
+		} else if id, ok := stored[key]; ok {
+			response.Messages = append(response.Messages, ImportedMessage{ClientMessageId: *entry.ClientMessageId, Id: id, Duplicate: true})
+		} else {
+			response.Messages = append(response.Messages, ImportedMessage{ClientMessageId: *entry.ClientMessageId, Id: messages[pending[key]].Id, Duplicate: true})
+		}
+	}
+
+	invalidateStats(chatId)
+
+	if len(newMembers) > 0 {
+		InvalidateChat(chatId)
+		for _, member := range newMembers {
+			dispatchWebhook(db, logger, chatId, WebhookMemberJoined, memberEventData{UserId: member.UserId, By: jwtPayload.UserId})
+		}
+	}
+
+	if len(messages) > 0 {
+		metadata := make([]messageMetadata, 0, len(messages))
+		for _, message := range messages {
+			metadata = append(metadata, messageMetadata{Id: message.Id, SenderId: message.SenderId, CreatedAt: message.CreatedAt})
+		}
+		dispatchWebhook(db, logger, chatId, WebhookMessagesImported, messagesEventData{Messages: metadata})
+	}
+
+	logger.Printf("Imported %d messages and %d members into chat: %s", len(messages), len(newMembers), chatId)
+
+	return &response, nil
+}
+
+// GetChatStats aggregates the messages of the chat. The aggregates are cached because
+// they scan every message of the chat, so they can lag behind by CHAT_STATS_CACHE_TTL.
+// Archived messages are not part of the aggregates.
+func GetChatStats(db *gorm.DB, cfg *common.Config, chatId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*ChatStatsResponse, *api.ApiError) {
+	if err := checkChatMember(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	if stats, ok := getCachedStats(chatId); ok {
+		return stats, nil
+	}
+
+	stats := ChatStatsResponse{
+		Participants: []ParticipantStats{},
+		Activity:     []DayActivity{},
+		ComputedAt:   time.Now(),
+	}
+
+	if err := db.Model(&database.Message{}).Scopes(notExpired).
+		Select("messages.sender_id AS user_id, users.name, COUNT(*) AS message_count").
+		Joins("JOIN users ON users.id = messages.sender_id").
+		Where("messages.chat_id = ?", chatId).
+		Group("messages.sender_id, users.name").
+		Order("message_count DESC").Scan(&stats.Participants).Error; err != nil {
+		logger.PrintfError("Error getting message counts of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if err := db.Model(&database.Message{}).Scopes(notExpired).
+		Select("DATE_FORMAT(created_at, '%Y-%m-%d') AS day, COUNT(*) AS message_count").
+		Where("chat_id = ?", chatId).
+		Group("day").
+		Order("day").Scan(&stats.Activity).Error; err != nil {
+		logger.PrintfError("Error getting activity of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	for _, participant := range stats.Participants {
+		stats.TotalMessages += participant.MessageCount
+	}
+
+	setCachedStats(chatId, stats, cfg.ChatStatsCacheTTL)
+
+	return &stats, nil
+}
+
+// number of messages loaded per query while streaming
+const messageStreamBatchSize = 500
+
+func toMessageEntry(message *database.Message) MessageEntry {
+	return MessageEntry{
+		Id:         message.Id,
+		CreatedAt:  message.CreatedAt.String(),
+		UpdatedAt:  message.UpdatedAt.String(),
+		Content:    message.Content,
+		Iv:         message.Iv,
+		SenderId:   message.SenderId,
+		Imported:   message.Imported,
+		ImportedBy: message.ImportedBy,
+		ExpiresAt:  message.ExpiresAt,
+	}
+}
+
+// StreamMessages passes the messages of the chat to write in batches, oldest first.
+// It pages with the (created_at, id) keyset instead of an offset, so late batches are as cheap as the first one.
+// Archived months are read from their archive objects before the messages table.
+// Errors after the first batch can only end the stream, clients resume it with the id of the last message they got.
+func StreamMessages(db *gorm.DB, cfg *common.Config, chatId string, query *StreamMessagesRequest, jwtPayload *auth.JWTAccessTokenPayload, write func([]MessageEntry) error, logger *common.Logger) *api.ApiError {
+	if err := checkChatMember(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return err
+	}
+
+	if err := checkStreamBandwidth(db, cfg, jwtPayload.UserId, logger); err != nil {
+		return err
+	}
+
+	archives, err := getArchives(db, chatId)
+	if err != nil {
+		logger.PrintfError("Error getting message archives of chat: %s. Error: %s", chatId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	var cursor *database.Message
+	if query.After != "" {
+		var after database.Message
+		if err := db.Select("id", "created_at").Where("id = ? AND chat_id = ?", query.After, chatId).First(&after).Error; err == nil {
+			cursor = &after
+		} else if cursor, err = findArchivedMessage(db, cfg, chatId, query.After, logger); err != nil {
+			logger.PrintfError("Error searching message archives of chat: %s. Error: %s", chatId, err)
+			return &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+
+		if cursor == nil {
+			return &api.ApiError{
+				Code:    http.StatusNotFound,
+				Error:   enum.NotFound,
+				Details: fmt.Sprintf("Message %s not found in chat", query.After),
+			}
+		}
+	}
+
+	streamed := 0
+	for i := range archives {
+		// skip months that end before the cursor without downloading them
+		month, _ := time.Parse("2006-01", archives[i].Month)
+		if cursor != nil && !month.AddDate(0, 1, 0).After(cursor.CreatedAt) {
+			continue
+		}
+
+		messages, err := loadArchive(cfg, &archives[i], logger)
+		if err != nil {
+			logger.PrintfError("Error reading message archive of chat: %s. Error: %s", chatId, err)
+			if streamed == 0 {
+				return &api.ApiError{
+					Code:  http.StatusInternalServerError,
+					Error: enum.ApiError,
+				}
+			}
+			return nil
+		}
+
+		entries := []MessageEntry{}
+		for _, message := range messages {
+			if !message.after(cursor) {
+				continue
+			}
+			archived := message.toMessage()
+			entries = append(entries, toMessageEntry(&archived))
+		}
+
+		for batch := range slices.Chunk(entries, messageStreamBatchSize) {
+			if err := write(batch); err != nil {
+				logger.PrintfDebug("Stopped streaming messages of chat: %s. Error: %s", chatId, err)
+				return nil
+			}
+			streamed += len(batch)
+		}
+
+		if len(messages) > 0 && messages[len(messages)-1].after(cursor) {
+			last := messages[len(messages)-1].toMessage()
+			cursor = &last
+		}
+	}
+
+	for {
+		tx := db.Scopes(notExpired).Where("chat_id = ?", chatId)
+		if cursor != nil {
+			tx = tx.Where("(created_at, id) > (?, ?)", cursor.CreatedAt, cursor.Id)
+		}
+
+		var messages []database.Message
+		if err := tx.Order("created_at, id").Limit(messageStreamBatchSize).Find(&messages).Error; err != nil {
+			logger.PrintfError("Error streaming messages of chat: %s. Error: %s", chatId, err)
+			if streamed == 0 {
+				return &api.ApiError{
+					Code:  http.StatusInternalServerError,
+					Error: enum.ApiError,
+				}
+			}
+			return nil
+		}
+
+		entries := make([]MessageEntry, 0, len(messages))
+		for _, message := range messages {
+			entries = append(entries, toMessageEntry(&message))
+		}
+		if err := write(entries); err != nil {
+			logger.PrintfDebug("Stopped streaming messages of chat: %s. Error: %s", chatId, err)
+			return nil
+		}
+		streamed += len(messages)
+
+		if len(messages) < messageStreamBatchSize {
+			break
+		}
+		cursor = &messages[len(messages)-1]
+	}
+
+	logger.Printf("Streamed %d messages of chat: %s", streamed, chatId)
+
+	return nil
+}
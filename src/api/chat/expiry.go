@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// number of expired messages deleted per query
+const expiryDeleteBatchSize = 1000
+
+// named lock that keeps the expiry jobs of several instances from firing the same webhooks
+const expiryLockName = "easyflow_message_expiry"
+
+// notExpired hides self destructing messages from the moment they expire, the cleanup job deletes them later.
+func notExpired(tx *gorm.DB) *gorm.DB {
+	return tx.Where("(messages.expires_at IS NULL OR messages.expires_at > ?)", time.Now())
+}
+
+// StartMessageExpiry periodically deletes messages whose expiry passed.
+func StartMessageExpiry(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	if cfg.MessageExpiryInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.MessageExpiryInterval) * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			withLock(db, expiryLockName, logger, func() {
+				deleteExpiredMessages(db, logger)
+			})
+		}
+	}()
+}
+
+func deleteExpiredMessages(db *gorm.DB, logger *common.Logger) {
+	now := time.Now()
+	for {
+		var messages []database.Message
+		if err := db.Select("id", "chat_id").Where("expires_at <= ?", now).
+			Limit(expiryDeleteBatchSize).Find(&messages).Error; err != nil {
+			logger.PrintfError("Could not get expired messages: %s", err)
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		ids := make([]string, 0, len(messages))
+		byChat := map[string][]string{}
+		for _, message := range messages {
+			ids = append(ids, message.Id)
+			byChat[message.ChatId] = append(byChat[message.ChatId], message.Id)
+		}
+
+		if err := db.Where("id IN ?", ids).Delete(&database.Message{}).Error; err != nil {
+			logger.PrintfError("Could not delete expired messages: %s", err)
+			return
+		}
+
+		for chatId, messageIds := range byChat {
+			invalidateStats(chatId)
+			dispatchWebhook(db, logger, chatId, WebhookMessagesExpired, expiredEventData{MessageIds: messageIds})
+		}
+
+		logger.Printf("Deleted %d expired messages", len(ids))
+
+		if len(messages) < expiryDeleteBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package chat
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/s3"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// approvals to delete a chat expire, a deletion needs a majority of owners agreeing within this time
+const chatDeletionApprovalLifetime = 24 * time.Hour
+
+func getOwners(db *gorm.DB, chatId string) ([]string, error) {
+	var owners []string
+	err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND is_admin = ?", chatId, true).
+		Order("user_id").Pluck("user_id", &owners).Error
+	return owners, err
+}
+
+// SetOwners replaces the owners of the chat with the given members. Only owners can change them, they can add
+// owners and remove themselves but not other owners, which would lower the majority needed to delete the chat.
+func SetOwners(db *gorm.DB, chatId string, payload *SetOwnersRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*OwnersResponse, *api.ApiError) {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	owners := slices.Compact(slices.Sorted(slices.Values(payload.Owners)))
+
+	current, err := getOwners(db, chatId)
+	if err != nil {
+		logger.PrintfError("Error getting owners of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	for _, owner := range current {
+		if owner != jwtPayload.UserId && !slices.Contains(owners, owner) {
+			logger.PrintfWarning("User: %s tried to remove owner: %s of chat: %s", jwtPayload.UserId, owner, chatId)
+			return nil, &api.ApiError{
+				Code:    http.StatusForbidden,
+				Error:   enum.NotAllowed,
+				Details: "Owners can only remove themselves",
+			}
+		}
+	}
+
+	var members int64
+	if err := db.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id IN ?", chatId, owners).Count(&members).Error; err != nil {
+		logger.PrintfError("Error checking members of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if members != int64(len(owners)) {
+		return nil, &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: "All owners have to be members of the chat",
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.ChatUserKeys{}).Where("chat_id = ?", chatId).
+			Update("is_admin", gorm.Expr("user_id IN ?", owners)).Error; err != nil {
+			return err
+		}
+
+		// approvals of former owners do not count anymore
+		return tx.Where("chat_id = ? AND user_id NOT IN ?", chatId, owners).Delete(&database.ChatDeletionApproval{}).Error
+	})
+	if err != nil {
+		logger.PrintfError("Error setting owners of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	dispatchWebhook(db, logger, chatId, WebhookOwnersChanged, ownersEventData{Owners: owners, By: jwtPayload.UserId})
+
+	logger.Printf("Set %d owners of chat: %s", len(owners), chatId)
+
+	return &OwnersResponse{Owners: owners}, nil
+}
+
+// DeleteChat records the approval of an owner to delete the chat. The chat is deleted once a majority of its
+// owners approved within chatDeletionApprovalLifetime, a chat with a single owner is deleted right away.
+func DeleteChat(db *gorm.DB, cfg *common.Config, chatId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*DeleteChatResponse, *api.ApiError) {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	approval := database.ChatDeletionApproval{
+		ChatId:    chatId,
+		UserId:    jwtPayload.UserId,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&approval).Error; err != nil {
+		logger.PrintfError("Error approving deletion of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	owners, err := getOwners(db, chatId)
+	if err != nil {
+		logger.PrintfError("Error getting owners of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	var approvals int64
+	if err := db.Model(&database.ChatDeletionApproval{}).
+		Where("chat_id = ? AND user_id IN ? AND created_at > ?", chatId, owners, time.Now().Add(-chatDeletionApprovalLifetime)).
+		Count(&approvals).Error; err != nil {
+		logger.PrintfError("Error counting deletion approvals of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	response := &DeleteChatResponse{
+		Approvals: int(approvals),
+		Required:  len(owners)/2 + 1,
+	}
+	if response.Approvals < response.Required {
+		logger.Printf("User: %s approved deletion of chat: %s, %d of %d approvals", jwtPayload.UserId, chatId, response.Approvals, response.Required)
+		return response, nil
+	}
+
+	var archives []database.MessageArchive
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_id = ?", chatId).Find(&archives).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&database.Message{},
+			&database.ChatUserKeys{},
+			&database.ChatBan{},
+			&database.ChatDeletionApproval{},
+			&database.MessageArchive{},
+			&database.ArchivedMessage{},
+			&database.ChatWebhook{},
+		} {
+			if err := tx.Where("chat_id = ?", chatId).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id = ?", chatId).Delete(&database.Chat{}).Error
+	})
+	if err != nil {
+		logger.PrintfError("Error deleting chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	// the rows are gone, leftover objects are unreachable and only cost storage
+	for _, archive := range archives {
+		if err := s3.DeleteObject(logger, cfg, cfg.MessageArchiveBucketName, archive.ObjectKey); err != nil {
+			logger.PrintfWarning("Could not delete message archive %s of deleted chat: %s", archive.ObjectKey, chatId)
+		}
+	}
+
+	InvalidateChat(chatId)
+	invalidateStats(chatId)
+
+	logger.Printf("Deleted chat: %s", chatId)
+
+	response.Deleted = true
+	return response, nil
+}
+
+// PromoteOwnerSuccessors makes the longest standing member an owner of every chat in which the user is the last owner.
+// It is called before the memberships of a purged user are removed, so no chat is left without an owner.
+func PromoteOwnerSuccessors(tx *gorm.DB, userId string) error {
+	var chatIds []string
+	if err := tx.Model(&database.ChatUserKeys{}).Where("user_id = ? AND is_admin = ?", userId, true).Pluck("chat_id", &chatIds).Error; err != nil {
+		return err
+	}
+
+	for _, chatId := range chatIds {
+		var owners int64
+		if err := tx.Model(&database.ChatUserKeys{}).Where("chat_id = ? AND user_id <> ? AND is_admin = ?", chatId, userId, true).Count(&owners).Error; err != nil {
+			return err
+		}
+		if owners > 0 {
+			continue
+		}
+
+		var successor database.ChatUserKeys
+		err := tx.Where("chat_id = ? AND user_id <> ?", chatId, userId).Order("created_at, id").First(&successor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&successor).Update("is_admin", true).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"context"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/translation"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+func joinLanguages(languages []string) *string {
+	if len(languages) == 0 {
+		return nil
+	}
+	joined := strings.Join(languages, ",")
+	return &joined
+}
+
+func chatLanguages(chat *database.Chat) []string {
+	if chat.Languages == nil || *chat.Languages == "" {
+		return []string{}
+	}
+	return strings.Split(*chat.Languages, ",")
+}
+
+// TranslateChat translates the name and description of the chat with the configured provider.
+// Members and, for public chats, everyone who is not banned can request it. Messages are end to end
+// encrypted and cannot be translated by the server, clients translate them locally.
+func TranslateChat(ctx context.Context, db *gorm.DB, cfg *common.Config, chatId string, query *TranslateChatRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*TranslateChatResponse, *api.ApiError) {
+	if !translation.Enabled(cfg) {
+		return nil, &api.ApiError{
+			Code:    http.StatusNotFound,
+			Error:   enum.NotFound,
+			Details: "Translation is disabled",
+		}
+	}
+
+	var chat database.Chat
+	if err := db.Where("id = ?", chatId).First(&chat).Error; err != nil {
+		logger.PrintfWarning("Chat with id: %s not found", chatId)
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	if err := checkNotBanned(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+	if !chat.IsPublic {
+		if err := checkChatMember(db, chatId, jwtPayload.UserId, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	// the first language is the one of the name and description, without a hint the provider detects it
+	source := ""
+	if languages := chatLanguages(&chat); len(languages) > 0 {
+		source = languages[0]
+		if strings.EqualFold(source, query.Target) {
+			return &TranslateChatResponse{
+				Target:      query.Target,
+				Name:        chat.Name,
+				Description: chat.Description,
+			}, nil
+		}
+	}
+
+	texts := []string{chat.Name}
+	if chat.Description != nil && *chat.Description != "" {
+		texts = append(texts, *chat.Description)
+	}
+
+	translated, err := translation.Translate(ctx, cfg, texts, source, query.Target)
+	if err != nil {
+		logger.PrintfError("Could not translate chat: %s to %s. Error: %s", chatId, query.Target, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusBadGateway,
+			Error: enum.ApiError,
+		}
+	}
+
+	response := &TranslateChatResponse{
+		Target: query.Target,
+		Name:   translated[0],
+	}
+	if len(translated) > 1 {
+		response.Description = &translated[1]
+	}
+
+	logger.Printf("Translated chat: %s to %s for user: %s", chatId, query.Target, jwtPayload.UserId)
+
+	return response, nil
+}
@@ -0,0 +1,319 @@
+package chat
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxWebhooksPerChat = 10
+
+// a delivery is retried after these delays, the first attempt is made right away
+var webhookRetryDelays = []time.Duration{10 * time.Second, time.Minute}
+
+type WebhookEvent string
+
+const (
+	WebhookMemberJoined     WebhookEvent = "member.joined"
+	WebhookMemberKicked     WebhookEvent = "member.kicked"
+	WebhookMemberBanned     WebhookEvent = "member.banned"
+	WebhookMemberUnbanned   WebhookEvent = "member.unbanned"
+	WebhookOwnersChanged    WebhookEvent = "owners.changed"
+	WebhookMessagesImported WebhookEvent = "messages.imported"
+	WebhookMessagesExpired  WebhookEvent = "messages.expired"
+)
+
+// webhookDelivery is the body of a delivery. Messages are end to end encrypted, so only their metadata is sent.
+type webhookDelivery struct {
+	Id        string       `json:"id"`
+	Event     WebhookEvent `json:"event"`
+	ChatId    string       `json:"chatId"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Data      interface{}  `json:"data"`
+}
+
+type memberEventData struct {
+	UserId string `json:"userId"`
+	// member that kicked, banned, unbanned or imported the user
+	By string `json:"by,omitempty"`
+}
+
+type ownersEventData struct {
+	Owners []string `json:"owners"`
+	By     string   `json:"by"`
+}
+
+type messageMetadata struct {
+	Id        string    `json:"id"`
+	SenderId  string    `json:"senderId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type messagesEventData struct {
+	Messages []messageMetadata `json:"messages"`
+}
+
+type expiredEventData struct {
+	MessageIds []string `json:"messageIds"`
+}
+
+// rejectPrivateAddress keeps webhooks from reaching the internal network of the server.
+// It runs after name resolution, so hostnames resolving to private addresses are rejected as well.
+func rejectPrivateAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("webhook address %s is not public", host)
+	}
+
+	return nil
+}
+
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: rejectPrivateAddress}).DialContext,
+	},
+	// a redirect would be followed without the signature check of the receiver, so it counts as a failure
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookSignature is the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the secret of the webhook.
+func webhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func toWebhookResponse(webhook *database.ChatWebhook) WebhookResponse {
+	events := []WebhookEvent{}
+	if webhook.Events != "" {
+		for _, event := range strings.Split(webhook.Events, ",") {
+			events = append(events, WebhookEvent(event))
+		}
+	}
+
+	return WebhookResponse{
+		Id:        webhook.Id,
+		CreatedAt: webhook.CreatedAt,
+		Url:       webhook.Url,
+		Events:    events,
+		CreatedBy: webhook.CreatedBy,
+	}
+}
+
+// dispatchWebhook delivers the event to the webhooks of the chat in the background.
+func dispatchWebhook(db *gorm.DB, logger *common.Logger, chatId string, event WebhookEvent, data interface{}) {
+	var webhooks []database.ChatWebhook
+	if err := db.Where("chat_id = ?", chatId).Find(&webhooks).Error; err != nil {
+		logger.PrintfError("Error getting webhooks of chat: %s. Error: %s", chatId, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Events != "" && !slices.Contains(strings.Split(webhook.Events, ","), string(event)) {
+			continue
+		}
+
+		body, err := json.Marshal(webhookDelivery{
+			Id:        uuid.NewString(),
+			Event:     event,
+			ChatId:    chatId,
+			CreatedAt: time.Now(),
+			Data:      data,
+		})
+		if err != nil {
+			logger.PrintfError("Error encoding %s webhook of chat: %s. Error: %s", event, chatId, err)
+			return
+		}
+
+		go deliverWebhook(webhook, event, body, logger.Detached())
+	}
+}
+
+// deliverWebhook posts the body until the receiver answers with a 2xx status or the retries are used up.
+func deliverWebhook(webhook database.ChatWebhook, event WebhookEvent, body []byte, logger *common.Logger) {
+	var err error
+	for attempt := 0; attempt <= len(webhookRetryDelays); attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookRetryDelays[attempt-1])
+		}
+
+		if err = postWebhook(&webhook, event, body); err == nil {
+			return
+		}
+		logger.PrintfDebug("Webhook %s delivery attempt %d failed: %s", webhook.Id, attempt+1, err)
+	}
+
+	logger.PrintfWarning("Could not deliver %s to webhook %s of chat: %s. Error: %s", event, webhook.Id, webhook.ChatId, err)
+}
+
+func postWebhook(webhook *database.ChatWebhook, event WebhookEvent, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	// the timestamp is signed, so receivers can reject replayed deliveries
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Easyflow-Event", string(event))
+	req.Header.Set("X-Easyflow-Timestamp", timestamp)
+	req.Header.Set("X-Easyflow-Signature", webhookSignature(webhook.Secret, timestamp, body))
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("receiver answered with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// GetWebhooks returns the webhooks of the chat. Only owners can see them.
+func GetWebhooks(db *gorm.DB, chatId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) ([]WebhookResponse, *api.ApiError) {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	var webhooks []database.ChatWebhook
+	if err := db.Where("chat_id = ?", chatId).Order("created_at").Find(&webhooks).Error; err != nil {
+		logger.PrintfError("Error getting webhooks of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	response := make([]WebhookResponse, 0, len(webhooks))
+	for i := range webhooks {
+		response = append(response, toWebhookResponse(&webhooks[i]))
+	}
+
+	return response, nil
+}
+
+// CreateWebhook registers a webhook and returns its secret, which is not shown again.
+// In production the url has to use https.
+func CreateWebhook(db *gorm.DB, cfg *common.Config, chatId string, payload *CreateWebhookRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*CreateWebhookResponse, *api.ApiError) {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return nil, err
+	}
+
+	target, err := url.Parse(payload.Url)
+	if err != nil || (target.Scheme != "https" && (cfg.Stage == "production" || target.Scheme != "http")) {
+		return nil, &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: "The webhook url has to use https",
+		}
+	}
+
+	var count int64
+	if err := db.Model(&database.ChatWebhook{}).Where("chat_id = ?", chatId).Count(&count).Error; err != nil {
+		logger.PrintfError("Error counting webhooks of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	if count >= maxWebhooksPerChat {
+		return nil, &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: fmt.Sprintf("A chat can have at most %d webhooks", maxWebhooksPerChat),
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		logger.PrintfError("Error generating webhook secret: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	events := make([]string, 0, len(payload.Events))
+	for _, event := range payload.Events {
+		events = append(events, string(event))
+	}
+
+	webhook := database.ChatWebhook{
+		ChatId:    chatId,
+		Url:       payload.Url,
+		Secret:    hex.EncodeToString(raw),
+		Events:    strings.Join(slices.Compact(slices.Sorted(slices.Values(events))), ","),
+		CreatedBy: jwtPayload.UserId,
+	}
+	if err := db.Create(&webhook).Error; err != nil {
+		logger.PrintfError("Error creating webhook of chat: %s. Error: %s", chatId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("User: %s created webhook %s of chat: %s", jwtPayload.UserId, webhook.Id, chatId)
+
+	return &CreateWebhookResponse{
+		WebhookResponse: toWebhookResponse(&webhook),
+		Secret:          webhook.Secret,
+	}, nil
+}
+
+func DeleteWebhook(db *gorm.DB, chatId string, webhookId string, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	if err := checkChatAdmin(db, chatId, jwtPayload.UserId, logger); err != nil {
+		return err
+	}
+
+	res := db.Where("id = ? AND chat_id = ?", webhookId, chatId).Delete(&database.ChatWebhook{})
+	if res.Error != nil {
+		logger.PrintfError("Error deleting webhook %s of chat: %s. Error: %s", webhookId, chatId, res.Error)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	if res.RowsAffected == 0 {
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	logger.Printf("User: %s deleted webhook %s of chat: %s", jwtPayload.UserId, webhookId, chatId)
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package keylog
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterKeyLogEndpoints(r *gin.RouterGroup) {
+	r.Use(middleware.LoggerMiddleware("KeyLog"))
+	r.Use(auth.AuthGuard())
+	r.Use(middleware.RateLimiter(1, 5))
+	r.GET("/head", GetTreeHeadController)
+	r.GET("/users/:userId", GetUserEntriesController)
+	r.GET("/proof/:index", GetInclusionProofController)
+}
+
+func GetTreeHeadController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	head, err := GetTreeHead(db, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, head)
+}
+
+func GetUserEntriesController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	entries, err := GetUserEntries(db, c.Param("userId"), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func GetInclusionProofController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	index, e := strconv.ParseInt(c.Param("index"), 10, 64)
+	if e != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: e.Error(),
+		})
+		return
+	}
+
+	var query ProofRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	proof, err := GetInclusionProof(db, index, &query, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, proof)
+}
@@ -0,0 +1,27 @@
+package keylog
+
+import "time"
+
+type LogEntry struct {
+	Index     int64     `json:"index"`
+	CreatedAt time.Time `json:"createdAt"`
+	PublicKey string    `json:"publicKey"`
+	LeafHash  string    `json:"leafHash"`
+}
+
+type TreeHeadResponse struct {
+	TreeSize int64  `json:"treeSize"`
+	RootHash string `json:"rootHash"`
+}
+
+type ProofRequest struct {
+	TreeSize int64 `form:"treeSize" validate:"omitempty,gt=0"`
+}
+
+type InclusionProofResponse struct {
+	Index     int64    `json:"index"`
+	TreeSize  int64    `json:"treeSize"`
+	LeafHash  string   `json:"leafHash"`
+	RootHash  string   `json:"rootHash"`
+	AuditPath []string `json:"auditPath"`
+}
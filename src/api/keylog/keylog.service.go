@@ -0,0 +1,239 @@
+package keylog
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const headId = 1
+
+func leafData(userId string, publicKey string) []byte {
+	return []byte(fmt.Sprintf("%s\n%s", userId, publicKey))
+}
+
+// Append adds the public key of a user to the key transparency log.
+// It has to be called whenever a public key is set or changed, ideally in the same transaction.
+// The index is taken from the locked head row, so concurrent appends of all instances get consecutive indexes.
+func Append(db *gorm.DB, userId string, publicKey string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var head database.KeyLogHead
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", headId).First(&head).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&database.KeyLogEntry{
+			Index:     head.Size,
+			PublicKey: publicKey,
+			LeafHash:  hex.EncodeToString(leafHash(leafData(userId, publicKey))),
+			UserId:    userId,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&head).Update("size", head.Size+1).Error
+	})
+}
+
+// initHead creates the head row, logs that existed before it start at their current size.
+func initHead(db *gorm.DB) error {
+	var size int64
+	if err := db.Model(&database.KeyLogEntry{}).Count(&size).Error; err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&database.KeyLogHead{Id: headId, Size: size}).Error
+}
+
+// Backfill appends the keys of users that have no log entry yet, e.g. users created before the log existed.
+func Backfill(db *gorm.DB, logger *common.Logger) error {
+	if err := initHead(db); err != nil {
+		return err
+	}
+
+	var users []database.User
+	if err := db.Select("id", "public_key").
+		Where("public_key <> '' AND id NOT IN (?)", db.Model(&database.KeyLogEntry{}).Select("user_id")).
+		Order("created_at").Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := Append(db, user.Id, user.PublicKey); err != nil {
+			return err
+		}
+	}
+
+	if len(users) > 0 {
+		logger.Printf("Added %d existing keys to the key transparency log", len(users))
+	}
+
+	return nil
+}
+
+// the log is append-only, so the leaves and the root of the latest size are kept and only new leaves are loaded
+var cache struct {
+	leaves [][]byte
+	root   []byte
+	mutex  sync.Mutex
+}
+
+// loadLeaves returns the first treeSize leaves, fewer if the log is smaller.
+func loadLeaves(db *gorm.DB, treeSize int64) ([][]byte, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if treeSize > int64(len(cache.leaves)) {
+		var hashes []string
+		if err := db.Model(&database.KeyLogEntry{}).
+			Where("`index` >= ? AND `index` < ?", len(cache.leaves), treeSize).
+			Order("`index`").Pluck("leaf_hash", &hashes).Error; err != nil {
+			return nil, err
+		}
+
+		for _, hash := range hashes {
+			leaf, err := hex.DecodeString(hash)
+			if err != nil {
+				return nil, err
+			}
+			cache.leaves = append(cache.leaves, leaf)
+		}
+		if len(hashes) > 0 {
+			cache.root = nil
+		}
+	}
+
+	return cache.leaves[:min(treeSize, int64(len(cache.leaves)))], nil
+}
+
+// treeRoot returns the root hash of the leaves, the root of the whole cached log is only computed once.
+func treeRoot(leaves [][]byte) []byte {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if len(leaves) != len(cache.leaves) {
+		return rootHash(leaves)
+	}
+	if cache.root == nil {
+		cache.root = rootHash(leaves)
+	}
+	return cache.root
+}
+
+func treeSize(db *gorm.DB) (int64, error) {
+	var head database.KeyLogHead
+	if err := db.Where("id = ?", headId).First(&head).Error; err != nil {
+		return 0, err
+	}
+	return head.Size, nil
+}
+
+func GetTreeHead(db *gorm.DB, logger *common.Logger) (*TreeHeadResponse, *api.ApiError) {
+	size, err := treeSize(db)
+	if err != nil {
+		logger.PrintfError("Error getting key log size: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	leaves, err := loadLeaves(db, size)
+	if err != nil {
+		logger.PrintfError("Error loading key log: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return &TreeHeadResponse{
+		TreeSize: int64(len(leaves)),
+		RootHash: hex.EncodeToString(treeRoot(leaves)),
+	}, nil
+}
+
+// GetUserEntries returns all log entries of a user, the last one is the current key.
+func GetUserEntries(db *gorm.DB, userId string, logger *common.Logger) ([]LogEntry, *api.ApiError) {
+	var entries []database.KeyLogEntry
+	if err := db.Where("user_id = ?", userId).Order("`index`").Find(&entries).Error; err != nil {
+		logger.PrintfError("Error getting key log of user: %s. Error: %s", userId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	response := make([]LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, LogEntry{
+			Index:     entry.Index,
+			CreatedAt: entry.CreatedAt,
+			PublicKey: entry.PublicKey,
+			LeafHash:  entry.LeafHash,
+		})
+	}
+
+	return response, nil
+}
+
+// GetInclusionProof proves that the entry at index is part of the tree of the given size.
+// Without a tree size the current tree is used.
+func GetInclusionProof(db *gorm.DB, index int64, query *ProofRequest, logger *common.Logger) (*InclusionProofResponse, *api.ApiError) {
+	size := query.TreeSize
+	if size == 0 {
+		var err error
+		if size, err = treeSize(db); err != nil {
+			logger.PrintfError("Error getting key log size: %s", err)
+			return nil, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+	}
+
+	leaves, err := loadLeaves(db, size)
+	if err != nil {
+		logger.PrintfError("Error loading key log: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if index < 0 || index >= int64(len(leaves)) || size > int64(len(leaves)) {
+		return nil, &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.NotFound,
+		}
+	}
+
+	path := inclusionPath(int(index), leaves)
+	auditPath := make([]string, 0, len(path))
+	for _, hash := range path {
+		auditPath = append(auditPath, hex.EncodeToString(hash))
+	}
+
+	return &InclusionProofResponse{
+		Index:     index,
+		TreeSize:  size,
+		LeafHash:  hex.EncodeToString(leaves[index]),
+		RootHash:  hex.EncodeToString(treeRoot(leaves)),
+		AuditPath: auditPath,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package keylog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// test vectors of the certificate transparency reference implementation
+var rfc6962Leaves = [][]byte{
+	{},
+	{0x00},
+	{0x10},
+	{0x20, 0x21},
+	{0x30, 0x31},
+	{0x40, 0x41, 0x42, 0x43},
+	{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+	{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+}
+
+func rfc6962LeafHashes(n int) [][]byte {
+	hashes := make([][]byte, 0, n)
+	for _, leaf := range rfc6962Leaves[:n] {
+		hashes = append(hashes, leafHash(leaf))
+	}
+	return hashes
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRootHash(t *testing.T) {
+	tests := []struct {
+		size int
+		root string
+	}{
+		{0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{1, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"},
+		{2, "fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125"},
+		{3, "aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77"},
+		{4, "d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7"},
+		{5, "4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4"},
+		{6, "76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef"},
+		{7, "ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c"},
+		{8, "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328"},
+	}
+
+	for _, tt := range tests {
+		got := rootHash(rfc6962LeafHashes(tt.size))
+		if want := decodeHex(t, tt.root); !bytes.Equal(got, want) {
+			t.Errorf("rootHash of %d leaves = %x, want %x", tt.size, got, want)
+		}
+	}
+}
+
+func TestInclusionPath(t *testing.T) {
+	tests := []struct {
+		leaf int
+		size int
+		path []string
+	}{
+		{0, 1, []string{}},
+		{0, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{5, 8, []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 3, []string{
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		}},
+		{1, 5, []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := inclusionPath(tt.leaf, rfc6962LeafHashes(tt.size))
+		if len(got) != len(tt.path) {
+			t.Errorf("inclusionPath(%d) of %d leaves has %d hashes, want %d", tt.leaf, tt.size, len(got), len(tt.path))
+			continue
+		}
+		for i := range got {
+			if want := decodeHex(t, tt.path[i]); !bytes.Equal(got[i], want) {
+				t.Errorf("inclusionPath(%d) of %d leaves [%d] = %x, want %x", tt.leaf, tt.size, i, got[i], want)
+			}
+		}
+	}
+}
+
+// every path has to lead from its leaf to the root of the tree, see RFC 6962, section 2.1.1
+func TestInclusionPathVerifies(t *testing.T) {
+	for size := 1; size <= len(rfc6962Leaves); size++ {
+		leaves := rfc6962LeafHashes(size)
+		root := rootHash(leaves)
+
+		for leaf := 0; leaf < size; leaf++ {
+			hash := leaves[leaf]
+			index, last := leaf, size-1
+			for _, sibling := range inclusionPath(leaf, leaves) {
+				if index%2 == 1 || index == last {
+					hash = nodeHash(sibling, hash)
+					for index%2 == 0 && index != 0 {
+						index >>= 1
+						last >>= 1
+					}
+				} else {
+					hash = nodeHash(hash, sibling)
+				}
+				index >>= 1
+				last >>= 1
+			}
+
+			if !bytes.Equal(hash, root) {
+				t.Errorf("path of leaf %d does not lead to the root of %d leaves", leaf, size)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package meta
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterMetaEndpoints(r *gin.RouterGroup) {
+	r.Use(middleware.LoggerMiddleware("Meta"))
+	r.Use(middleware.RateLimiter(1, 4))
+	r.GET("/errors", GetErrorCatalogueController)
+	r.GET("/capabilities", GetCapabilitiesController)
+}
+
+func GetErrorCatalogueController(c *gin.Context) {
+	c.JSON(http.StatusOK, enum.ErrorCatalogue)
+}
+
+func GetCapabilitiesController(c *gin.Context) {
+	_, _, _, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, GetCapabilities(cfg))
+}
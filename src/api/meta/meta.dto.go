@@ -0,0 +1,32 @@
+package meta
+
+type PasswordPolicy struct {
+	MinLength       int      `json:"minLength"`
+	MaxLength       int      `json:"maxLength"`
+	RequiredClasses []string `json:"requiredClasses"`
+	// number of previous passwords, including the current one, that cannot be reused
+	History int `json:"history"`
+	// passwords found in data breaches are rejected
+	RejectBreached bool `json:"rejectBreached"`
+}
+
+type Features struct {
+	OAuthProviders    []string `json:"oauthProviders"`
+	Saml              bool     `json:"saml"`
+	Passkeys          bool     `json:"passkeys"`
+	EmailVerification bool     `json:"emailVerification"`
+	ChatDiscovery     bool     `json:"chatDiscovery"`
+	Geolocation       bool     `json:"geolocation"`
+	GuestAccounts     bool     `json:"guestAccounts"`
+	// presence can be hidden per user (PUT /user/presence) and per chat (PUT /chat/:chatId/presence)
+	Presence bool `json:"presence"`
+	// there is no realtime channel, so typing indicators are not relayed
+	TypingIndicators bool `json:"typingIndicators"`
+	Translation      bool `json:"translation"`
+}
+
+type CapabilitiesResponse struct {
+	Version        string         `json:"version"`
+	Features       Features       `json:"features"`
+	PasswordPolicy PasswordPolicy `json:"passwordPolicy"`
+}
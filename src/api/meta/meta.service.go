@@ -0,0 +1,33 @@
+package meta
+
+import (
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/translation"
+	"easyflow-backend/src/api/user"
+	"easyflow-backend/src/common"
+)
+
+func GetCapabilities(cfg *common.Config) CapabilitiesResponse {
+	return CapabilitiesResponse{
+		Version: common.Version,
+		Features: Features{
+			OAuthProviders:    auth.EnabledOAuthProviders(cfg),
+			Saml:              auth.SamlEnabled(cfg),
+			Passkeys:          true,
+			EmailVerification: true,
+			ChatDiscovery:     true,
+			Geolocation:       cfg.GeoIPDatabasePath != "",
+			GuestAccounts:     cfg.GuestAccounts,
+			Presence:          true,
+			TypingIndicators:  false,
+			Translation:       translation.Enabled(cfg),
+		},
+		PasswordPolicy: PasswordPolicy{
+			MinLength:       cfg.PasswordMinLength,
+			MaxLength:       user.PasswordMaxLength(cfg),
+			RequiredClasses: cfg.PasswordRequiredClasses,
+			History:         cfg.PasswordHistory,
+			RejectBreached:  cfg.BreachedPasswordCheck,
+		},
+	}
+}
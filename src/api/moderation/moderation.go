@@ -0,0 +1,100 @@
+package moderation
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type Mode string
+
+const (
+	// violations are logged but the request goes through
+	Flag Mode = "flag"
+	// violations reject the request
+	Reject Mode = "reject"
+)
+
+// Filter checks a plaintext value the server can see and returns the offending term, if any.
+type Filter interface {
+	Check(value string) (string, bool)
+}
+
+type blocklistFilter struct {
+	blocklist []string
+	allowlist map[string]struct{}
+}
+
+// NewBlocklistFilter returns a filter that matches any blocked term contained in a value.
+// Terms on the allowlist are an admin override and are never reported, even if they contain a blocked term.
+func NewBlocklistFilter(blocklist []string, allowlist []string) Filter {
+	f := &blocklistFilter{
+		allowlist: make(map[string]struct{}, len(allowlist)),
+	}
+
+	for _, term := range blocklist {
+		f.blocklist = append(f.blocklist, strings.ToLower(term))
+	}
+	for _, term := range allowlist {
+		f.allowlist[strings.ToLower(term)] = struct{}{}
+	}
+
+	return f
+}
+
+func (f *blocklistFilter) Check(value string) (string, bool) {
+	for _, word := range strings.Fields(strings.ToLower(value)) {
+		if _, ok := f.allowlist[word]; ok {
+			continue
+		}
+		for _, term := range f.blocklist {
+			if strings.Contains(word, term) {
+				return term, true
+			}
+		}
+	}
+	return "", false
+}
+
+var extraFilters []Filter
+var extraFiltersMutex sync.RWMutex
+
+// RegisterFilter adds a filter that is applied in addition to the configured blocklist.
+func RegisterFilter(f Filter) {
+	extraFiltersMutex.Lock()
+	defer extraFiltersMutex.Unlock()
+
+	extraFilters = append(extraFilters, f)
+}
+
+// CheckFields runs every filter against the given fields (field name -> value).
+// Depending on the configured mode a violation is either only logged or returned as an error.
+func CheckFields(cfg *common.Config, logger *common.Logger, fields map[string]string) *api.ApiError {
+	extraFiltersMutex.RLock()
+	filters := append([]Filter{NewBlocklistFilter(cfg.ModerationBlocklist, cfg.ModerationAllowlist)}, extraFilters...)
+	extraFiltersMutex.RUnlock()
+
+	var violations []string
+	for field, value := range fields {
+		for _, filter := range filters {
+			if term, ok := filter.Check(value); ok {
+				logger.PrintfWarning("Content policy violation in field %s, matched term: %s", field, term)
+				violations = append(violations, field)
+				break
+			}
+		}
+	}
+
+	if len(violations) == 0 || Mode(cfg.ModerationMode) != Reject {
+		return nil
+	}
+
+	return &api.ApiError{
+		Code:    http.StatusUnprocessableEntity,
+		Error:   enum.ContentPolicyViolation,
+		Details: violations,
+	}
+}
@@ -0,0 +1,41 @@
+package notifications
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterNotificationEndpoints(r *gin.RouterGroup) {
+	r.Use(middleware.LoggerMiddleware("Notifications"))
+	r.Use(middleware.RateLimiter(1, 4))
+	// GET for the link in the mail, POST for one-click unsubscribe from mail clients
+	r.GET("/unsubscribe/:token", UnsubscribeController)
+	r.POST("/unsubscribe/:token", UnsubscribeController)
+}
+
+func UnsubscribeController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	category, err := Unsubscribe(db, cfg, c.Param("token"), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"category": category,
+	})
+}
@@ -0,0 +1,41 @@
+package notifications
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/mail"
+	"net/http"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func Unsubscribe(db *gorm.DB, cfg *common.Config, token string, logger *common.Logger) (mail.Category, *api.ApiError) {
+	userId, category, err := mail.ParseUnsubscribeToken(cfg, token)
+	if err != nil {
+		logger.PrintfWarning("Invalid unsubscribe token")
+		return "", &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidToken,
+		}
+	}
+
+	// unsubscribing twice is not an error
+	suppression := database.MailSuppression{
+		UserId:   userId,
+		Category: string(category),
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&suppression).Error; err != nil {
+		logger.PrintfError("Error unsubscribing user: %s from %s mails. Error: %s", userId, category, err)
+		return "", &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("Unsubscribed user: %s from %s mails", userId, category)
+
+	return category, nil
+}
@@ -1,16 +1,21 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"easyflow-backend/src/api"
 	"easyflow-backend/src/common"
 	"easyflow-backend/src/enum"
+	"easyflow-backend/src/metrics"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go/aws"
 )
 
@@ -34,10 +39,10 @@ func connect(cfg *common.Config) (*s3.Client, error) {
 }
 
 /*
-Object upload url generation
+Object upload url generation, the upload has to send contentType as Content-Type header.
+checksum is the optional base64 encoded SHA-256 the uploaded object must have
 */
-// TODO: Add filetype restriction to the upload url
-func GenerateUploadURL(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string, expiration int) (*string, *api.ApiError) {
+func GenerateUploadURL(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string, expiration int, contentType string, checksum *string) (*string, *api.ApiError) {
 	client, err := connect(cfg)
 	if err != nil {
 		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
@@ -50,12 +55,16 @@ func GenerateUploadURL(logger *common.Logger, cfg *common.Config, bucketName str
 
 	presigner := s3.NewPresignClient(client)
 
+	start := time.Now()
 	req, err := presigner.PresignPutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: &bucketName,
-		Key:    &objectKey,
+		Bucket:         &bucketName,
+		Key:            &objectKey,
+		ContentType:    &contentType,
+		ChecksumSHA256: checksum,
 	}, func(opts *s3.PresignOptions) {
 		opts.Expires = time.Duration(expiration) * time.Second
 	})
+	metrics.ObserveStorage("presign_put", start, err)
 	if err != nil {
 		logger.PrintfError("Could not get object %s in bucket %s", objectKey, bucketName)
 		return nil, &api.ApiError{
@@ -82,10 +91,12 @@ func GetObjectsWithPrefix(logger *common.Logger, cfg *common.Config, bucketName
 		}
 	}
 
+	start := time.Now()
 	listedObjects, err := client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
 		Bucket: &bucketName,
 		Prefix: &prefix,
 	})
+	metrics.ObserveStorage("list", start, err)
 	if err != nil {
 		logger.PrintfError("An error happened while listing objects in bucket %s", bucketName)
 		return nil, &api.ApiError{
@@ -112,10 +123,12 @@ func GenerateDownloadURL(logger *common.Logger, cfg *common.Config, bucketName s
 		}
 	}
 
+	start := time.Now()
 	exists, err := client.HeadObject(context.TODO(), &s3.HeadObjectInput{
 		Bucket: &bucketName,
 		Key:    &objectKey,
 	})
+	metrics.ObserveStorage("stat", start, err)
 	if err != nil || exists == nil {
 		logger.PrintfWarning("Could not get object %s in bucket %s", objectKey, bucketName)
 		return nil, &api.ApiError{
@@ -127,12 +140,14 @@ func GenerateDownloadURL(logger *common.Logger, cfg *common.Config, bucketName s
 
 	presigner := s3.NewPresignClient(client)
 
+	start = time.Now()
 	req, err := presigner.PresignGetObject(context.TODO(), &s3.GetObjectInput{
 		Bucket: &bucketName,
 		Key:    &objectKey,
 	}, func(opts *s3.PresignOptions) {
 		opts.Expires = time.Duration(expiration) * time.Second
 	})
+	metrics.ObserveStorage("presign_get", start, err)
 	if err != nil {
 		logger.PrintfError("Could not presign url to get object %s in bucket %s", objectKey, bucketName)
 		return nil, &api.ApiError{
@@ -144,3 +159,360 @@ func GenerateDownloadURL(logger *common.Logger, cfg *common.Config, bucketName s
 
 	return &req.URL, nil
 }
+
+/*
+UploadObject stores the content as an object in the bucket
+*/
+func UploadObject(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string, content []byte, contentType string) *api.ApiError {
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	start := time.Now()
+	_, err = client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      &bucketName,
+		Key:         &objectKey,
+		Body:        bytes.NewReader(content),
+		ContentType: &contentType,
+	})
+	metrics.ObserveStorage("put", start, err)
+	if err != nil {
+		logger.PrintfError("Could not upload object %s to bucket %s: %s", objectKey, bucketName, err)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	return nil
+}
+
+// parts of streamed uploads, the smallest part size S3 accepts
+const uploadPartSize = 5 * 1024 * 1024
+
+/*
+UploadStream uploads the content of body without holding all of it in memory.
+Bodies larger than one part are uploaded in parts, a failed upload is aborted
+*/
+func UploadStream(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string, body io.Reader, contentType string) *api.ApiError {
+	part := make([]byte, uploadPartSize)
+	n, err := io.ReadFull(body, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return UploadObject(logger, cfg, bucketName, objectKey, part[:n], contentType)
+	}
+	if err != nil {
+		logger.PrintfError("Could not read object %s for bucket %s: %s", objectKey, bucketName, err)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	start := time.Now()
+	err = uploadParts(client, bucketName, objectKey, contentType, body, part)
+	metrics.ObserveStorage("put", start, err)
+	if err != nil {
+		logger.PrintfError("Could not upload object %s to bucket %s: %s", objectKey, bucketName, err)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	return nil
+}
+
+// uploadParts uploads first and the rest of body as multipart upload.
+func uploadParts(client *s3.Client, bucketName string, objectKey string, contentType string, body io.Reader, first []byte) error {
+	upload, err := client.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:      &bucketName,
+		Key:         &objectKey,
+		ContentType: &contentType,
+	})
+	if err != nil {
+		return err
+	}
+
+	abort := func(err error) error {
+		client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+			Bucket:   &bucketName,
+			Key:      &objectKey,
+			UploadId: upload.UploadId,
+		})
+		return err
+	}
+
+	var completed []types.CompletedPart
+	part := first
+	for number := int32(1); len(part) > 0; number++ {
+		uploaded, err := client.UploadPart(context.TODO(), &s3.UploadPartInput{
+			Bucket:     &bucketName,
+			Key:        &objectKey,
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int32(number),
+			Body:       bytes.NewReader(part),
+		})
+		if err != nil {
+			return abort(err)
+		}
+		completed = append(completed, types.CompletedPart{ETag: uploaded.ETag, PartNumber: aws.Int32(number)})
+
+		n, err := io.ReadFull(body, first)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return abort(err)
+		}
+		part = first[:n]
+	}
+
+	_, err = client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          &bucketName,
+		Key:             &objectKey,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
+// ObjectInfo is the metadata of a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	// base64 encoded SHA-256 stored with the object, nil if it was uploaded without one
+	Checksum *string
+	// changes whenever the object is overwritten
+	ETag string
+}
+
+/*
+StatObject returns the size, content type and checksum of an object
+*/
+func StatObject(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string) (*ObjectInfo, *api.ApiError) {
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return nil, &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	start := time.Now()
+	object, err := client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket:       &bucketName,
+		Key:          &objectKey,
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	metrics.ObserveStorage("stat", start, err)
+	if err != nil {
+		logger.PrintfWarning("Could not get object %s in bucket %s", objectKey, bucketName)
+		return nil, &api.ApiError{
+			Code:    http.StatusNotFound,
+			Error:   enum.NotFound,
+			Details: err,
+		}
+	}
+
+	info := &ObjectInfo{
+		Checksum: object.ChecksumSHA256,
+	}
+	if object.ContentLength != nil {
+		info.Size = *object.ContentLength
+	}
+	if object.ContentType != nil {
+		info.ContentType = *object.ContentType
+	}
+	if object.ETag != nil {
+		info.ETag = *object.ETag
+	}
+
+	return info, nil
+}
+
+/*
+CopyObject copies an object to another key in the same bucket.
+With an etag the copy fails if the source was overwritten since it was stat'ed
+*/
+func CopyObject(logger *common.Logger, cfg *common.Config, bucketName string, sourceKey string, objectKey string, etag string) *api.ApiError {
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	source := bucketName + "/" + sourceKey
+
+	start := time.Now()
+	input := &s3.CopyObjectInput{
+		Bucket:     &bucketName,
+		Key:        &objectKey,
+		CopySource: &source,
+	}
+	if etag != "" {
+		input.CopySourceIfMatch = &etag
+	}
+	_, err = client.CopyObject(context.TODO(), input)
+	metrics.ObserveStorage("copy", start, err)
+	if err != nil {
+		logger.PrintfWarning("Could not copy object %s to %s in bucket %s: %s", sourceKey, objectKey, bucketName, err)
+		return &api.ApiError{
+			Code:    http.StatusNotFound,
+			Error:   enum.NotFound,
+			Details: err,
+		}
+	}
+
+	return nil
+}
+
+/*
+DeleteObject removes an object from the bucket
+*/
+func DeleteObject(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string) *api.ApiError {
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	start := time.Now()
+	_, err = client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: &bucketName,
+		Key:    &objectKey,
+	})
+	metrics.ObserveStorage("delete", start, err)
+	if err != nil {
+		logger.PrintfError("Could not delete object %s in bucket %s: %s", objectKey, bucketName, err)
+		return &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	return nil
+}
+
+/*
+GetObject downloads the content of an object in the bucket
+*/
+func GetObject(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string) ([]byte, *api.ApiError) {
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return nil, &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	start := time.Now()
+	object, err := client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: &bucketName,
+		Key:    &objectKey,
+	})
+	metrics.ObserveStorage("get", start, err)
+	if err != nil {
+		logger.PrintfWarning("Could not get object %s in bucket %s: %s", objectKey, bucketName, err)
+		return nil, &api.ApiError{
+			Code:    http.StatusNotFound,
+			Error:   enum.NotFound,
+			Details: err,
+		}
+	}
+	defer object.Body.Close()
+
+	content, err := io.ReadAll(object.Body)
+	if err != nil {
+		logger.PrintfError("Could not read object %s in bucket %s: %s", objectKey, bucketName, err)
+		return nil, &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	return content, nil
+}
+
+/*
+GetObjectRange downloads up to length bytes from the start of an object.
+With an etag the download fails if the object was overwritten since it was stat'ed
+*/
+func GetObjectRange(logger *common.Logger, cfg *common.Config, bucketName string, objectKey string, length int64, etag string) ([]byte, *api.ApiError) {
+	client, err := connect(cfg)
+	if err != nil {
+		logger.PrintfError("An error happened while connecting to the bucket %s", bucketName)
+		return nil, &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: &bucketName,
+		Key:    &objectKey,
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", length-1)),
+	}
+	if etag != "" {
+		input.IfMatch = &etag
+	}
+
+	start := time.Now()
+	object, err := client.GetObject(context.TODO(), input)
+	metrics.ObserveStorage("get", start, err)
+	if err != nil {
+		logger.PrintfWarning("Could not get object %s in bucket %s: %s", objectKey, bucketName, err)
+		return nil, &api.ApiError{
+			Code:    http.StatusNotFound,
+			Error:   enum.NotFound,
+			Details: err,
+		}
+	}
+	defer object.Body.Close()
+
+	// servers that ignore the range send the whole object
+	content, err := io.ReadAll(io.LimitReader(object.Body, length))
+	if err != nil {
+		logger.PrintfError("Could not read object %s in bucket %s: %s", objectKey, bucketName, err)
+		return nil, &api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: err,
+		}
+	}
+
+	return content, nil
+}
@@ -0,0 +1,153 @@
+package scim
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterScimEndpoints(r *gin.RouterGroup) {
+	r.Use(middleware.LoggerMiddleware("Scim"))
+	r.Use(middleware.RateLimiter(5, 20))
+	r.Use(ScimGuard())
+	r.POST("/Users", CreateUserController)
+	r.GET("/Users/:id", GetUserController)
+	r.PUT("/Users/:id", ReplaceUserController)
+	r.PATCH("/Users/:id", PatchUserController)
+	r.DELETE("/Users/:id", DeactivateUserController)
+}
+
+// writeError responds in the scim error format instead of api.ApiError.
+func writeError(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, Error{
+		Schemas: []string{errorSchema},
+		Status:  strconv.Itoa(status),
+		Detail:  detail,
+	})
+}
+
+func writeApiError(c *gin.Context, err *api.ApiError) {
+	detail, _ := err.Details.(string)
+	if detail == "" {
+		detail = string(err.Error)
+	}
+	writeError(c, err.Code, detail)
+}
+
+func writeUser(c *gin.Context, status int, user *User) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, user)
+}
+
+// bind parses scim json bodies, which are sent as application/scim+json.
+func bind[T any](c *gin.Context) (*T, bool) {
+	var payload T
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		writeError(c, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if err := api.Validate.Struct(payload); err != nil {
+		writeError(c, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return &payload, true
+}
+
+func CreateUserController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		writeError(c, http.StatusInternalServerError, "")
+		return
+	}
+
+	payload, ok := bind[User](c)
+	if !ok {
+		return
+	}
+
+	user, err := CreateUser(db, payload, logger)
+	if err != nil {
+		writeApiError(c, err)
+		return
+	}
+
+	writeUser(c, http.StatusCreated, user)
+}
+
+func GetUserController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		writeError(c, http.StatusInternalServerError, "")
+		return
+	}
+
+	user, err := GetUser(db, c.Param("id"), logger)
+	if err != nil {
+		writeApiError(c, err)
+		return
+	}
+
+	writeUser(c, http.StatusOK, user)
+}
+
+func ReplaceUserController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		writeError(c, http.StatusInternalServerError, "")
+		return
+	}
+
+	payload, ok := bind[User](c)
+	if !ok {
+		return
+	}
+
+	user, err := ReplaceUser(db, c.Param("id"), payload, logger)
+	if err != nil {
+		writeApiError(c, err)
+		return
+	}
+
+	writeUser(c, http.StatusOK, user)
+}
+
+func PatchUserController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		writeError(c, http.StatusInternalServerError, "")
+		return
+	}
+
+	payload, ok := bind[PatchRequest](c)
+	if !ok {
+		return
+	}
+
+	user, err := PatchUser(db, c.Param("id"), payload, logger)
+	if err != nil {
+		writeApiError(c, err)
+		return
+	}
+
+	writeUser(c, http.StatusOK, user)
+}
+
+func DeactivateUserController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[common.AnyStruct](c)
+	if errors != nil {
+		writeError(c, http.StatusInternalServerError, "")
+		return
+	}
+
+	if err := DeactivateUser(db, c.Param("id"), logger); err != nil {
+		writeApiError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package scim
+
+import "time"
+
+const (
+	userSchema      = "urn:ietf:params:scim:schemas:core:2.0:User"
+	errorSchema     = "urn:ietf:params:scim:api:messages:2.0:Error"
+	patchOpSchema   = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimContentType = "application/scim+json"
+)
+
+type Name struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// User is the subset of the scim core user schema easyflow supports.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	Id          string   `json:"id,omitempty"`
+	ExternalId  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName" validate:"required,email"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	// nil on requests means active
+	Active *bool `json:"active,omitempty"`
+	Meta   *Meta `json:"meta,omitempty"`
+}
+
+type PatchOperation struct {
+	Op    string      `json:"op" validate:"required"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations" validate:"required,min=1,dive"`
+}
+
+type Error struct {
+	Schemas []string `json:"schemas"`
+	Status  string   `json:"status"`
+	Detail  string   `json:"detail,omitempty"`
+}
@@ -0,0 +1,33 @@
+package scim
+
+import (
+	"crypto/subtle"
+	"easyflow-backend/src/common"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScimGuard checks the bearer token of the identity provider.
+// The scim endpoints are disabled if no token is configured.
+func ScimGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, logger, _, cfg, errs := common.SetupEndpoint[common.AnyStruct](c)
+		if errs != nil {
+			writeError(c, http.StatusInternalServerError, "")
+			c.Abort()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || cfg.ScimToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ScimToken)) != 1 {
+			logger.PrintfWarning("Rejected request to scim endpoint")
+			writeError(c, http.StatusUnauthorized, "Invalid bearer token")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
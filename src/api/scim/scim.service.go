@@ -0,0 +1,304 @@
+package scim
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/chat"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+func toScimUser(user *database.User) *User {
+	active := !user.Disabled
+	return &User{
+		Schemas:     []string{userSchema},
+		Id:          user.Id,
+		UserName:    user.Email,
+		Name:        &Name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []Email{{Value: user.Email, Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+		},
+	}
+}
+
+// displayName picks the name of the user from the scim attributes.
+func displayName(payload *User) string {
+	name := payload.DisplayName
+	if name == "" && payload.Name != nil {
+		name = payload.Name.Formatted
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(payload.UserName, "@")
+	}
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return name
+}
+
+func getUser(db *gorm.DB, userId string, logger *common.Logger) (*database.User, *api.ApiError) {
+	var user database.User
+	err := db.Where("id = ?", userId).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &api.ApiError{
+			Code:    http.StatusNotFound,
+			Error:   enum.UserNotFound,
+			Details: fmt.Sprintf("User %s not found", userId),
+		}
+	}
+	if err != nil {
+		logger.PrintfError("Error getting user: %s. Error: %s", userId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	return &user, nil
+}
+
+// setDisabled (de)activates a user. Deactivation ends all sessions and the user cannot log in anymore.
+func setDisabled(tx *gorm.DB, user *database.User, disabled bool) error {
+	if err := tx.Model(user).Update("disabled", disabled).Error; err != nil {
+		return err
+	}
+	if disabled {
+		_, err := auth.EndSessions(tx, tx.Where("user_id = ?", user.Id))
+		return err
+	}
+	return nil
+}
+
+// CreateUser provisions a user. Users provisioned by the identity provider log in through sso, so they have no password.
+func CreateUser(db *gorm.DB, payload *User, logger *common.Logger) (*User, *api.ApiError) {
+	var count int64
+	if err := db.Model(&database.User{}).Where("email = ?", payload.UserName).Count(&count).Error; err != nil {
+		logger.PrintfError("Error checking user: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	if count > 0 {
+		return nil, &api.ApiError{
+			Code:    http.StatusConflict,
+			Error:   enum.AlreadyExists,
+			Details: fmt.Sprintf("User %s already exists", payload.UserName),
+		}
+	}
+
+	user := database.User{
+		Email:         payload.UserName,
+		EmailVerified: true,
+		Name:          displayName(payload),
+		Disabled:      payload.Active != nil && !*payload.Active,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		logger.PrintfError("Error creating user: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("Provisioned user: %s", user.Id)
+
+	return toScimUser(&user), nil
+}
+
+func GetUser(db *gorm.DB, userId string, logger *common.Logger) (*User, *api.ApiError) {
+	user, err := getUser(db, userId, logger)
+	if err != nil {
+		return nil, err
+	}
+	return toScimUser(user), nil
+}
+
+// ReplaceUser updates the user with the attributes of a full scim user resource.
+func ReplaceUser(db *gorm.DB, userId string, payload *User, logger *common.Logger) (*User, *api.ApiError) {
+	user, e := getUser(db, userId, logger)
+	if e != nil {
+		return nil, e
+	}
+
+	disabled := payload.Active != nil && !*payload.Active
+	emailChanged := payload.UserName != user.Email
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"email": payload.UserName,
+			"name":  displayName(payload),
+		}).Error; err != nil {
+			return err
+		}
+		if emailChanged {
+			if err := auth.SecurityEvent(tx, user.Id, enum.EmailChanged, nil); err != nil {
+				return err
+			}
+		}
+		return setDisabled(tx, user, disabled)
+	})
+	if err != nil {
+		logger.PrintfError("Error updating user: %s. Error: %s", userId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	database.AddRegisteredEmail(payload.UserName)
+	if emailChanged {
+		audit.Record(db, logger, user.Id, enum.EmailChanged, common.ClientInfo{}, nil)
+	}
+	chat.InvalidateChatsOfUser(user.Id)
+	logger.Printf("Updated provisioned user: %s", user.Id)
+
+	return GetUser(db, userId, logger)
+}
+
+// PatchUser applies replace operations on active, displayName and userName, the attributes identity providers change.
+func PatchUser(db *gorm.DB, userId string, payload *PatchRequest, logger *common.Logger) (*User, *api.ApiError) {
+	user, e := getUser(db, userId, logger)
+	if e != nil {
+		return nil, e
+	}
+
+	updates := map[string]interface{}{}
+	var disabled *bool
+	apply := func(path string, value interface{}) *api.ApiError {
+		invalid := &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: fmt.Sprintf("Invalid value for %s", path),
+		}
+
+		switch strings.ToLower(path) {
+		case "active":
+			active, ok := value.(bool)
+			if !ok {
+				return invalid
+			}
+			d := !active
+			disabled = &d
+		case "displayname", "name.formatted":
+			name, ok := value.(string)
+			if !ok || name == "" {
+				return invalid
+			}
+			updates["name"] = displayName(&User{DisplayName: name})
+		case "username":
+			email, ok := value.(string)
+			if !ok || api.Validate.Var(email, "email") != nil {
+				return invalid
+			}
+			updates["email"] = email
+		default:
+			return &api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Unsupported attribute: %s", path),
+			}
+		}
+		return nil
+	}
+
+	for _, operation := range payload.Operations {
+		if !strings.EqualFold(operation.Op, "replace") && !strings.EqualFold(operation.Op, "add") {
+			return nil, &api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Unsupported operation: %s", operation.Op),
+			}
+		}
+
+		// without a path the value is an object of attributes
+		if operation.Path == "" {
+			values, ok := operation.Value.(map[string]interface{})
+			if !ok {
+				return nil, &api.ApiError{
+					Code:    http.StatusBadRequest,
+					Error:   enum.MalformedRequest,
+					Details: "Operations without path need an object value",
+				}
+			}
+			for path, value := range values {
+				if err := apply(path, value); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if err := apply(operation.Path, operation.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	email, emailChanged := updates["email"].(string)
+	emailChanged = emailChanged && email != user.Email
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(user).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if emailChanged {
+			if err := auth.SecurityEvent(tx, user.Id, enum.EmailChanged, nil); err != nil {
+				return err
+			}
+		}
+		if disabled != nil {
+			return setDisabled(tx, user, *disabled)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.PrintfError("Error patching user: %s. Error: %s", userId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if emailChanged {
+		database.AddRegisteredEmail(email)
+		audit.Record(db, logger, user.Id, enum.EmailChanged, common.ClientInfo{}, nil)
+	}
+	chat.InvalidateChatsOfUser(user.Id)
+	logger.Printf("Patched provisioned user: %s", user.Id)
+
+	return GetUser(db, userId, logger)
+}
+
+// DeactivateUser handles deprovisioning. The account is disabled instead of deleted so chats stay intact.
+func DeactivateUser(db *gorm.DB, userId string, logger *common.Logger) *api.ApiError {
+	user, e := getUser(db, userId, logger)
+	if e != nil {
+		return e
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return setDisabled(tx, user, true)
+	}); err != nil {
+		logger.PrintfError("Error deactivating user: %s. Error: %s", userId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("Deactivated user: %s", user.Id)
+
+	return nil
+}
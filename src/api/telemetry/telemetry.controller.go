@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTelemetryEndpoints(r *gin.RouterGroup) {
+	r.Use(middleware.LoggerMiddleware("Telemetry"))
+	r.Use(middleware.RateLimiter(1, 4))
+	r.POST("/connection", auth.AuthGuard(), ReportConnectionController)
+}
+
+func ReportConnectionController(c *gin.Context) {
+	payload, logger, _, _, errors := common.SetupEndpoint[ConnectionReportRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	ReportConnection(payload, user.(*auth.JWTAccessTokenPayload), logger)
+
+	c.JSON(http.StatusOK, gin.H{})
+}
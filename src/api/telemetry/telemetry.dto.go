@@ -0,0 +1,20 @@
+package telemetry
+
+import "time"
+
+type ConnectionReportRequest struct {
+	// round trip time and jitter measured by the client in milliseconds
+	RttMs    float64 `json:"rttMs" validate:"gte=0,lte=60000"`
+	JitterMs float64 `json:"jitterMs" validate:"gte=0,lte=60000"`
+}
+
+type ConnectionQualityResponse struct {
+	// refresh token random of the session, matches the current flag of GET /auth/sessions
+	Session  string  `json:"session"`
+	RttMs    float64 `json:"rttMs"`
+	JitterMs float64 `json:"jitterMs"`
+	// smoothed over all reports of the session like the TCP srtt
+	SmoothedRttMs float64   `json:"smoothedRttMs"`
+	Reports       int       `json:"reports"`
+	ReportedAt    time.Time `json:"reportedAt"`
+}
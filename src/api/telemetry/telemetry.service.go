@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sessions without a report for this long are dropped, clients report every few seconds while connected
+const connectionQualityTTL = 10 * time.Minute
+
+type connectionQuality struct {
+	userId string
+	ConnectionQualityResponse
+}
+
+// connection quality is only kept in memory, it describes live connections to this instance
+var connections = make(map[string]*connectionQuality)
+var connectionsMutex sync.Mutex
+var lastPrune time.Time
+
+// pruneConnections drops stale sessions, at most once a minute. The mutex has to be held.
+func pruneConnections(now time.Time) {
+	if now.Sub(lastPrune) < time.Minute {
+		return
+	}
+	lastPrune = now
+
+	for session, quality := range connections {
+		if now.Sub(quality.ReportedAt) > connectionQualityTTL {
+			delete(connections, session)
+		}
+	}
+}
+
+// ReportConnection records a quality report of the client session and adds it to the metrics histograms.
+func ReportConnection(payload *ConnectionReportRequest, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) {
+	metrics.ObserveConnectionQuality(payload.RttMs/1000, payload.JitterMs/1000)
+
+	// api keys and impersonation tokens have no session
+	if jwtPayload.RefreshRand == nil {
+		return
+	}
+	session := jwtPayload.RefreshRand.String()
+	now := time.Now()
+
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	pruneConnections(now)
+
+	quality, ok := connections[session]
+	if !ok {
+		quality = &connectionQuality{
+			userId: jwtPayload.UserId,
+			ConnectionQualityResponse: ConnectionQualityResponse{
+				Session:       session,
+				SmoothedRttMs: payload.RttMs,
+			},
+		}
+		connections[session] = quality
+	}
+
+	quality.RttMs = payload.RttMs
+	quality.JitterMs = payload.JitterMs
+	quality.SmoothedRttMs += (payload.RttMs - quality.SmoothedRttMs) / 8
+	quality.Reports++
+	quality.ReportedAt = now
+
+	logger.PrintfDebug("Connection of user: %s reported %.0fms rtt, %.0fms jitter", jwtPayload.UserId, payload.RttMs, payload.JitterMs)
+}
+
+// GetConnectionQuality returns the quality of the live sessions of the user, most recently reported first.
+func GetConnectionQuality(userId string) []ConnectionQualityResponse {
+	now := time.Now()
+
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	pruneConnections(now)
+
+	response := []ConnectionQualityResponse{}
+	for _, quality := range connections {
+		if quality.userId == userId && now.Sub(quality.ReportedAt) <= connectionQualityTTL {
+			response = append(response, quality.ConnectionQualityResponse)
+		}
+	}
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].ReportedAt.After(response[j].ReportedAt)
+	})
+
+	return response
+}
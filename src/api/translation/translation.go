@@ -0,0 +1,244 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"easyflow-backend/src/common"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider translates plaintext. Messages are end to end encrypted and never reach it,
+// only metadata the server can read, like the name and description of a chat.
+type Provider interface {
+	// Translate returns the texts in the target language, the source language is detected when it is empty.
+	Translate(ctx context.Context, texts []string, source string, target string) ([]string, error)
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// GetProvider returns the configured provider, ok is false when translation is disabled.
+func GetProvider(cfg *common.Config) (Provider, bool) {
+	switch cfg.TranslationProvider {
+	case "libretranslate":
+		if cfg.TranslationUrl == "" {
+			return nil, false
+		}
+		return &libreTranslate{url: strings.TrimSuffix(cfg.TranslationUrl, "/"), apiKey: cfg.TranslationApiKey}, true
+	case "deepl":
+		if cfg.TranslationApiKey == "" {
+			return nil, false
+		}
+		url := cfg.TranslationUrl
+		if url == "" {
+			url = "https://api-free.deepl.com"
+		}
+		return &deepL{url: strings.TrimSuffix(url, "/"), apiKey: cfg.TranslationApiKey}, true
+	default:
+		return nil, false
+	}
+}
+
+func Enabled(cfg *common.Config) bool {
+	_, ok := GetProvider(cfg)
+	return ok
+}
+
+type cacheEntry struct {
+	text      string
+	expiresAt time.Time
+}
+
+var cache = make(map[string]*cacheEntry)
+var cacheMutex sync.Mutex
+
+func cacheKey(text string, source string, target string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + target + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Translate translates the texts with the configured provider. Translations are reused for
+// TranslationCacheTTL seconds, so only texts that changed or were not requested yet reach the provider.
+func Translate(ctx context.Context, cfg *common.Config, texts []string, source string, target string) ([]string, error) {
+	provider, ok := GetProvider(cfg)
+	if !ok {
+		return nil, fmt.Errorf("translation is disabled")
+	}
+
+	result := make([]string, len(texts))
+	var missing []string
+	var missingIndexes []int
+
+	cacheMutex.Lock()
+	for i, text := range texts {
+		entry, ok := cache[cacheKey(text, source, target)]
+		if ok && time.Now().Before(entry.expiresAt) {
+			result[i] = entry.text
+			continue
+		}
+		missing = append(missing, text)
+		missingIndexes = append(missingIndexes, i)
+	}
+	cacheMutex.Unlock()
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	translated, err := provider.Translate(ctx, missing, source, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(translated) != len(missing) {
+		return nil, fmt.Errorf("provider returned %d translations for %d texts", len(translated), len(missing))
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	now := time.Now()
+	for key, entry := range cache {
+		if now.After(entry.expiresAt) {
+			delete(cache, key)
+		}
+	}
+
+	for i, text := range translated {
+		result[missingIndexes[i]] = text
+		if cfg.TranslationCacheTTL > 0 {
+			cache[cacheKey(missing[i], source, target)] = &cacheEntry{
+				text:      text,
+				expiresAt: now.Add(time.Duration(cfg.TranslationCacheTTL) * time.Second),
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func postJSON(ctx context.Context, url string, header http.Header, body interface{}, target interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered with status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+// libreTranslate uses the api of a (self hosted) LibreTranslate instance.
+type libreTranslate struct {
+	url    string
+	apiKey string
+}
+
+func (l *libreTranslate) Translate(ctx context.Context, texts []string, source string, target string) ([]string, error) {
+	if source == "" {
+		source = "auto"
+	}
+
+	var response struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	err := postJSON(ctx, l.url+"/translate", nil, map[string]interface{}{
+		"q":       texts,
+		"source":  source,
+		"target":  target,
+		"format":  "text",
+		"api_key": l.apiKey,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.TranslatedText, nil
+}
+
+// deepL uses the DeepL api, TranslationUrl selects between the free and the pro endpoint.
+type deepL struct {
+	url    string
+	apiKey string
+}
+
+// deepLTarget maps a language tag to a DeepL target language. DeepL only knows regional variants of English and
+// Portuguese and script variants of Chinese, the region of other languages is dropped.
+func deepLTarget(tag string) string {
+	parts := strings.Split(strings.ToLower(tag), "-")
+	language := parts[0]
+	subtags := parts[1:]
+
+	switch language {
+	case "en":
+		if slices.Contains(subtags, "gb") {
+			return "EN-GB"
+		}
+		return "EN-US"
+	case "pt":
+		if slices.Contains(subtags, "br") {
+			return "PT-BR"
+		}
+		return "PT-PT"
+	case "zh":
+		if slices.Contains(subtags, "hant") || slices.Contains(subtags, "tw") || slices.Contains(subtags, "hk") || slices.Contains(subtags, "mo") {
+			return "ZH-HANT"
+		}
+		return "ZH-HANS"
+	case "no":
+		return "NB"
+	}
+
+	return strings.ToUpper(language)
+}
+
+func (d *deepL) Translate(ctx context.Context, texts []string, source string, target string) ([]string, error) {
+	body := map[string]interface{}{
+		"text":        texts,
+		"target_lang": deepLTarget(target),
+	}
+	if source != "" {
+		// DeepL only accepts the primary language as source, e.g. "EN" instead of "EN-US"
+		body["source_lang"] = strings.ToUpper(strings.SplitN(source, "-", 2)[0])
+	}
+
+	var response struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	header := http.Header{"Authorization": []string{"DeepL-Auth-Key " + d.apiKey}}
+	if err := postJSON(ctx, d.url+"/v2/translate", header, body, &response); err != nil {
+		return nil, err
+	}
+
+	translated := make([]string, 0, len(response.Translations))
+	for _, translation := range response.Translations {
+		translated = append(translated, translation.Text)
+	}
+
+	return translated, nil
+}
@@ -0,0 +1,151 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/chat"
+	"easyflow-backend/src/api/s3"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeleteUser schedules the account for deletion and ends all sessions.
+// Logging in during the grace period restores it, afterwards purgeDeletedUsers removes its data.
+func DeleteUser(db *gorm.DB, cfg *common.Config, jwtPayload *auth.JWTAccessTokenPayload, client common.ClientInfo, logger *common.Logger) (*DeleteUserResponse, *api.ApiError) {
+	var user database.User
+	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
+		logger.PrintfError("Error getting user: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.NotFound,
+		}
+	}
+
+	purgeAt := time.Now().Add(time.Duration(cfg.AccountDeletionGracePeriod) * time.Second)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("deletion_scheduled_at", purgeAt).Error; err != nil {
+			return err
+		}
+		_, err := auth.EndSessions(tx, tx.Where("user_id = ?", user.Id))
+		return err
+	})
+	if err != nil {
+		logger.PrintfError("Error deleting user: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	audit.Record(db, logger, user.Id, enum.AccountDeleted, client, nil)
+
+	logger.Printf("Scheduled deletion of user: %s at %s", user.Id, purgeAt)
+
+	return &DeleteUserResponse{PurgeAt: purgeAt}, nil
+}
+
+// StartAccountPurge periodically purges accounts whose deletion grace period is over.
+func StartAccountPurge(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purgeDeletedUsers(db, cfg, logger)
+		}
+	}()
+}
+
+func purgeDeletedUsers(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	var users []database.User
+	if err := db.Where("deletion_scheduled_at < ?", time.Now()).Find(&users).Error; err != nil {
+		logger.PrintfError("Could not get deleted users: %s", err)
+		return
+	}
+
+	for _, user := range users {
+		if err := purgeUser(db, &user); err != nil {
+			logger.PrintfError("Could not purge user: %s. Error: %s", user.Id, err)
+			continue
+		}
+
+		for _, size := range append([]int{0}, profilePictureSizes...) {
+			if err := s3.DeleteObject(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(user.Id, size)); err != nil {
+				logger.PrintfWarning("Could not delete profile picture of purged user: %s", user.Id)
+			}
+		}
+		chat.InvalidateChatsOfUser(user.Id)
+
+		logger.Printf("Purged deleted user: %s", user.Id)
+	}
+}
+
+// purgeUser removes the personal data, keys, sessions and chat memberships of a user.
+// The row itself stays as an anonymous tombstone since messages and the append-only key log reference it.
+func purgeUser(db *gorm.DB, user *database.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var apiKeyIds []string
+		if err := tx.Model(&database.ApiKey{}).Where("user_id = ?", user.Id).Pluck("id", &apiKeyIds).Error; err != nil {
+			return err
+		}
+		if len(apiKeyIds) > 0 {
+			if err := tx.Where("api_key_id IN ?", apiKeyIds).Delete(&database.ApiKeyUsage{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("api_key_id IN ?", apiKeyIds).Delete(&database.ApiKeyQuota{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := chat.PromoteOwnerSuccessors(tx, user.Id); err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&database.ChatUserKeys{},
+			&database.UserKeys{},
+			&database.ChatBan{},
+			&database.ChatDeletionApproval{},
+			&database.WebAuthnCredential{},
+			&database.EmailVerificationToken{},
+			&database.MailSuppression{},
+			&database.NotificationSettings{},
+			&database.OAuthAccount{},
+			&database.KnownDevice{},
+			&database.ApiKey{},
+			&database.UploadNonce{},
+			&database.PasswordHistory{},
+			&database.StreamBandwidth{},
+			&database.AuditLog{},
+		} {
+			if err := tx.Where("user_id = ?", user.Id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("subject = ?", user.Id).Delete(&database.AbuseFlag{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(user).Updates(map[string]interface{}{
+			"email":                 "deleted-" + user.Id + "@deleted.invalid",
+			"email_verified":        false,
+			"name":                  "Deleted user",
+			"username":              nil,
+			"bio":                   nil,
+			"profile_picture":       nil,
+			"password":              "",
+			"public_key":            "",
+			"private_key":           "",
+			"iv":                    "",
+			"disabled":              true,
+			"deletion_scheduled_at": nil,
+		}).Error
+	})
+}
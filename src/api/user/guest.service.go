@@ -0,0 +1,118 @@
+package user
+
+import (
+	"easyflow-backend/src/antiabuse"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/chat"
+	"easyflow-backend/src/api/keylog"
+	"easyflow-backend/src/api/moderation"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// guests get an address of the reserved .invalid domain since emails are unique
+const guestEmailDomain = "guest.invalid"
+
+// CreateGuest creates a temporary account and logs it in. Guests have no password,
+// they stay logged in through their refresh token until they are purged.
+func CreateGuest(db *gorm.DB, cfg *common.Config, payload *CreateGuestRequest, client common.ClientInfo, logger *common.Logger) (auth.JWTPair, *api.ApiError) {
+	if !cfg.GuestAccounts {
+		return auth.JWTPair{}, &api.ApiError{
+			Code:    http.StatusForbidden,
+			Error:   enum.NotAllowed,
+			Details: "Guest accounts are disabled",
+		}
+	}
+
+	if antiabuse.CheckSignup(db, cfg, client.IP, logger) == antiabuse.Throttle {
+		return auth.JWTPair{}, &api.ApiError{
+			Code:  http.StatusTooManyRequests,
+			Error: enum.TooManyAttempts,
+		}
+	}
+
+	id := uuid.NewString()
+	name := "Guest " + id[:4]
+	if payload.Name != nil && *payload.Name != "" {
+		if err := moderation.CheckFields(cfg, logger, map[string]string{"name": *payload.Name}); err != nil {
+			return auth.JWTPair{}, err
+		}
+		name = *payload.Name
+	}
+
+	user := database.User{
+		Email:      "guest-" + id + "@" + guestEmailDomain,
+		Name:       name,
+		PublicKey:  payload.PublicKey,
+		PrivateKey: payload.PrivateKey,
+		Iv:         payload.Iv,
+		Guest:      true,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return keylog.Append(tx, user.Id, user.PublicKey)
+	})
+	if err != nil {
+		logger.PrintfError("Error creating guest: %s", err)
+		return auth.JWTPair{}, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	antiabuse.RecordSignup(db, cfg, client.IP, logger)
+	logger.Printf("Created guest: %s", user.Id)
+
+	return auth.LoginUser(db, cfg, &user, client, logger)
+}
+
+// StartGuestPurge periodically deletes guests whose sessions were not used within GUEST_INACTIVITY_TIMEOUT.
+func StartGuestPurge(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	if !cfg.GuestAccounts {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purgeInactiveGuests(db, cfg, logger)
+		}
+	}()
+}
+
+func purgeInactiveGuests(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	cutoff := time.Now().Add(-time.Duration(cfg.GuestInactivityTimeout) * time.Second)
+
+	var guests []database.User
+	// purged guests stay as disabled tombstones
+	if err := db.Where("guest = ? AND disabled = ? AND created_at < ?", true, false, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM user_keys WHERE user_keys.user_id = users.id AND user_keys.last_used_at > ?)", cutoff).
+		Find(&guests).Error; err != nil {
+		logger.PrintfError("Could not get inactive guests: %s", err)
+		return
+	}
+
+	for _, guest := range guests {
+		if err := purgeUser(db, &guest); err != nil {
+			logger.PrintfWarning("Could not purge guest: %s. Error: %s", guest.Id, err)
+			continue
+		}
+		chat.InvalidateChatsOfUser(guest.Id)
+	}
+
+	if len(guests) > 0 {
+		logger.Printf("Purged %d inactive guests", len(guests))
+	}
+}
@@ -0,0 +1,72 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func toNotificationSettingsResponse(settings *database.NotificationSettings) *NotificationSettingsResponse {
+	return &NotificationSettingsResponse{
+		Email:    settings.Email,
+		Push:     settings.Push,
+		Mentions: settings.Mentions,
+	}
+}
+
+func GetNotificationSettings(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*NotificationSettingsResponse, *api.ApiError) {
+	settings, err := database.GetNotificationSettings(db, jwtPayload.UserId)
+	if err != nil {
+		logger.PrintfError("Error getting notification settings of user: %s. Error: %s", jwtPayload.UserId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return toNotificationSettingsResponse(settings), nil
+}
+
+// UpdateNotificationSettings applies the given settings on top of the current ones and returns the result.
+func UpdateNotificationSettings(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, payload *UpdateNotificationSettingsRequest, logger *common.Logger) (*NotificationSettingsResponse, *api.ApiError) {
+	settings, err := database.GetNotificationSettings(db, jwtPayload.UserId)
+	if err != nil {
+		logger.PrintfError("Error getting notification settings of user: %s. Error: %s", jwtPayload.UserId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if payload.Email != nil {
+		settings.Email = *payload.Email
+	}
+	if payload.Push != nil {
+		settings.Push = *payload.Push
+	}
+	if payload.Mentions != nil {
+		settings.Mentions = *payload.Mentions
+	}
+
+	// the first change creates the settings, concurrent first changes update the same row
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "push", "mentions"}),
+	}).Create(settings).Error; err != nil {
+		logger.PrintfError("Error updating notification settings of user: %s. Error: %s", jwtPayload.UserId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("User: %s updated notification settings (email: %t, push: %t, mentions: %t)", jwtPayload.UserId, settings.Email, settings.Push, settings.Mentions)
+
+	return toNotificationSettingsResponse(settings), nil
+}
@@ -0,0 +1,142 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// bcrypt ignores everything after the first 72 bytes
+const bcryptMaxLength = 72
+
+// PasswordViolation is one rule of the password policy a password does not satisfy.
+type PasswordViolation struct {
+	// MIN_LENGTH, MAX_LENGTH, CHARACTER_CLASS, DENYLIST or REUSED
+	Rule  string `json:"rule"`
+	Limit int    `json:"limit,omitempty"`
+	// missing character class: lower, upper, digit or symbol
+	Class string `json:"class,omitempty"`
+}
+
+var characterClasses = map[string]func(rune) bool{
+	"lower":  unicode.IsLower,
+	"upper":  unicode.IsUpper,
+	"digit":  unicode.IsDigit,
+	"symbol": func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) },
+}
+
+// PasswordMaxLength is the configured maximum, bounded by what bcrypt can hash.
+func PasswordMaxLength(cfg *common.Config) int {
+	if cfg.PasswordMaxLength <= 0 {
+		return bcryptMaxLength
+	}
+	return min(cfg.PasswordMaxLength, bcryptMaxLength)
+}
+
+// checkPasswordPolicy validates a new password against the configured policy and, for existing users, their
+// current and previous passwords. All violations are returned at once, so clients can show them together.
+func checkPasswordPolicy(db *gorm.DB, cfg *common.Config, user *database.User, password string, logger *common.Logger) *api.ApiError {
+	violations := []PasswordViolation{}
+
+	if utf8.RuneCountInString(password) < cfg.PasswordMinLength {
+		violations = append(violations, PasswordViolation{Rule: "MIN_LENGTH", Limit: cfg.PasswordMinLength})
+	}
+	// in bytes, multi byte characters count more than once towards the bcrypt limit
+	if maxLength := PasswordMaxLength(cfg); len(password) > maxLength {
+		violations = append(violations, PasswordViolation{Rule: "MAX_LENGTH", Limit: maxLength})
+	}
+
+	for _, class := range cfg.PasswordRequiredClasses {
+		matches, ok := characterClasses[class]
+		if !ok {
+			logger.PrintfWarning("Unknown password character class: %q", class)
+			continue
+		}
+		if !strings.ContainsFunc(password, matches) {
+			violations = append(violations, PasswordViolation{Rule: "CHARACTER_CLASS", Class: class})
+		}
+	}
+
+	if slices.ContainsFunc(cfg.PasswordDenylist, func(denied string) bool { return strings.EqualFold(denied, password) }) {
+		violations = append(violations, PasswordViolation{Rule: "DENYLIST"})
+	}
+
+	if user != nil && cfg.PasswordHistory > 0 {
+		reused, err := isReusedPassword(db, cfg, user, password)
+		if err != nil {
+			logger.PrintfError("Could not get password history of user: %s. Error: %s", user.Id, err)
+			return &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+		if reused {
+			violations = append(violations, PasswordViolation{Rule: "REUSED", Limit: cfg.PasswordHistory})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	logger.PrintfDebug("Rejected password violating %d policy rules", len(violations))
+	return &api.ApiError{
+		Code:    http.StatusUnprocessableEntity,
+		Error:   enum.PasswordPolicy,
+		Details: violations,
+	}
+}
+
+// isReusedPassword compares the password with the current one and the last PASSWORD_HISTORY - 1 previous ones.
+func isReusedPassword(db *gorm.DB, cfg *common.Config, user *database.User, password string) (bool, error) {
+	hashes := []string{user.Password}
+
+	if cfg.PasswordHistory > 1 {
+		var previous []string
+		if err := db.Model(&database.PasswordHistory{}).Where("user_id = ?", user.Id).
+			Order("created_at desc").Limit(cfg.PasswordHistory-1).Pluck("hash", &previous).Error; err != nil {
+			return false, err
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// recordPasswordHistory keeps the replaced password hash and drops the ones beyond PASSWORD_HISTORY.
+func recordPasswordHistory(tx *gorm.DB, cfg *common.Config, user *database.User) error {
+	if cfg.PasswordHistory <= 1 || user.Password == "" {
+		return nil
+	}
+
+	if err := tx.Create(&database.PasswordHistory{UserId: user.Id, Hash: user.Password}).Error; err != nil {
+		return err
+	}
+
+	// pruned on every change, the current password is stored on the user so PASSWORD_HISTORY - 1 entries are kept
+	var ids []string
+	if err := tx.Model(&database.PasswordHistory{}).Where("user_id = ?", user.Id).
+		Order("created_at desc").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) < cfg.PasswordHistory {
+		return nil
+	}
+
+	return tx.Where("id IN ?", ids[cfg.PasswordHistory-1:]).Delete(&database.PasswordHistory{}).Error
+}
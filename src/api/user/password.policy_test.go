@@ -0,0 +1,215 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func testLogger() *common.Logger {
+	return common.NewLogger(io.Discard, "test", nil, common.ERROR)
+}
+
+func violatedRules(err *api.ApiError) []string {
+	if err == nil {
+		return []string{}
+	}
+	rules := []string{}
+	for _, violation := range err.Details.([]PasswordViolation) {
+		rule := violation.Rule
+		if violation.Class != "" {
+			rule += ":" + violation.Class
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	current, err := bcrypt.GenerateFromPassword([]byte("current password 1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &database.User{Id: uuid.NewString(), Password: string(current)}
+
+	tests := []struct {
+		name     string
+		password string
+		user     *database.User
+		classes  []string
+		denylist []string
+		history  int
+		maxLen   int
+		rules    []string
+	}{
+		{"valid", "long enough password", nil, nil, nil, 0, 0, []string{}},
+		{"too short", "short", nil, nil, nil, 0, 0, []string{"MIN_LENGTH"}},
+		{"too short in runes", "ääääääää", nil, nil, nil, 0, 0, []string{"MIN_LENGTH"}},
+		{"longer than bcrypt", strings.Repeat("a", 73), nil, nil, nil, 0, 0, []string{"MAX_LENGTH"}},
+		{"multi byte characters count in bytes", strings.Repeat("ä", 37), nil, nil, nil, 0, 0, []string{"MAX_LENGTH"}},
+		{"longer than configured", strings.Repeat("a", 21), nil, nil, nil, 0, 20, []string{"MAX_LENGTH"}},
+		{"configured above bcrypt", strings.Repeat("a", 73), nil, nil, nil, 0, 100, []string{"MAX_LENGTH"}},
+		{"all classes", "Long enough passw0rd!", nil, []string{"lower", "upper", "digit", "symbol"}, nil, 0, 0, []string{}},
+		{"missing classes", "long enough password", nil, []string{"lower", "upper", "digit"}, nil, 0, 0, []string{"CHARACTER_CLASS:upper", "CHARACTER_CLASS:digit"}},
+		{"unknown class is ignored", "long enough password", nil, []string{"emoji"}, nil, 0, 0, []string{}},
+		{"denied", "Correct Horse Battery", nil, nil, []string{"correct horse battery"}, 0, 0, []string{"DENYLIST"}},
+		{"all violations at once", "abc", nil, []string{"digit"}, []string{"abc"}, 0, 0, []string{"MIN_LENGTH", "CHARACTER_CLASS:digit", "DENYLIST"}},
+		{"current password without history", "current password 1", user, nil, nil, 0, 0, []string{}},
+		{"current password reused", "current password 1", user, nil, nil, 1, 0, []string{"REUSED"}},
+		{"new password", "another password 2", user, nil, nil, 1, 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &common.Config{
+				PasswordMinLength:       12,
+				PasswordMaxLength:       tt.maxLen,
+				PasswordRequiredClasses: tt.classes,
+				PasswordDenylist:        tt.denylist,
+				PasswordHistory:         tt.history,
+			}
+
+			// a history of one only compares with the current password, which needs no database
+			err := checkPasswordPolicy(nil, cfg, tt.user, tt.password, testLogger())
+			if got := violatedRules(err); !slices.Equal(got, tt.rules) {
+				t.Errorf("violated rules = %v, want %v", got, tt.rules)
+			}
+		})
+	}
+}
+
+// historyConn is a database connection that holds the password history of one user, newest first.
+// It answers the queries of recordPasswordHistory and records the ids it was asked to delete.
+type historyConn struct {
+	ids     []string
+	deleted []string
+}
+
+func (c *historyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *historyConn) Close() error { return nil }
+
+func (c *historyConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *historyConn) Commit() error { return nil }
+
+func (c *historyConn) Rollback() error { return nil }
+
+func (c *historyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO `password_histories` (`id`,"):
+		c.ids = append([]string{args[0].Value.(string)}, c.ids...)
+		return historyResult(1), nil
+	case strings.HasPrefix(query, "DELETE FROM `password_histories`"):
+		for _, arg := range args {
+			c.deleted = append(c.deleted, arg.Value.(string))
+		}
+		return historyResult(len(args)), nil
+	}
+	return nil, errors.New("unexpected statement: " + query)
+}
+
+func (c *historyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(query, "SELECT `id` FROM `password_histories`") {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	return &historyRows{ids: slices.Clone(c.ids)}, nil
+}
+
+// historyResult also answers LastInsertId, which driver.RowsAffected refuses
+type historyResult int64
+
+func (r historyResult) LastInsertId() (int64, error) { return 0, nil }
+
+func (r historyResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+type historyRows struct {
+	ids []string
+}
+
+func (r *historyRows) Columns() []string { return []string{"id"} }
+
+func (r *historyRows) Close() error { return nil }
+
+func (r *historyRows) Next(dest []driver.Value) error {
+	if len(r.ids) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.ids[0]
+	r.ids = r.ids[1:]
+	return nil
+}
+
+type historyConnector struct {
+	conn *historyConn
+}
+
+func (c historyConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+
+func (c historyConnector) Driver() driver.Driver { return nil }
+
+func historyDB(t *testing.T, conn *historyConn) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(historyConnector{conn: conn}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRecordPasswordHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  int
+		password string
+		// ids of previous hashes, newest first
+		ids     []string
+		kept    int
+		deleted []string
+	}{
+		{"disabled", 0, "hash", []string{"a"}, 1, nil},
+		{"only the current password", 1, "hash", []string{"a"}, 1, nil},
+		{"no password", 3, "", []string{"a"}, 1, nil},
+		{"below the limit", 3, "hash", []string{"a"}, 2, nil},
+		{"at the limit", 3, "hash", []string{"a", "b"}, 2, []string{"b"}},
+		{"limit was lowered", 3, "hash", []string{"a", "b", "c", "d"}, 2, []string{"b", "c", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &historyConn{ids: slices.Clone(tt.ids)}
+			cfg := &common.Config{PasswordHistory: tt.history}
+			user := &database.User{Id: uuid.NewString(), Password: tt.password}
+
+			if err := recordPasswordHistory(historyDB(t, conn), cfg, user); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(conn.deleted, tt.deleted) {
+				t.Errorf("deleted %v, want %v", conn.deleted, tt.deleted)
+			}
+			if kept := len(conn.ids) - len(conn.deleted); kept != tt.kept {
+				t.Errorf("kept %d hashes, want %d", kept, tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/enum"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const breachedPasswordRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// isBreachedPassword asks the Have I Been Pwned range api whether the password appeared in a breach.
+// Only the first five characters of the SHA-1 hash leave the server (k-anonymity).
+func isBreachedPassword(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, breachedPasswordRangeURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// padded responses hide the number of matching suffixes
+	req.Header.Set("Add-Padding", "true")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		candidate, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// padding entries have a count of 0
+		if candidate == suffix && count != "0" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// checkBreachedPassword rejects passwords known to be compromised.
+// It fails open, signups and password changes keep working when the api is slow or unreachable.
+func checkBreachedPassword(cfg *common.Config, password string, logger *common.Logger) *api.ApiError {
+	if !cfg.BreachedPasswordCheck {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.BreachedPasswordTimeout)*time.Millisecond)
+	defer cancel()
+
+	breached, err := isBreachedPassword(ctx, password)
+	if err != nil {
+		logger.PrintfWarning("Could not check password against breaches, skipping the check: %s", err)
+		return nil
+	}
+
+	if breached {
+		logger.PrintfWarning("Rejected compromised password")
+		return &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.CompromisedPassword,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// most user ids per presence request
+const maxPresenceIds = 100
+
+// GetPresence returns online status and last seen of the given users. Only users sharing a chat with the requester
+// in which they do not hide their presence, and not hiding it globally, are shown with their status.
+// Everyone else is reported offline without last seen. Unknown ids are omitted.
+func GetPresence(db *gorm.DB, cfg *common.Config, jwtPayload *auth.JWTAccessTokenPayload, query *PresenceRequest, logger *common.Logger) ([]PresenceEntry, *api.ApiError) {
+	ids := slices.Compact(slices.Sorted(slices.Values(strings.Split(query.Ids, ","))))
+	if len(ids) > maxPresenceIds {
+		return nil, &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: fmt.Sprintf("At most %d user ids are allowed", maxPresenceIds),
+		}
+	}
+	for _, id := range ids {
+		if err := api.Validate.Var(id, "uuid"); err != nil {
+			return nil, &api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Invalid user id: %s", id),
+			}
+		}
+	}
+
+	var users []database.User
+	if err := db.Select("id", "last_seen_at", "hide_presence").Where("id IN ? AND deletion_scheduled_at IS NULL", ids).
+		Find(&users).Error; err != nil {
+		logger.PrintfError("Error getting presence of users: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	var shared []string
+	if err := db.Model(&database.ChatUserKeys{}).
+		Joins("JOIN chat_user_keys AS own ON own.chat_id = chat_user_keys.chat_id AND own.user_id = ?", jwtPayload.UserId).
+		Where("chat_user_keys.user_id IN ? AND chat_user_keys.hide_presence = ?", ids, false).Distinct().Pluck("chat_user_keys.user_id", &shared).Error; err != nil {
+		logger.PrintfError("Error getting shared chats of user: %s. Error: %s", jwtPayload.UserId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	onlineSince := time.Now().Add(-time.Duration(cfg.PresenceOnlineWindow) * time.Second)
+	entries := make([]PresenceEntry, 0, len(users))
+	for _, user := range users {
+		entry := PresenceEntry{UserId: user.Id, Status: PresenceOffline}
+
+		visible := user.Id == jwtPayload.UserId || (!user.HidePresence && slices.Contains(shared, user.Id))
+		if visible && user.LastSeenAt != nil {
+			entry.LastSeenAt = user.LastSeenAt
+			if user.LastSeenAt.After(onlineSince) {
+				entry.Status = PresenceOnline
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// SetPresenceVisibility hides or shows the presence of the user to others.
+func SetPresenceVisibility(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, payload *SetPresenceVisibilityRequest, logger *common.Logger) *api.ApiError {
+	if err := db.Model(&database.User{}).Where("id = ?", jwtPayload.UserId).Update("hide_presence", payload.Hidden).Error; err != nil {
+		logger.PrintfError("Error setting presence visibility of user: %s. Error: %s", jwtPayload.UserId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("User: %s set presence hidden to %t", jwtPayload.UserId, payload.Hidden)
+
+	return nil
+}
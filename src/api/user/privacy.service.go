@@ -0,0 +1,62 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+func getPrivacySettings(db *gorm.DB, userId string, logger *common.Logger) (*PrivacySettingsResponse, *api.ApiError) {
+	var user database.User
+	if err := db.Select("discoverable_by_email", "read_receipts", "chat_invites").Where("id = ?", userId).First(&user).Error; err != nil {
+		logger.PrintfError("Error getting privacy settings of user: %s. Error: %s", userId, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return &PrivacySettingsResponse{
+		DiscoverableByEmail: user.DiscoverableByEmail,
+		ReadReceipts:        user.ReadReceipts,
+		ChatInvites:         user.ChatInvites,
+	}, nil
+}
+
+func GetPrivacySettings(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) (*PrivacySettingsResponse, *api.ApiError) {
+	return getPrivacySettings(db, jwtPayload.UserId, logger)
+}
+
+// UpdatePrivacySettings applies the given settings and returns the result. Users that are not discoverable
+// are left out of POST /user/contacts/discover, the chat invite setting is checked by chat.CreateChat.
+func UpdatePrivacySettings(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, payload *UpdatePrivacySettingsRequest, logger *common.Logger) (*PrivacySettingsResponse, *api.ApiError) {
+	fields := map[string]interface{}{}
+	if payload.DiscoverableByEmail != nil {
+		fields["discoverable_by_email"] = *payload.DiscoverableByEmail
+	}
+	if payload.ReadReceipts != nil {
+		fields["read_receipts"] = *payload.ReadReceipts
+	}
+	if payload.ChatInvites != nil {
+		fields["chat_invites"] = *payload.ChatInvites
+	}
+
+	if len(fields) > 0 {
+		if err := db.Model(&database.User{}).Where("id = ?", jwtPayload.UserId).Updates(fields).Error; err != nil {
+			logger.PrintfError("Error updating privacy settings of user: %s. Error: %s", jwtPayload.UserId, err)
+			return nil, &api.ApiError{
+				Code:  http.StatusInternalServerError,
+				Error: enum.ApiError,
+			}
+		}
+
+		logger.Printf("User: %s updated privacy settings %v", jwtPayload.UserId, fields)
+	}
+
+	return getPrivacySettings(db, jwtPayload.UserId, logger)
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+var domainLimiterMap = make(map[string]*rate.Limiter)
+var domainLimiterMapMutex sync.Mutex
+
+// returns the signup rate limiter for the email domain.
+func getDomainLimiter(cfg *common.Config, domain string) *rate.Limiter {
+	domainLimiterMapMutex.Lock()
+	defer domainLimiterMapMutex.Unlock()
+
+	limiter, ok := domainLimiterMap[domain]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Hour/time.Duration(cfg.SignupDomainRate)), cfg.SignupDomainBurst)
+		domainLimiterMap[domain] = limiter
+	}
+	return limiter
+}
+
+// matchesDomain reports whether domain is one of the entries or a subdomain of one.
+func matchesDomain(domain string, entries []string) bool {
+	for _, entry := range entries {
+		entry = strings.ToLower(entry)
+		if domain == entry || strings.HasSuffix(domain, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEmailDomain applies the domain allow- and blocklist, the MX check and the per-domain signup rate.
+func checkEmailDomain(cfg *common.Config, email string, logger *common.Logger) *api.ApiError {
+	_, domain, _ := strings.Cut(email, "@")
+	domain = strings.ToLower(domain)
+
+	notAllowed := &api.ApiError{
+		Code:  http.StatusForbidden,
+		Error: enum.EmailDomainNotAllowed,
+	}
+
+	if len(cfg.SignupDomainAllowlist) > 0 && !matchesDomain(domain, cfg.SignupDomainAllowlist) {
+		logger.PrintfWarning("Rejected signup from domain %s, not on the allowlist", domain)
+		return notAllowed
+	}
+	if matchesDomain(domain, cfg.SignupDomainBlocklist) {
+		logger.PrintfWarning("Rejected signup from blocked domain %s", domain)
+		return notAllowed
+	}
+
+	if cfg.SignupRequireMX {
+		records, err := net.LookupMX(domain)
+		if err != nil || len(records) == 0 {
+			logger.PrintfWarning("Rejected signup from domain %s without mx records: %v", domain, err)
+			return notAllowed
+		}
+	}
+
+	if cfg.SignupDomainRate > 0 && !getDomainLimiter(cfg, domain).Allow() {
+		logger.PrintfWarning("Rejected signup from domain %s, too many signups", domain)
+		return &api.ApiError{
+			Code:  http.StatusTooManyRequests,
+			Error: enum.TooManyAttempts,
+		}
+	}
+
+	return nil
+}
+
+// StartEmailFilter builds the filter of registered emails and rebuilds it every EMAIL_FILTER_REBUILD_INTERVAL,
+// which also drops the emails of purged users.
+func StartEmailFilter(db *gorm.DB, cfg *common.Config, logger *common.Logger) {
+	if !cfg.EmailFilter {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.EmailFilterRebuildInterval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			if err := database.RebuildEmailFilter(db, cfg.EmailFilterFalsePositiveRate); err != nil {
+				logger.PrintfError("Could not rebuild email filter: %s", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,94 @@
+package user
+
+import (
+	"bytes"
+	"easyflow-backend/src/api/s3"
+	"easyflow-backend/src/common"
+	"fmt"
+	"image"
+	"image/png"
+	"strconv"
+
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// edge lengths of the square profile picture variants, see GET /user/profile-picture?size=
+var profilePictureSizes = []int{64, 256}
+
+// larger uploads are not decoded, a small compressed file can expand to gigabytes of pixels
+const maxProfilePicturePixels = 8192 * 8192
+
+// thumbnails are generated by at most this many workers at a time, resizing is cpu bound
+var thumbnailWorkers = make(chan struct{}, 2)
+
+// profilePictureKey is the deterministic object key of a variant, size 0 is the original upload.
+func profilePictureKey(userId string, size int) string {
+	if size == 0 {
+		return userId
+	}
+	return "thumbnails/" + userId + "/" + strconv.Itoa(size)
+}
+
+// generateThumbnails stores the resized variants of the profile picture of the user in the background.
+// Until they exist the profile picture endpoint falls back to the original.
+func generateThumbnails(cfg *common.Config, userId string, logger *common.Logger) {
+	logger = logger.Detached()
+	go func() {
+		thumbnailWorkers <- struct{}{}
+		defer func() { <-thumbnailWorkers }()
+
+		if err := resizeProfilePicture(cfg, userId, logger); err != nil {
+			logger.PrintfError("Could not generate profile picture thumbnails of user: %s. Error: %s", userId, err)
+			return
+		}
+
+		logger.Printf("Generated profile picture thumbnails of user: %s", userId)
+	}()
+}
+
+func resizeProfilePicture(cfg *common.Config, userId string, logger *common.Logger) error {
+	content, e := s3.GetObject(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(userId, 0))
+	if e != nil {
+		return fmt.Errorf("could not download the original: %s", e.Error)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > maxProfilePicturePixels {
+		return fmt.Errorf("the original has %dx%d pixels", config.Width, config.Height)
+	}
+
+	original, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	// variants are square, the center of the original is cropped
+	bounds := original.Bounds()
+	edge := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, edge, edge).Add(bounds.Min).Add(image.Pt((bounds.Dx()-edge)/2, (bounds.Dy()-edge)/2))
+
+	for _, size := range profilePictureSizes {
+		// smaller originals are not upscaled
+		edgeLength := min(size, edge)
+		thumbnail := image.NewRGBA(image.Rect(0, 0, edgeLength, edgeLength))
+		draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), original, crop, draw.Src, nil)
+
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, thumbnail); err != nil {
+			return err
+		}
+
+		if e := s3.UploadObject(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(userId, size), encoded.Bytes(), "image/png"); e != nil {
+			return fmt.Errorf("could not upload the %dpx variant: %s", size, e.Error)
+		}
+	}
+
+	return nil
+}
@@ -15,12 +15,29 @@ func RegisterUserEndpoints(r *gin.RouterGroup) {
 	r.Use(middleware.LoggerMiddleware("User"))
 	r.Use(middleware.RateLimiter(1, 4))
 	r.POST("/signup", middleware.RateLimiter(1, 0), CreateUserController)
+	r.POST("/guest", middleware.RateLimiter(1, 0), CreateGuestController)
 	r.GET("/", auth.AuthGuard(), GetUserController)
 	r.GET("/exists/:email", UserExists)
+	r.POST("/contacts/discover", middleware.RateLimiter(1, 0), auth.AuthGuard(), auth.VerifiedGuard(), DiscoverContactsController)
+	r.GET("/by-username/:username", middleware.RateLimiter(1, 0), auth.AuthGuard(), GetUserByUsernameController)
+	r.PUT("/username", auth.AuthGuard(), auth.SessionGuard(), SetUsernameController)
+	r.GET("/login-history", auth.AuthGuard(), GetLoginHistoryController)
+	r.GET("/audit", auth.AuthGuard(), GetAuditLogController)
+	r.GET("/presence", auth.AuthGuard(), GetPresenceController)
+	r.PUT("/presence", auth.AuthGuard(), SetPresenceVisibilityController)
+	r.GET("/privacy", auth.AuthGuard(), GetPrivacySettingsController)
+	r.PUT("/privacy", auth.AuthGuard(), auth.SessionGuard(), UpdatePrivacySettingsController)
+	r.GET("/notifications", auth.AuthGuard(), GetNotificationSettingsController)
+	r.PUT("/notifications", auth.AuthGuard(), UpdateNotificationSettingsController)
+	r.GET("/verify/:token", VerifyEmailController)
+	r.POST("/verify/resend", auth.AuthGuard(), ResendVerificationMailController)
 	r.GET("/profile-picture", auth.AuthGuard(), GetProfilePictureController)
 	r.GET("/upload-profile-picture", auth.AuthGuard(), GenerateUploadProfilePictureURLController)
-	r.PUT("/", auth.AuthGuard(), UpdateUserController)
-	r.DELETE("/", auth.AuthGuard(), DeleteUserController)
+	r.POST("/uploads/confirm", auth.AuthGuard(), ConfirmUploadController)
+	r.POST("/profile-picture/confirm", auth.AuthGuard(), ConfirmUploadController)
+	r.PUT("/", auth.AuthGuard(), auth.SessionGuard(), UpdateUserController)
+	r.PUT("/password", auth.AuthGuard(), auth.SessionGuard(), ChangePasswordController)
+	r.DELETE("/", auth.AuthGuard(), auth.SessionGuard(), DeleteUserController)
 }
 
 func CreateUserController(c *gin.Context) {
@@ -34,14 +51,48 @@ func CreateUserController(c *gin.Context) {
 		return
 	}
 
-	user, err := CreateUser(db, payload, cfg, logger)
+	user, err := CreateUser(db, payload, cfg, common.GetClientInfo(c), logger)
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
 	}
+
+	// new and registered emails get the same answer, only new ones get a verification mail
+	if cfg.EmailEnumerationProtection {
+		c.JSON(http.StatusAccepted, gin.H{})
+		return
+	}
 	c.JSON(200, user)
 }
 
+func CreateGuestController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[CreateGuestRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	tokens, err := CreateGuest(db, cfg, payload, common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	auth.WriteTokens(c, cfg, tokens)
+}
+
 func GetUserController(c *gin.Context) {
 	_, logger, db, _, errors := common.SetupEndpoint[any](c)
 	if errors != nil {
@@ -91,7 +142,25 @@ func GetProfilePictureController(c *gin.Context) {
 		return
 	}
 
-	imageURL, err := GenerateGetProfilePictureURL(db, user.(*auth.JWTAccessTokenPayload), logger, cfg)
+	var query ProfilePictureRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	imageURL, err := GenerateGetProfilePictureURL(db, user.(*auth.JWTAccessTokenPayload), query.Size, logger, cfg)
 
 	if err != nil {
 		c.JSON(err.Code, err)
@@ -102,7 +171,7 @@ func GetProfilePictureController(c *gin.Context) {
 }
 
 func UserExists(c *gin.Context) {
-	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
 	if errors != nil {
 		c.JSON(http.StatusInternalServerError, api.ApiError{
 			Code:    http.StatusInternalServerError,
@@ -112,6 +181,15 @@ func UserExists(c *gin.Context) {
 		return
 	}
 
+	if cfg.EmailEnumerationProtection {
+		c.JSON(http.StatusForbidden, api.ApiError{
+			Code:    http.StatusForbidden,
+			Error:   enum.NotAllowed,
+			Details: "Use POST /user/contacts/discover",
+		})
+		return
+	}
+
 	email := c.Param("email")
 	if email == ":email" {
 		c.JSON(http.StatusBadRequest, api.ApiError{
@@ -131,8 +209,36 @@ func UserExists(c *gin.Context) {
 	c.JSON(200, userInDb)
 }
 
+func DiscoverContactsController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[DiscoverContactsRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	contacts, err := DiscoverContacts(db, payload, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contacts)
+}
+
 func UpdateUserController(c *gin.Context) {
-	payload, logger, db, _, errors := common.SetupEndpoint[UpdateUserRequest](c)
+	payload, logger, db, cfg, errors := common.SetupEndpoint[UpdateUserRequest](c)
 	if errors != nil {
 		c.JSON(http.StatusInternalServerError, api.ApiError{
 			Code:    http.StatusInternalServerError,
@@ -150,7 +256,7 @@ func UpdateUserController(c *gin.Context) {
 		})
 	}
 
-	updatedUser, err := UpdateUser(db, user.(*auth.JWTAccessTokenPayload), payload, logger)
+	updatedUser, err := UpdateUser(db, cfg, user.(*auth.JWTAccessTokenPayload), payload, common.GetClientInfo(c), logger)
 
 	if err != nil {
 		c.JSON(err.Code, err)
@@ -160,6 +266,35 @@ func UpdateUserController(c *gin.Context) {
 	c.JSON(200, updatedUser)
 }
 
+func ChangePasswordController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[ChangePasswordRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	err := ChangePassword(db, cfg, user.(*auth.JWTAccessTokenPayload), payload, common.GetClientInfo(c), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
 func GenerateUploadProfilePictureURLController(c *gin.Context) {
 	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
 	if errors != nil {
@@ -179,7 +314,25 @@ func GenerateUploadProfilePictureURLController(c *gin.Context) {
 		})
 	}
 
-	uploadURL, err := GenerateUploadProfilePictureURL(db, user.(*auth.JWTAccessTokenPayload), logger, cfg)
+	var query UploadProfilePictureRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	uploadURL, err := GenerateUploadProfilePictureURL(db, user.(*auth.JWTAccessTokenPayload), &query, logger, cfg)
 
 	if err != nil {
 		c.JSON(err.Code, err)
@@ -189,8 +342,45 @@ func GenerateUploadProfilePictureURLController(c *gin.Context) {
 	c.JSON(200, uploadURL)
 }
 
+func ConfirmUploadController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[ConfirmUploadRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	imageURL, err := ConfirmUpload(db, user.(*auth.JWTAccessTokenPayload), payload, logger, cfg)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(200, imageURL)
+}
+
 func DeleteUserController(c *gin.Context) {
-	_, logger, db, _, errors := common.SetupEndpoint[CreateUserRequest](c)
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
 	if errors != nil {
 		c.JSON(http.StatusInternalServerError, api.ApiError{
 			Code:    http.StatusInternalServerError,
@@ -209,8 +399,61 @@ func DeleteUserController(c *gin.Context) {
 		return
 	}
 
-	err := DeleteUser(db, user.(*auth.JWTAccessTokenPayload), logger)
+	response, err := DeleteUser(db, cfg, user.(*auth.JWTAccessTokenPayload), common.GetClientInfo(c), logger)
+
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("access_token", "", -1, "/", cfg.Domain, cfg.Stage == "production", true)
+	c.SetCookie("refresh_token", "", -1, "/", cfg.Domain, cfg.Stage == "production", true)
+
+	c.JSON(200, response)
+}
+
+func GetLoginHistoryController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	history, err := GetLoginHistory(db, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(200, history)
+}
+
+func VerifyEmailController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
 
+	err := VerifyEmail(db, c.Param("token"), common.GetClientInfo(c), logger)
 	if err != nil {
 		c.JSON(err.Code, err)
 		return
@@ -218,3 +461,350 @@ func DeleteUserController(c *gin.Context) {
 
 	c.JSON(200, gin.H{})
 }
+
+func ResendVerificationMailController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	err := ResendVerificationMail(db, cfg, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+func GetAuditLogController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	var query AuditLogRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	log, err := GetAuditLog(db, user.(*auth.JWTAccessTokenPayload), &query, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(200, log)
+}
+
+func GetPresenceController(c *gin.Context) {
+	_, logger, db, cfg, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	var query PresenceRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+	if err := api.Validate.Struct(query); err != nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: api.TranslateError(err),
+		})
+		return
+	}
+
+	presence, err := GetPresence(db, cfg, user.(*auth.JWTAccessTokenPayload), &query, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
+
+func SetPresenceVisibilityController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[SetPresenceVisibilityRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := SetPresenceVisibility(db, user.(*auth.JWTAccessTokenPayload), payload, logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func GetNotificationSettingsController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	settings, err := GetNotificationSettings(db, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdateNotificationSettingsController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[UpdateNotificationSettingsRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	settings, err := UpdateNotificationSettings(db, user.(*auth.JWTAccessTokenPayload), payload, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func GetUserByUsernameController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, err := GetUserByUsername(db, c.Param("username"), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func SetUsernameController(c *gin.Context) {
+	payload, logger, db, cfg, errors := common.SetupEndpoint[SetUsernameRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	if err := SetUsername(db, cfg, user.(*auth.JWTAccessTokenPayload), payload, logger); err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func GetPrivacySettingsController(c *gin.Context) {
+	_, logger, db, _, errors := common.SetupEndpoint[any](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	settings, err := GetPrivacySettings(db, user.(*auth.JWTAccessTokenPayload), logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdatePrivacySettingsController(c *gin.Context) {
+	payload, logger, db, _, errors := common.SetupEndpoint[UpdatePrivacySettingsRequest](c)
+	if errors != nil {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:    http.StatusInternalServerError,
+			Error:   enum.ApiError,
+			Details: errors,
+		})
+		return
+	}
+
+	if payload == nil {
+		c.JSON(http.StatusBadRequest, api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.MalformedRequest,
+		})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusInternalServerError, api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		})
+		return
+	}
+
+	settings, err := UpdatePrivacySettings(db, user.(*auth.JWTAccessTokenPayload), payload, logger)
+	if err != nil {
+		c.JSON(err.Code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
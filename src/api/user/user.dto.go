@@ -1,16 +1,63 @@
 package user
 
-import "time"
+import (
+	"easyflow-backend/src/enum"
+	"time"
+)
 
 type CreateUserRequest struct {
 	Email      string `json:"email" validate:"required,email"`
 	Name       string `json:"name" validate:"required,lte=50"`
-	Password   string `json:"password" validate:"required,gte=12"`
+	Password   string `json:"password" validate:"required"`
 	PublicKey  string `json:"publicKey" validate:"required"`
 	PrivateKey string `json:"privateKey" validate:"required"`
 	Iv         string `json:"iv" validate:"required,lte=16"`
 }
 
+// CreateGuestRequest contains the keys generated by the client, the private key is encrypted with a secret kept on the device.
+type CreateGuestRequest struct {
+	Name       *string `json:"name" validate:"omitempty,lte=50"`
+	PublicKey  string  `json:"publicKey" validate:"required"`
+	PrivateKey string  `json:"privateKey" validate:"required"`
+	Iv         string  `json:"iv" validate:"required,lte=16"`
+}
+
+type DiscoverContactsRequest struct {
+	Emails []string `json:"emails" validate:"required,gte=1,lte=50,dive,email"`
+}
+
+type PrivacySettingsResponse struct {
+	DiscoverableByEmail bool             `json:"discoverableByEmail"`
+	ReadReceipts        bool             `json:"readReceipts"`
+	ChatInvites         enum.ChatInvites `json:"chatInvites"`
+}
+
+// UpdatePrivacySettingsRequest changes the given settings, omitted ones are kept.
+type UpdatePrivacySettingsRequest struct {
+	DiscoverableByEmail *bool             `json:"discoverableByEmail"`
+	ReadReceipts        *bool             `json:"readReceipts"`
+	ChatInvites         *enum.ChatInvites `json:"chatInvites" validate:"omitempty,oneof=everyone contacts nobody"`
+}
+
+type SetUsernameRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=30"`
+}
+
+// UserHandleEntry is the public profile behind a username, it never contains the email.
+type UserHandleEntry struct {
+	Id        string `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
+
+type ContactEntry struct {
+	Email     string `json:"email"`
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
+
 type CreateUserResponse struct {
 	Id        string `json:"id"`
 	CreatedAt string `json:"createdAt"`
@@ -35,6 +82,44 @@ type CheckIfuserExistsRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+// ChangePasswordRequest contains the private key encrypted with the new password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required"`
+	PrivateKey      string `json:"privateKey" validate:"required"`
+	Iv              string `json:"iv" validate:"required,lte=16"`
+}
+
+type ProfilePictureRequest struct {
+	// edge length of a square variant, omitted returns the original
+	Size int `form:"size" validate:"omitempty,oneof=64 256"`
+}
+
+type UploadProfilePictureRequest struct {
+	// the upload has to send it as Content-Type header
+	ContentType string `form:"contentType" validate:"required,oneof=image/png image/jpeg image/webp image/gif"`
+	// optional hex encoded SHA-256 of the picture, the upload then has to send it base64 encoded in x-amz-checksum-sha256
+	Sha256 string `form:"sha256" validate:"omitempty,hexadecimal,len=64"`
+}
+
+type UploadProfilePictureResponse struct {
+	UploadURL string `json:"uploadUrl"`
+	// has to be sent to /user/uploads/confirm after the upload
+	Token string `json:"token"`
+	// largest accepted upload in bytes
+	MaxSize   int64 `json:"maxSize"`
+	ExpiresIn int   `json:"expiresIn"`
+}
+
+type ConfirmUploadRequest struct {
+	Token string `json:"token" validate:"required,lte=4096"`
+}
+
+type DeleteUserResponse struct {
+	// logging in before this time restores the account
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
 type UpdateUserRequest struct {
 	Name           *string `json:"name" validate:"omitempty,lte=50"`
 	Bio            *string `json:"bio" validate:"omitempty,lte=1000"`
@@ -50,3 +135,62 @@ type UpdateUserResponse struct {
 	Bio            *string   `json:"bio"`
 	ProfilePicture *string   `json:"profilePicture"`
 }
+
+type LoginHistoryEntry struct {
+	Time      time.Time `json:"time"`
+	Success   bool      `json:"success"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+}
+
+type AuditLogRequest struct {
+	Page int `form:"page" validate:"omitempty,gte=0"`
+}
+
+type AuditLogEntry struct {
+	Time      time.Time        `json:"time"`
+	Action    enum.AuditAction `json:"action"`
+	Details   *string          `json:"details,omitempty"`
+	IP        string           `json:"ip"`
+	UserAgent string           `json:"userAgent"`
+	Country   string           `json:"country,omitempty"`
+	City      string           `json:"city,omitempty"`
+}
+
+type PresenceRequest struct {
+	// comma separated user ids
+	Ids string `form:"ids" validate:"required"`
+}
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+type PresenceEntry struct {
+	UserId string         `json:"userId"`
+	Status PresenceStatus `json:"status"`
+	// omitted for users that hide their presence
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+type SetPresenceVisibilityRequest struct {
+	Hidden bool `json:"hidden"`
+}
+
+type NotificationSettingsResponse struct {
+	Email    bool `json:"email"`
+	Push     bool `json:"push"`
+	Mentions bool `json:"mentions"`
+}
+
+// UpdateNotificationSettingsRequest changes the given settings, omitted ones are kept.
+type UpdateNotificationSettingsRequest struct {
+	Email    *bool `json:"email"`
+	Push     *bool `json:"push"`
+	Mentions *bool `json:"mentions"`
+}
@@ -1,30 +1,77 @@
 package user
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"easyflow-backend/src/antiabuse"
 	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
 	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/api/chat"
+	"easyflow-backend/src/api/keylog"
+	"easyflow-backend/src/api/moderation"
 	"easyflow-backend/src/api/s3"
 	"easyflow-backend/src/common"
 	"easyflow-backend/src/database"
 	"easyflow-backend/src/enum"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
-func CreateUser(db *gorm.DB, payload *CreateUserRequest, cfg *common.Config, logger *common.Logger) (*database.User, *api.ApiError) {
-	var user database.User
-	if err := db.Where("email = ?", payload.Email).First(&user).Error; err == nil {
-		logger.PrintfError("User with email: %s already exists", payload.Email)
+// CreateUser returns neither a user nor an error for registered emails with EMAIL_ENUMERATION_PROTECTION.
+func CreateUser(db *gorm.DB, payload *CreateUserRequest, cfg *common.Config, client common.ClientInfo, logger *common.Logger) (*database.User, *api.ApiError) {
+	if err := moderation.CheckFields(cfg, logger, map[string]string{"name": payload.Name}); err != nil {
+		return nil, err
+	}
+
+	if antiabuse.CheckSignup(db, cfg, client.IP, logger) == antiabuse.Throttle {
 		return nil, &api.ApiError{
-			Code:  http.StatusConflict,
-			Error: enum.AlreadyExists,
+			Code:  http.StatusTooManyRequests,
+			Error: enum.TooManyAttempts,
+		}
+	}
+
+	if cfg.EmailEnumerationProtection {
+		defer common.PadDuration(time.Now(), time.Duration(cfg.EnumerationMinResponseTime)*time.Millisecond)
+	}
+
+	// with EMAIL_ENUMERATION_PROTECTION registered emails pass all checks like new ones and get the same response
+	exists := false
+	var user database.User
+	if database.EmailMightExist(payload.Email) {
+		if err := db.Where("email = ?", payload.Email).First(&user).Error; err == nil {
+			logger.PrintfError("User with email: %s already exists", payload.Email)
+			if !cfg.EmailEnumerationProtection {
+				return nil, &api.ApiError{
+					Code:  http.StatusConflict,
+					Error: enum.AlreadyExists,
+				}
+			}
+			exists = true
 		}
 	}
 
+	if err := checkEmailDomain(cfg, payload.Email, logger); err != nil {
+		return nil, err
+	}
+
+	if err := checkPasswordPolicy(db, cfg, nil, payload.Password, logger); err != nil {
+		return nil, err
+	}
+
+	if err := checkBreachedPassword(cfg, payload.Password, logger); err != nil {
+		return nil, err
+	}
+
 	password, err := bcrypt.GenerateFromPassword([]byte(payload.Password), cfg.SaltRounds)
 	if err != nil {
 		logger.PrintfError("Error hashing password: %s", err)
@@ -34,6 +81,12 @@ func CreateUser(db *gorm.DB, payload *CreateUserRequest, cfg *common.Config, log
 		}
 	}
 
+	// registered emails count like new ones, otherwise the throttling would tell them apart
+	if exists {
+		antiabuse.RecordSignup(db, cfg, client.IP, logger)
+		return nil, nil
+	}
+
 	//create a new user
 	user = database.User{
 		Email:      payload.Email,
@@ -44,7 +97,25 @@ func CreateUser(db *gorm.DB, payload *CreateUserRequest, cfg *common.Config, log
 		Iv:         payload.Iv,
 	}
 
-	if err := db.Create(&user).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return keylog.Append(tx, user.Id, user.PublicKey)
+	})
+	// the email filter misses users created through other instances until its next rebuild
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		logger.PrintfError("User with email: %s already exists", payload.Email)
+		if cfg.EmailEnumerationProtection {
+			return nil, nil
+		}
+		return nil, &api.ApiError{
+			Code:  http.StatusConflict,
+			Error: enum.AlreadyExists,
+		}
+	}
+	if err != nil {
 		logger.PrintfError("Error creating user: %s", err)
 		return nil, &api.ApiError{
 			Code:  http.StatusInternalServerError,
@@ -52,6 +123,22 @@ func CreateUser(db *gorm.DB, payload *CreateUserRequest, cfg *common.Config, log
 		}
 	}
 
+	antiabuse.RecordSignup(db, cfg, client.IP, logger)
+
+	// the account exists at this point, a failed mail can be retried via /user/verify/resend
+	send := func() {
+		if err := sendVerificationMail(db, cfg, &user, logger); err != nil {
+			logger.PrintfWarning("Could not send verification mail to user: %s", user.Id)
+		}
+	}
+	// a slow mail server would make new emails answer later than registered ones
+	if cfg.EmailEnumerationProtection {
+		logger = logger.Detached()
+		go send()
+	} else {
+		send()
+	}
+
 	return &user, nil
 }
 
@@ -71,8 +158,14 @@ func GetUserById(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *co
 }
 
 func GetUserByEmail(db *gorm.DB, email string, logger *common.Logger) (bool, *api.ApiError) {
+	if !database.EmailMightExist(email) {
+		logger.PrintfDebug("Email: %s is not in the email filter", email)
+		return false, nil
+	}
+
+	// users that turned off discoverability by email are reported as unknown
 	var user database.User
-	err := db.Where("email = ?", email).First(&user).Error
+	err := db.Where("email = ? AND discoverable_by_email = ?", email, true).First(&user).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.PrintfInfo("No user with email: %s found", err)
@@ -92,7 +185,38 @@ func GetUserByEmail(db *gorm.DB, email string, logger *common.Logger) (bool, *ap
 	return true, nil
 }
 
-func GenerateGetProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger, cfg *common.Config) (*string, *api.ApiError) {
+// DiscoverContacts returns the users registered with one of the emails, the authenticated replacement of /user/exists.
+// Users that turned off discoverability by email are left out.
+func DiscoverContacts(db *gorm.DB, payload *DiscoverContactsRequest, logger *common.Logger) ([]ContactEntry, *api.ApiError) {
+	var users []database.User
+	if err := db.Select("id", "email", "name", "public_key").
+		Where("email IN ? AND disabled = ? AND deletion_scheduled_at IS NULL AND discoverable_by_email = ?", payload.Emails, false, true).
+		Find(&users).Error; err != nil {
+		logger.PrintfError("Error discovering contacts: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	contacts := []ContactEntry{}
+	for _, user := range users {
+		contacts = append(contacts, ContactEntry{
+			Email:     user.Email,
+			Id:        user.Id,
+			Name:      user.Name,
+			PublicKey: user.PublicKey,
+		})
+	}
+
+	logger.Printf("Discovered %d of %d contacts", len(contacts), len(payload.Emails))
+
+	return contacts, nil
+}
+
+// GenerateGetProfilePictureURL presigns a download of the profile picture. A size returns that variant
+// if it was generated already and the original otherwise, only the url of the original is stored.
+func GenerateGetProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, size int, logger *common.Logger, cfg *common.Config) (*string, *api.ApiError) {
 	var user database.User
 	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
 		logger.PrintfError("Error getting user: %s", err)
@@ -102,7 +226,13 @@ func GenerateGetProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPa
 		}
 	}
 
-	imageURL, err := s3.GenerateDownloadURL(logger, cfg, cfg.ProfilePictureBucketName, user.Id, 60*60*24*7) // 1 week expiration time
+	if size != 0 {
+		if _, err := s3.StatObject(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(user.Id, size)); err == nil {
+			return s3.GenerateDownloadURL(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(user.Id, size), 60*60*24*7)
+		}
+	}
+
+	imageURL, err := s3.GenerateDownloadURL(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(user.Id, 0), 60*60*24*7) // 1 week expiration time
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +251,18 @@ func GenerateGetProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPa
 	return imageURL, nil
 }
 
-func GenerateUploadProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger, cfg *common.Config) (*string, *api.ApiError) {
+// seconds a profile picture upload url and its nonce are valid
+const profilePictureUploadTimeout = 10 * 60
+
+// pending uploads are stored next to the profile pictures until they are confirmed
+func pendingProfilePictureKey(userId string, nonce string) string {
+	return "pending/" + userId + "/" + nonce
+}
+
+// GenerateUploadProfilePictureURL presigns an upload to a pending object and issues an upload token for it.
+// The token binds the upload to the user, object, size limit and content type, and can be confirmed once.
+// The profile picture only changes on confirmation, so a leaked upload url cannot replace it.
+func GenerateUploadProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, query *UploadProfilePictureRequest, logger *common.Logger, cfg *common.Config) (*UploadProfilePictureResponse, *api.ApiError) {
 	var user database.User
 	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
 		logger.PrintfError("Error getting user: %s", err)
@@ -131,22 +272,176 @@ func GenerateUploadProfilePictureURL(db *gorm.DB, jwtPayload *auth.JWTAccessToke
 		}
 	}
 
-	uploadURL, err := s3.GenerateUploadURL(logger, cfg, cfg.ProfilePictureBucketName, user.Id, 60*60)
+	nonce := database.UploadNonce{
+		Nonce:     uuid.NewString(),
+		ExpiresAt: time.Now().Add(profilePictureUploadTimeout * time.Second),
+		UserId:    user.Id,
+	}
+	if query.Sha256 != "" {
+		sum, _ := hex.DecodeString(query.Sha256)
+		checksum := base64.StdEncoding.EncodeToString(sum)
+		nonce.Checksum = &checksum
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND expires_at < ?", user.Id, time.Now()).Delete(&database.UploadNonce{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&nonce).Error
+	})
+	if err != nil {
+		logger.PrintfError("Error saving upload nonce: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	objectKey := pendingProfilePictureKey(user.Id, nonce.Nonce)
+	token, err := auth.IssueUploadToken(cfg, nonce.Nonce, auth.UploadTokenPayload{
+		UserId:      user.Id,
+		Bucket:      cfg.ProfilePictureBucketName,
+		ObjectKey:   objectKey,
+		MaxSize:     int64(cfg.ProfilePictureMaxSize),
+		ContentType: query.ContentType,
+		Checksum:    nonce.Checksum,
+	}, profilePictureUploadTimeout)
 	if err != nil {
-		logger.PrintfError("Error uploading profile picture: %s", err.Error)
+		logger.PrintfError("Error generating upload token: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	uploadURL, e := s3.GenerateUploadURL(logger, cfg, cfg.ProfilePictureBucketName, objectKey, profilePictureUploadTimeout, query.ContentType, nonce.Checksum)
+	if e != nil {
+		logger.PrintfError("Error uploading profile picture: %s", e.Error)
 		return nil, &api.ApiError{
 			Code:    http.StatusInternalServerError,
 			Error:   enum.ApiError,
-			Details: err,
+			Details: e,
 		}
 	}
 
 	logger.Printf("Successfully generated profile picture upload URL for user: %s", user.Id)
 
-	return uploadURL, nil
+	return &UploadProfilePictureResponse{
+		UploadURL: *uploadURL,
+		Token:     token,
+		MaxSize:   int64(cfg.ProfilePictureMaxSize),
+		ExpiresIn: profilePictureUploadTimeout,
+	}, nil
+}
+
+// rejectUpload deletes an upload that does not match its token and invalidates the token.
+func rejectUpload(db *gorm.DB, cfg *common.Config, claims *auth.UploadTokenPayload, errorCode enum.ErrorCode, details string, logger *common.Logger) *api.ApiError {
+	logger.PrintfWarning("Rejected upload %s of user: %s. %s", claims.ObjectKey, claims.UserId, details)
+	db.Model(&database.UploadNonce{}).Where("nonce = ?", claims.ID).Update("invalid", true)
+	if err := s3.DeleteObject(logger, cfg, claims.Bucket, claims.ObjectKey); err != nil {
+		logger.PrintfWarning("Could not delete rejected upload %s", claims.ObjectKey)
+	}
+	return &api.ApiError{
+		Code:    http.StatusBadRequest,
+		Error:   errorCode,
+		Details: details,
+	}
+}
+
+// ConfirmUpload verifies an upload against its token, consumes the token and makes the uploaded object the profile picture.
+func ConfirmUpload(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, payload *ConfirmUploadRequest, logger *common.Logger, cfg *common.Config) (*string, *api.ApiError) {
+	claims, err := auth.ValidateUploadToken(cfg, payload.Token)
+	if err != nil || claims.UserId != jwtPayload.UserId || claims.Bucket != cfg.ProfilePictureBucketName {
+		logger.PrintfWarning("Rejected upload confirmation of user: %s with invalid token", jwtPayload.UserId)
+		return nil, &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidUploadToken,
+		}
+	}
+
+	now := time.Now()
+	res := db.Model(&database.UploadNonce{}).
+		Where("nonce = ? AND user_id = ? AND used_at IS NULL AND invalid = ? AND expires_at > ?", claims.ID, jwtPayload.UserId, false, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		logger.PrintfError("Error consuming upload nonce: %s", res.Error)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if res.RowsAffected == 0 {
+		logger.PrintfWarning("Rejected upload confirmation of user: %s with used token", jwtPayload.UserId)
+		return nil, &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidUploadToken,
+		}
+	}
+
+	object, e := s3.StatObject(logger, cfg, claims.Bucket, claims.ObjectKey)
+	if e != nil {
+		// nothing was uploaded yet, the token stays usable until it expires
+		db.Model(&database.UploadNonce{}).Where("nonce = ?", claims.ID).Update("used_at", nil)
+		return nil, e
+	}
+
+	if object.Size > claims.MaxSize {
+		return nil, rejectUpload(db, cfg, claims, enum.UploadRejected, fmt.Sprintf("The upload is larger than %d bytes", claims.MaxSize), logger)
+	}
+	if object.ContentType != claims.ContentType {
+		return nil, rejectUpload(db, cfg, claims, enum.UploadRejected, fmt.Sprintf("The upload has to have the content type %s", claims.ContentType), logger)
+	}
+	// storage providers without checksum support accept any upload, so the stored checksum is compared as well
+	if claims.Checksum != nil && (object.Checksum == nil || *object.Checksum != *claims.Checksum) {
+		return nil, rejectUpload(db, cfg, claims, enum.ChecksumMismatch, "The checksum of the upload does not match", logger)
+	}
+
+	// the content type is only a header of the upload, the content has to match it as well.
+	// Content detection looks at the first 512 bytes at most.
+	content, e := s3.GetObjectRange(logger, cfg, claims.Bucket, claims.ObjectKey, 512, object.ETag)
+	if e != nil {
+		db.Model(&database.UploadNonce{}).Where("nonce = ?", claims.ID).Update("used_at", nil)
+		return nil, e
+	}
+	if detected := http.DetectContentType(content); detected != claims.ContentType {
+		return nil, rejectUpload(db, cfg, claims, enum.UploadRejected, fmt.Sprintf("The upload is not a %s image", strings.TrimPrefix(claims.ContentType, "image/")), logger)
+	}
+
+	// the upload url is still valid, the object must not have been replaced after it was checked
+	if err := s3.CopyObject(logger, cfg, claims.Bucket, claims.ObjectKey, profilePictureKey(jwtPayload.UserId, 0), object.ETag); err != nil {
+		db.Model(&database.UploadNonce{}).Where("nonce = ?", claims.ID).Update("used_at", nil)
+		return nil, err
+	}
+	if err := s3.DeleteObject(logger, cfg, claims.Bucket, claims.ObjectKey); err != nil {
+		logger.PrintfWarning("Could not delete pending profile picture %s", claims.ObjectKey)
+	}
+
+	// variants of the previous picture must not be served until the new ones are generated
+	for _, size := range profilePictureSizes {
+		if err := s3.DeleteObject(logger, cfg, cfg.ProfilePictureBucketName, profilePictureKey(jwtPayload.UserId, size)); err != nil {
+			logger.PrintfWarning("Could not delete %dpx profile picture of user: %s", size, jwtPayload.UserId)
+		}
+	}
+	generateThumbnails(cfg, jwtPayload.UserId, logger)
+
+	logger.Printf("Confirmed profile picture upload of user: %s", jwtPayload.UserId)
+
+	return GenerateGetProfilePictureURL(db, jwtPayload, 0, logger, cfg)
 }
 
-func UpdateUser(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, payload *UpdateUserRequest, logger *common.Logger) (*database.User, *api.ApiError) {
+func UpdateUser(db *gorm.DB, cfg *common.Config, jwtPayload *auth.JWTAccessTokenPayload, payload *UpdateUserRequest, client common.ClientInfo, logger *common.Logger) (*database.User, *api.ApiError) {
+	fields := map[string]string{}
+	if payload.Name != nil {
+		fields["name"] = *payload.Name
+	}
+	if payload.Bio != nil {
+		fields["bio"] = *payload.Bio
+	}
+	if err := moderation.CheckFields(cfg, logger, fields); err != nil {
+		return nil, err
+	}
+
 	var user database.User
 	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
 		logger.PrintfError("Error getting user: %s", err)
@@ -172,30 +467,161 @@ func UpdateUser(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, payload *Up
 		}
 	}
 
+	chat.InvalidateChatsOfUser(user.Id)
+
+	// only the names of the changed fields are stored, not their values
+	changed := make([]string, 0, len(fields))
+	for _, field := range []string{"name", "bio"} {
+		if _, ok := fields[field]; ok {
+			changed = append(changed, field)
+		}
+	}
+	details := strings.Join(changed, ",")
+	audit.Record(db, logger, user.Id, enum.ProfileUpdated, client, &details)
+
 	logger.Printf("Successfully updated user: %s", user.Id)
 
 	return &user, nil
 }
 
-func DeleteUser(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+// ChangePassword replaces the password and the private key encrypted with it.
+// All sessions except the current one are ended.
+func ChangePassword(db *gorm.DB, cfg *common.Config, jwtPayload *auth.JWTAccessTokenPayload, payload *ChangePasswordRequest, client common.ClientInfo, logger *common.Logger) *api.ApiError {
 	var user database.User
 	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
 		logger.PrintfError("Error getting user: %s", err)
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.UserNotFound,
+		}
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.CurrentPassword)); err != nil {
+		logger.PrintfWarning("Wrong current password for user: %s", user.Id)
+		return &api.ApiError{
+			Code:  http.StatusUnauthorized,
+			Error: enum.WrongCredentials,
+		}
+	}
+
+	if err := checkPasswordPolicy(db, cfg, &user, payload.NewPassword, logger); err != nil {
+		return err
+	}
+
+	if err := checkBreachedPassword(cfg, payload.NewPassword, logger); err != nil {
+		return err
+	}
+
+	password, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), cfg.SaltRounds)
+	if err != nil {
+		logger.PrintfError("Error hashing password: %s", err)
 		return &api.ApiError{
 			Code:  http.StatusInternalServerError,
-			Error: enum.NotFound,
+			Error: enum.ApiError,
 		}
 	}
 
-	if err := db.Delete(&user).Error; err != nil {
-		logger.PrintfError("Error deleting user: %s", err)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
+			"password":    string(password),
+			"private_key": payload.PrivateKey,
+			"iv":          payload.Iv,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := recordPasswordHistory(tx, cfg, &user); err != nil {
+			return err
+		}
+
+		// the private key is replaced as well, other devices must log in again to get it
+		return auth.SecurityEvent(tx, user.Id, enum.PasswordChanged, jwtPayload.RefreshRand)
+	})
+	if err != nil {
+		logger.PrintfError("Error changing password of user: %s. Error: %s", user.Id, err)
 		return &api.ApiError{
 			Code:  http.StatusInternalServerError,
 			Error: enum.ApiError,
 		}
 	}
 
-	logger.Printf("Successfully deleted user: %s", user.Id)
+	audit.Record(db, logger, user.Id, enum.PasswordChanged, client, nil)
+
+	logger.Printf("Successfully changed password of user: %s", user.Id)
 
 	return nil
 }
+
+func GetLoginHistory(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) ([]LoginHistoryEntry, *api.ApiError) {
+	var entries []database.AuditLog
+	if err := db.Where("user_id = ? AND action IN ?", jwtPayload.UserId, []enum.AuditAction{enum.LoginSucceeded, enum.LoginFailed}).
+		Order("created_at desc").Limit(50).Find(&entries).Error; err != nil {
+		logger.PrintfError("Error getting login history: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	history := []LoginHistoryEntry{}
+	for _, entry := range entries {
+		history = append(history, LoginHistoryEntry{
+			Time:      entry.CreatedAt,
+			Success:   entry.Action == enum.LoginSucceeded,
+			IP:        entry.IP,
+			UserAgent: entry.UserAgent,
+			Country:   entry.Country,
+			City:      entry.City,
+		})
+	}
+
+	logger.Printf("Successfully got login history for user: %s", jwtPayload.UserId)
+
+	return history, nil
+}
+
+// auditActionsVisibleToUser are the audit log actions users can see about their own account.
+// Failed logins are left out, they are part of the login history.
+var auditActionsVisibleToUser = []enum.AuditAction{
+	enum.LoginSucceeded,
+	enum.ProfileUpdated,
+	enum.EmailVerified,
+	enum.PasskeyRegistered,
+	enum.SessionRevoked,
+	enum.PasswordChanged,
+	enum.NewDeviceLogin,
+	enum.AccountDeleted,
+	enum.AccountRestored,
+	enum.Impersonated,
+}
+
+func GetAuditLog(db *gorm.DB, jwtPayload *auth.JWTAccessTokenPayload, query *AuditLogRequest, logger *common.Logger) ([]AuditLogEntry, *api.ApiError) {
+	const pageSize = 50
+
+	var entries []database.AuditLog
+	if err := db.Where("user_id = ? AND action IN ?", jwtPayload.UserId, auditActionsVisibleToUser).
+		Order("created_at desc").Limit(pageSize).Offset(query.Page * pageSize).Find(&entries).Error; err != nil {
+		logger.PrintfError("Error getting audit log: %s", err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	log := []AuditLogEntry{}
+	for _, entry := range entries {
+		log = append(log, AuditLogEntry{
+			Time:      entry.CreatedAt,
+			Action:    entry.Action,
+			Details:   entry.Details,
+			IP:        entry.IP,
+			UserAgent: entry.UserAgent,
+			Country:   entry.Country,
+			City:      entry.City,
+		})
+	}
+
+	logger.Printf("Successfully got audit log for user: %s", jwtPayload.UserId)
+
+	return log, nil
+}
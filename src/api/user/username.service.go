@@ -0,0 +1,112 @@
+package user
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// lowercase letters, digits and underscores, starting with a letter
+var usernamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{2,29}$`)
+
+// handles that could be mistaken for the service itself
+var reservedUsernames = []string{"admin", "administrator", "easyflow", "moderator", "root", "support", "system"}
+
+// GetUserByUsername returns the public profile behind a username, so users can be added to chats without their email.
+func GetUserByUsername(db *gorm.DB, username string, logger *common.Logger) (*UserHandleEntry, *api.ApiError) {
+	var user database.User
+	if err := db.Select("id", "username", "name", "public_key").
+		Where("username = ? AND disabled = ? AND deletion_scheduled_at IS NULL", strings.ToLower(username), false).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &api.ApiError{
+				Code:  http.StatusNotFound,
+				Error: enum.UserNotFound,
+			}
+		}
+		logger.PrintfError("Error getting user with username: %s. Error: %s", username, err)
+		return nil, &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return &UserHandleEntry{
+		Id:        user.Id,
+		Username:  *user.Username,
+		Name:      user.Name,
+		PublicKey: user.PublicKey,
+	}, nil
+}
+
+// SetUsername chooses or changes the username of the user. Usernames are case insensitive and stored lowercase,
+// changes are limited to one per UsernameChangeInterval so handles cannot be cycled to impersonate others.
+func SetUsername(db *gorm.DB, cfg *common.Config, jwtPayload *auth.JWTAccessTokenPayload, payload *SetUsernameRequest, logger *common.Logger) *api.ApiError {
+	username := strings.ToLower(payload.Username)
+	if !usernamePattern.MatchString(username) || slices.Contains(reservedUsernames, username) {
+		return &api.ApiError{
+			Code:    http.StatusBadRequest,
+			Error:   enum.MalformedRequest,
+			Details: "Usernames have 3 to 30 letters, digits or underscores and start with a letter",
+		}
+	}
+
+	var user database.User
+	if err := db.Select("id", "username", "username_changed_at").Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
+		logger.PrintfError("Error getting user: %s. Error: %s", jwtPayload.UserId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	if user.Username != nil && *user.Username == username {
+		return nil
+	}
+
+	if user.UsernameChangedAt != nil {
+		next := user.UsernameChangedAt.Add(time.Duration(cfg.UsernameChangeInterval) * time.Second)
+		if time.Now().Before(next) {
+			return &api.ApiError{
+				Code:    http.StatusTooManyRequests,
+				Error:   enum.TooManyAttempts,
+				Details: map[string]int{"retryAfter": int(math.Ceil(time.Until(next).Seconds()))},
+			}
+		}
+	}
+
+	err := db.Model(&database.User{}).Where("id = ?", jwtPayload.UserId).Updates(map[string]interface{}{
+		"username":            username,
+		"username_changed_at": time.Now(),
+	}).Error
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return &api.ApiError{
+			Code:  http.StatusConflict,
+			Error: enum.AlreadyExists,
+		}
+	}
+	if err != nil {
+		logger.PrintfError("Error setting username of user: %s. Error: %s", jwtPayload.UserId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	logger.Printf("User: %s set username to %s", jwtPayload.UserId, username)
+
+	return nil
+}
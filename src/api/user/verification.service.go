@@ -0,0 +1,133 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/api/audit"
+	"easyflow-backend/src/api/auth"
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"easyflow-backend/src/enum"
+	"easyflow-backend/src/mail"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// sendVerificationMail replaces any pending verification token of the user and mails a new one.
+func sendVerificationMail(db *gorm.DB, cfg *common.Config, user *database.User, logger *common.Logger) *api.ApiError {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		logger.PrintfError("Error generating verification token: %s", err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+	token := hex.EncodeToString(raw)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.Id).Delete(&database.EmailVerificationToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&database.EmailVerificationToken{
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(time.Duration(cfg.EmailVerificationExpirationTime) * time.Second),
+			UserId:    user.Id,
+		}).Error
+	})
+	if err != nil {
+		logger.PrintfError("Error saving verification token for user: %s. Error: %s", user.Id, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	link := fmt.Sprintf("%s/verify/%s", cfg.GetFrontendURL(), token)
+	body := fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening the following link:\n\n%s\n\nThe link expires in %d hours.", user.Name, link, cfg.EmailVerificationExpirationTime/3600)
+	if err := mail.Send(cfg, logger, user.Email, "Verify your email address", body); err != nil {
+		logger.PrintfError("Error sending verification mail to user: %s. Error: %s", user.Id, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	return nil
+}
+
+func VerifyEmail(db *gorm.DB, token string, client common.ClientInfo, logger *common.Logger) *api.ApiError {
+	var entry database.EmailVerificationToken
+	if err := db.Where("token_hash = ? AND expires_at > ?", hashToken(token), time.Now()).First(&entry).Error; err != nil {
+		logger.PrintfWarning("Invalid or expired email verification token")
+		return &api.ApiError{
+			Code:  http.StatusBadRequest,
+			Error: enum.InvalidToken,
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.User{}).Where("id = ?", entry.UserId).Update("email_verified", true).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", entry.UserId).Delete(&database.EmailVerificationToken{}).Error
+	})
+	if err != nil {
+		logger.PrintfError("Error verifying email of user: %s. Error: %s", entry.UserId, err)
+		return &api.ApiError{
+			Code:  http.StatusInternalServerError,
+			Error: enum.ApiError,
+		}
+	}
+
+	audit.Record(db, logger, entry.UserId, enum.EmailVerified, client, nil)
+
+	logger.Printf("Successfully verified email of user: %s", entry.UserId)
+
+	return nil
+}
+
+func ResendVerificationMail(db *gorm.DB, cfg *common.Config, jwtPayload *auth.JWTAccessTokenPayload, logger *common.Logger) *api.ApiError {
+	var user database.User
+	if err := db.Where("id = ?", jwtPayload.UserId).First(&user).Error; err != nil {
+		logger.PrintfError("Error getting user: %s", err)
+		return &api.ApiError{
+			Code:  http.StatusNotFound,
+			Error: enum.UserNotFound,
+		}
+	}
+
+	if user.EmailVerified {
+		return &api.ApiError{
+			Code:    http.StatusConflict,
+			Error:   enum.AlreadyExists,
+			Details: "Email is already verified",
+		}
+	}
+
+	if user.Guest {
+		return &api.ApiError{
+			Code:    http.StatusForbidden,
+			Error:   enum.NotAllowed,
+			Details: "Guests have no email",
+		}
+	}
+
+	if err := sendVerificationMail(db, cfg, &user, logger); err != nil {
+		return err
+	}
+
+	logger.Printf("Resent verification mail to user: %s", user.Id)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// Filter is a bloom filter of strings, it is safe for concurrent use.
+// Test never reports false for an added value, but it can report true for values that were never added.
+type Filter struct {
+	mu     sync.RWMutex
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+// New sizes a filter for n values with the false positive rate p.
+func New(n int, p float64) *Filter {
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	n = max(n, 1)
+
+	size := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/float64(n)*math.Ln2)))
+
+	return &Filter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// locations derives the bit positions of a value from two halves of one 128 bit hash (double hashing).
+func (f *Filter) locations(value string) []uint64 {
+	hash := fnv.New128a()
+	hash.Write([]byte(value))
+	sum := hash.Sum(nil)
+	a, b := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+
+	locations := make([]uint64, f.hashes)
+	for i := range locations {
+		locations[i] = (a + uint64(i)*b) % f.size
+	}
+	return locations
+}
+
+// Add puts the value into the filter.
+func (f *Filter) Add(value string) {
+	locations := f.locations(value)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, bit := range locations {
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test reports whether the value might have been added.
+func (f *Filter) Test(value string) bool {
+	locations := f.locations(value)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, bit := range locations {
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+package common
+
+import "github.com/gin-gonic/gin"
+
+// ClientInfo describes the client a request originates from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// low entropy client hints browsers send by default, platform and mobile
+	ClientHints string
+}
+
+func GetClientInfo(c *gin.Context) ClientInfo {
+	return ClientInfo{
+		IP:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		ClientHints: c.GetHeader("Sec-CH-UA-Platform") + ";" + c.GetHeader("Sec-CH-UA-Mobile"),
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"gorm.io/gorm"
@@ -22,18 +23,187 @@ type Config struct {
 	SaltRounds  int
 	Port        string
 	DebugMode   bool
+	// production refuses to start when the migration would modify existing columns, unless this is set
+	MigrateAllowDestructive bool
 	//jwt
-	JwtSecret             string
+	JwtSecret string
+	JwtKeyId  string
+	// expected iss and aud claims, tokens of other issuers or for other audiences are rejected
+	JwtIssuer   string
+	JwtAudience string
+	// HS256 (default), RS256 or EdDSA
+	JwtAlgorithm      string
+	JwtPrivateKeyFile string
+	JwtPublicKeyFile  string
+	// previously used secrets as "kid:secret" pairs, still accepted for validation
+	JwtPreviousKeys []string
+	// previously used public keys as "kid:path" pairs
+	JwtPreviousPublicKeys []string
 	JwtExpirationTime     int
 	RefreshExpirationTime int
+	// refresh token lifetime of "remember me" logins
+	RememberMeExpirationTime int
+	// unix time until which refresh tokens without a typ claim are accepted, 0 refuses them
+	JwtUntypedTokensUntil int
+	// api keys
+	ApiKeyMonthlyQuota       int64
+	ApiKeyUsageFlushInterval int
+	// signup
+	SignupDomainAllowlist []string
+	SignupDomainBlocklist []string
+	SignupRequireMX       bool
+	SignupDomainRate      int
+	SignupDomainBurst     int
+	// bloom filter of registered emails that lets lookups of unknown emails skip the database
+	EmailFilter                  bool
+	EmailFilterRebuildInterval   int
+	EmailFilterFalsePositiveRate float64
+	// hide whether an email is registered, /user/exists is replaced by the authenticated contact discovery
+	EmailEnumerationProtection bool
+	// milliseconds login and signup take at least with EmailEnumerationProtection
+	EnumerationMinResponseTime int
+	// seconds a deleted account can be restored before it is purged
+	AccountDeletionGracePeriod int
+	// guest accounts
+	GuestAccounts          bool
+	GuestInactivityTimeout int
+	GuestRateLimit         float64
+	GuestRateBurst         int
+	// users are shown online for this many seconds after their last request
+	PresenceOnlineWindow int
+	// seconds between username changes, choosing the first one is always allowed
+	UsernameChangeInterval int
+	// password policy, see user.checkPasswordPolicy
+	PasswordMinLength       int
+	PasswordMaxLength       int
+	PasswordRequiredClasses []string
+	PasswordDenylist        []string
+	PasswordHistory         int
+	// reject passwords found in data breaches (haveibeenpwned range api)
+	BreachedPasswordCheck   bool
+	BreachedPasswordTimeout int
+	// mail users on logins from new devices
+	NewDeviceNotification bool
+	// reject refreshes from another device than the session was created on
+	RefreshDeviceBinding bool
+	// login risk scoring, see auth.RiskScorer
+	LoginRiskScoring           bool
+	LoginRiskVelocityThreshold int
+	LoginRiskTravelWindow      int
+	// account lockout
+	LoginLockoutThreshold   int
+	LoginLockoutDuration    int
+	LoginLockoutMaxDuration int
+	// login attempts per minute and email
+	LoginEmailRate  int
+	LoginEmailBurst int
+	// oauth
+	OAuthGoogleClientId     string
+	OAuthGoogleClientSecret string
+	OAuthGithubClientId     string
+	OAuthGithubClientSecret string
+	// existing accounts with emails of these domains are linked to oauth identities, others are rejected
+	OAuthLinkDomains []string
+	// saml sso, disabled when the idp metadata url is empty
+	SamlIdpMetadataURL  string
+	SamlEntityId        string
+	SamlCertificateFile string
+	SamlPrivateKeyFile  string
+	SamlEmailAttribute  string
+	SamlNameAttribute   string
+	// existing accounts with emails of these domains are linked to saml identities, others are rejected
+	SamlLinkDomains []string
+	// reverse proxy auth, disabled when the secret is empty
+	ProxyAuthSecret      string
+	ProxyAuthEmailHeader string
+	ProxyAuthNameHeader  string
+	ProxyAuthMaxSkew     int
+	// existing accounts with emails of these domains are linked to proxy identities, others are rejected
+	ProxyLinkDomains []string
+	// bearer token of the scim endpoints, disabled when empty
+	ScimToken string
+	// bearer token of the prometheus scraper, /metrics is disabled when empty
+	MetricsToken string
+	// webauthn
+	WebAuthnRPID      string
+	WebAuthnRPName    string
+	WebAuthnRPOrigins []string
 	// s3
 	BucketURL                string
 	BucketAccessKeyId        string
 	BucketSecret             string
 	ProfilePictureBucketName string
+	ReportsBucketName        string
+	// largest accepted profile picture upload in bytes
+	ProfilePictureMaxSize int
+	// message archive, messages older than MessageArchiveAfter seconds are moved to the bucket
+	MessageArchiveBucketName string
+	MessageArchiveAfter      int
+	MessageArchiveInterval   int
+	// seconds between deletions of expired self destructing messages, 0 disables it
+	MessageExpiryInterval int
+	// bytes of message streams per user and day, 0 means unlimited
+	StreamDailyBandwidth         int64
+	StreamBandwidthFlushInterval int
+	// cache
+	ChatCacheTTL      int
+	ChatStatsCacheTTL int
+	// moderation
+	KickCooldown        int
+	ModerationMode      string
+	ModerationBlocklist []string
+	ModerationAllowlist []string
+	// antiabuse thresholds (0 disables one), offenders above one are flagged and above twice of it throttled
+	AbuseChatBurst          int
+	AbuseDuplicateChatNames int
+	AbuseSignupBurst        int
+	// translation of chat names and descriptions, provider is "libretranslate", "deepl" or empty to disable it
+	TranslationProvider string
+	TranslationUrl      string
+	TranslationApiKey   string
+	TranslationCacheTTL int
+	// mail
+	SmtpHost     string
+	SmtpPort     string
+	SmtpUser     string
+	SmtpPassword string
+	MailFrom     string
+	// secret for unsubscribe links, mails the user can opt out of are not sent without it
+	UnsubscribeSecret string
+	// email verification
+	EmailVerificationExpirationTime int
+	// geoip
+	GeoIPDatabasePath    string
+	GeoIPRefreshInterval int
+	// admin
+	AdminApiKey string
+	// seconds an impersonation token issued through the admin api stays valid
+	ImpersonationTokenLifetime int
+	// debug capture
+	DebugCaptureRate float64
+	DebugCaptureSize int
+	DebugCaptureTTL  int
+	// client versions, see middleware.ClientVersionMiddleware
+	MinClientVersion        string
+	DeprecatedClientVersion string
 	// app
-	FrontendURL string
-	Domain      string
+	FrontendURL     string
+	BackendURL      string
+	Domain          string
+	TrustedProxies  []string
+	RemoteIPHeaders []string
+	// tls
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSAutocert         bool
+	TLSAutocertCacheDir string
+	HTTPRedirectPort    string
+	// http server
+	ReadHeaderTimeout int
+	ReadTimeout       int
+	WriteTimeout      int
+	IdleTimeout       int
+	MaxHeaderBytes    int
 }
 
 func getEnv(key, fallback string) string {
@@ -52,6 +222,32 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func getEnvList(key string) []string {
+	var list []string
+	if value, ok := os.LookupEnv(key); ok {
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				list = append(list, entry)
+			}
+		}
+	}
+	return list
+}
+
+// GetFrontendURL returns the primary frontend URL, used to build links in mails.
+func (c *Config) GetFrontendURL() string {
+	return strings.TrimSpace(strings.Split(c.FrontendURL, ",")[0])
+}
+
 // LoadDefaultConfig loads the default configuration values.
 // It reads the environment variables from the .env file, if present,
 // and returns a Config struct with the loaded values.
@@ -66,20 +262,139 @@ func LoadDefaultConfig() *Config {
 			Logger:                                   logger.Default.LogMode(logger.Silent),
 			DisableForeignKeyConstraintWhenMigrating: true,
 		},
-		Stage:                    getEnv("STAGE", "development"),
-		LogLevel:                 LogLevel(getEnv("LOG_LEVEL", "DEBUG")),
-		DatabaseURL:              getEnv("DATABASE_URL", ""),
-		SaltRounds:               getEnvInt("SALT_OR_ROUNDS", 10),
-		JwtSecret:                getEnv("JWT_SECRET", "public_secret"),
-		JwtExpirationTime:        getEnvInt("JWT_EXPIRATION_TIME", 60*10),          // 10 minutes
-		RefreshExpirationTime:    getEnvInt("REFRESH_EXPIRATION_TIME", 60*60*24*7), // 1 week
-		Port:                     getEnv("PORT", "4000"),
-		DebugMode:                getEnv("DEBUG_MODE", "false") == "true",
-		BucketURL:                getEnv("BUCKET_URL", ""),
-		BucketAccessKeyId:        getEnv("BUCKET_ACCESS_KEY_ID", ""),
-		BucketSecret:             getEnv("BUCKET_SECRET", ""),
-		ProfilePictureBucketName: getEnv("PROFILE_PICTURE_BUCKET_NAME", ""),
-		FrontendURL:              getEnv("FRONTEND_URL", "http://localhost:3000"),
-		Domain:                   getEnv("DOMAIN", "localhost"),
+		Stage:                           getEnv("STAGE", "development"),
+		LogLevel:                        LogLevel(getEnv("LOG_LEVEL", "DEBUG")),
+		DatabaseURL:                     getEnv("DATABASE_URL", ""),
+		MigrateAllowDestructive:         getEnv("MIGRATE_ALLOW_DESTRUCTIVE", "false") == "true",
+		SaltRounds:                      getEnvInt("SALT_OR_ROUNDS", 10),
+		JwtSecret:                       getEnv("JWT_SECRET", "public_secret"),
+		JwtKeyId:                        getEnv("JWT_KEY_ID", "default"),
+		JwtIssuer:                       getEnv("JWT_ISSUER", "easyflow"),
+		JwtAudience:                     getEnv("JWT_AUDIENCE", "easyflow-api"),
+		JwtAlgorithm:                    getEnv("JWT_ALGORITHM", "HS256"),
+		JwtPrivateKeyFile:               getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JwtPublicKeyFile:                getEnv("JWT_PUBLIC_KEY_FILE", ""),
+		JwtPreviousKeys:                 getEnvList("JWT_PREVIOUS_KEYS"),
+		JwtPreviousPublicKeys:           getEnvList("JWT_PREVIOUS_PUBLIC_KEYS"),
+		JwtExpirationTime:               getEnvInt("JWT_EXPIRATION_TIME", 60*10), // 10 minutes
+		JwtUntypedTokensUntil:           getEnvInt("JWT_UNTYPED_TOKENS_UNTIL", 0),
+		RefreshExpirationTime:           getEnvInt("REFRESH_EXPIRATION_TIME", 60*60*24*7),      // 1 week
+		RememberMeExpirationTime:        getEnvInt("REMEMBER_ME_EXPIRATION_TIME", 60*60*24*30), // 30 days
+		ApiKeyMonthlyQuota:              int64(getEnvInt("API_KEY_MONTHLY_QUOTA", 0)),
+		ApiKeyUsageFlushInterval:        getEnvInt("API_KEY_USAGE_FLUSH_INTERVAL", 60),
+		SignupDomainAllowlist:           getEnvList("SIGNUP_DOMAIN_ALLOWLIST"),
+		SignupDomainBlocklist:           getEnvList("SIGNUP_DOMAIN_BLOCKLIST"),
+		SignupRequireMX:                 getEnv("SIGNUP_REQUIRE_MX", "false") == "true",
+		SignupDomainRate:                getEnvInt("SIGNUP_DOMAIN_RATE", 0),
+		SignupDomainBurst:               getEnvInt("SIGNUP_DOMAIN_BURST", 10),
+		EmailFilter:                     getEnv("EMAIL_FILTER", "false") == "true",
+		EmailFilterRebuildInterval:      getEnvInt("EMAIL_FILTER_REBUILD_INTERVAL", 60*5),
+		EmailFilterFalsePositiveRate:    getEnvFloat("EMAIL_FILTER_FALSE_POSITIVE_RATE", 0.01),
+		EmailEnumerationProtection:      getEnv("EMAIL_ENUMERATION_PROTECTION", "false") == "true",
+		EnumerationMinResponseTime:      getEnvInt("ENUMERATION_MIN_RESPONSE_TIME", 1000),
+		AccountDeletionGracePeriod:      getEnvInt("ACCOUNT_DELETION_GRACE_PERIOD", 60*60*24*30), // 30 days
+		GuestAccounts:                   getEnv("GUEST_ACCOUNTS", "false") == "true",
+		GuestInactivityTimeout:          getEnvInt("GUEST_INACTIVITY_TIMEOUT", 60*60*24*7), // 1 week
+		GuestRateLimit:                  getEnvFloat("GUEST_RATE_LIMIT", 1),
+		GuestRateBurst:                  getEnvInt("GUEST_RATE_BURST", 10),
+		PresenceOnlineWindow:            getEnvInt("PRESENCE_ONLINE_WINDOW", 300),
+		UsernameChangeInterval:          getEnvInt("USERNAME_CHANGE_INTERVAL", 60*60*24*30), // 30 days
+		PasswordMinLength:               getEnvInt("PASSWORD_MIN_LENGTH", 12),
+		PasswordMaxLength:               getEnvInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordRequiredClasses:         getEnvList("PASSWORD_REQUIRED_CLASSES"),
+		PasswordDenylist:                getEnvList("PASSWORD_DENYLIST"),
+		PasswordHistory:                 getEnvInt("PASSWORD_HISTORY", 0),
+		BreachedPasswordCheck:           getEnv("BREACHED_PASSWORD_CHECK", "true") == "true",
+		BreachedPasswordTimeout:         getEnvInt("BREACHED_PASSWORD_TIMEOUT", 2000), // 2 seconds
+		NewDeviceNotification:           getEnv("NEW_DEVICE_NOTIFICATION", "true") == "true",
+		RefreshDeviceBinding:            getEnv("REFRESH_DEVICE_BINDING", "true") == "true",
+		LoginRiskScoring:                getEnv("LOGIN_RISK_SCORING", "false") == "true",
+		LoginRiskVelocityThreshold:      getEnvInt("LOGIN_RISK_VELOCITY_THRESHOLD", 20),
+		LoginRiskTravelWindow:           getEnvInt("LOGIN_RISK_TRAVEL_WINDOW", 60*60),
+		LoginLockoutThreshold:           getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LoginLockoutDuration:            getEnvInt("LOGIN_LOCKOUT_DURATION", 60),        // 1 minute
+		LoginLockoutMaxDuration:         getEnvInt("LOGIN_LOCKOUT_MAX_DURATION", 60*60), // 1 hour
+		LoginEmailRate:                  getEnvInt("LOGIN_EMAIL_RATE", 10),
+		LoginEmailBurst:                 getEnvInt("LOGIN_EMAIL_BURST", 5),
+		OAuthGoogleClientId:             getEnv("OAUTH_GOOGLE_CLIENT_ID", ""),
+		OAuthGoogleClientSecret:         getEnv("OAUTH_GOOGLE_CLIENT_SECRET", ""),
+		OAuthGithubClientId:             getEnv("OAUTH_GITHUB_CLIENT_ID", ""),
+		OAuthGithubClientSecret:         getEnv("OAUTH_GITHUB_CLIENT_SECRET", ""),
+		OAuthLinkDomains:                getEnvList("OAUTH_LINK_DOMAINS"),
+		SamlIdpMetadataURL:              getEnv("SAML_IDP_METADATA_URL", ""),
+		SamlEntityId:                    getEnv("SAML_ENTITY_ID", ""),
+		SamlCertificateFile:             getEnv("SAML_CERTIFICATE_FILE", ""),
+		SamlPrivateKeyFile:              getEnv("SAML_PRIVATE_KEY_FILE", ""),
+		SamlEmailAttribute:              getEnv("SAML_EMAIL_ATTRIBUTE", "email"),
+		SamlNameAttribute:               getEnv("SAML_NAME_ATTRIBUTE", "displayName"),
+		SamlLinkDomains:                 getEnvList("SAML_LINK_DOMAINS"),
+		ProxyAuthSecret:                 getEnv("PROXY_AUTH_SECRET", ""),
+		ProxyAuthEmailHeader:            getEnv("PROXY_AUTH_EMAIL_HEADER", "X-Forwarded-Email"),
+		ProxyAuthNameHeader:             getEnv("PROXY_AUTH_NAME_HEADER", "X-Forwarded-User"),
+		ProxyAuthMaxSkew:                getEnvInt("PROXY_AUTH_MAX_SKEW", 60), // 1 minute
+		ProxyLinkDomains:                getEnvList("PROXY_LINK_DOMAINS"),
+		ScimToken:                       getEnv("SCIM_TOKEN", ""),
+		MetricsToken:                    getEnv("METRICS_TOKEN", ""),
+		WebAuthnRPID:                    getEnv("WEBAUTHN_RP_ID", getEnv("DOMAIN", "localhost")),
+		WebAuthnRPName:                  getEnv("WEBAUTHN_RP_NAME", "Easyflow"),
+		WebAuthnRPOrigins:               strings.Split(getEnv("WEBAUTHN_RP_ORIGINS", getEnv("FRONTEND_URL", "http://localhost:3000")), ", "),
+		Port:                            getEnv("PORT", "4000"),
+		DebugMode:                       getEnv("DEBUG_MODE", "false") == "true",
+		BucketURL:                       getEnv("BUCKET_URL", ""),
+		BucketAccessKeyId:               getEnv("BUCKET_ACCESS_KEY_ID", ""),
+		BucketSecret:                    getEnv("BUCKET_SECRET", ""),
+		ProfilePictureBucketName:        getEnv("PROFILE_PICTURE_BUCKET_NAME", ""),
+		ReportsBucketName:               getEnv("REPORTS_BUCKET_NAME", ""),
+		ProfilePictureMaxSize:           getEnvInt("PROFILE_PICTURE_MAX_SIZE", 5*1024*1024),
+		MessageArchiveBucketName:        getEnv("MESSAGE_ARCHIVE_BUCKET_NAME", ""),
+		MessageArchiveAfter:             getEnvInt("MESSAGE_ARCHIVE_AFTER", 60*60*24*365),
+		MessageArchiveInterval:          getEnvInt("MESSAGE_ARCHIVE_INTERVAL", 60*60*6),
+		MessageExpiryInterval:           getEnvInt("MESSAGE_EXPIRY_INTERVAL", 60),
+		StreamDailyBandwidth:            int64(getEnvInt("STREAM_DAILY_BANDWIDTH", 0)),
+		StreamBandwidthFlushInterval:    getEnvInt("STREAM_BANDWIDTH_FLUSH_INTERVAL", 60),
+		ChatCacheTTL:                    getEnvInt("CHAT_CACHE_TTL", 30),        // 30 seconds
+		ChatStatsCacheTTL:               getEnvInt("CHAT_STATS_CACHE_TTL", 300), // 5 minutes
+		KickCooldown:                    getEnvInt("KICK_COOLDOWN", 60*5),       // 5 minutes
+		ModerationMode:                  getEnv("MODERATION_MODE", "reject"),
+		ModerationBlocklist:             getEnvList("MODERATION_BLOCKLIST"),
+		ModerationAllowlist:             getEnvList("MODERATION_ALLOWLIST"),
+		AbuseChatBurst:                  getEnvInt("ABUSE_CHAT_BURST", 10),
+		AbuseDuplicateChatNames:         getEnvInt("ABUSE_DUPLICATE_CHAT_NAMES", 20),
+		AbuseSignupBurst:                getEnvInt("ABUSE_SIGNUP_BURST", 10),
+		TranslationProvider:             getEnv("TRANSLATION_PROVIDER", ""),
+		TranslationUrl:                  getEnv("TRANSLATION_URL", ""),
+		TranslationApiKey:               getEnv("TRANSLATION_API_KEY", ""),
+		TranslationCacheTTL:             getEnvInt("TRANSLATION_CACHE_TTL", 60*60*24), // 1 day
+		SmtpHost:                        getEnv("SMTP_HOST", ""),
+		SmtpPort:                        getEnv("SMTP_PORT", "587"),
+		SmtpUser:                        getEnv("SMTP_USER", ""),
+		SmtpPassword:                    getEnv("SMTP_PASSWORD", ""),
+		MailFrom:                        getEnv("MAIL_FROM", "noreply@easyflow.chat"),
+		UnsubscribeSecret:               getEnv("UNSUBSCRIBE_SECRET", ""),
+		EmailVerificationExpirationTime: getEnvInt("EMAIL_VERIFICATION_EXPIRATION_TIME", 60*60*24), // 1 day
+		GeoIPDatabasePath:               getEnv("GEOIP_DATABASE_PATH", ""),
+		GeoIPRefreshInterval:            getEnvInt("GEOIP_REFRESH_INTERVAL", 60*60*24), // 1 day
+		AdminApiKey:                     getEnv("ADMIN_API_KEY", ""),
+		ImpersonationTokenLifetime:      getEnvInt("IMPERSONATION_TOKEN_LIFETIME", 60*15), // 15 minutes
+		DebugCaptureRate:                getEnvFloat("DEBUG_CAPTURE_RATE", 0),
+		DebugCaptureSize:                getEnvInt("DEBUG_CAPTURE_SIZE", 200),
+		DebugCaptureTTL:                 getEnvInt("DEBUG_CAPTURE_TTL", 60*15), // 15 minutes
+		MinClientVersion:                getEnv("MIN_CLIENT_VERSION", ""),
+		DeprecatedClientVersion:         getEnv("DEPRECATED_CLIENT_VERSION", ""),
+		FrontendURL:                     getEnv("FRONTEND_URL", "http://localhost:3000"),
+		BackendURL:                      getEnv("BACKEND_URL", "http://localhost:4000"),
+		Domain:                          getEnv("DOMAIN", "localhost"),
+		TrustedProxies:                  getEnvList("TRUSTED_PROXIES"),
+		RemoteIPHeaders:                 getEnvList("REMOTE_IP_HEADERS"),
+		TLSCertFile:                     getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:                      getEnv("TLS_KEY_FILE", ""),
+		TLSAutocert:                     getEnv("TLS_AUTOCERT", "false") == "true",
+		TLSAutocertCacheDir:             getEnv("TLS_AUTOCERT_CACHE_DIR", "certs"),
+		HTTPRedirectPort:                getEnv("HTTP_REDIRECT_PORT", ""),
+		ReadHeaderTimeout:               getEnvInt("READ_HEADER_TIMEOUT", 5),  // 5 seconds
+		ReadTimeout:                     getEnvInt("READ_TIMEOUT", 15),        // 15 seconds
+		WriteTimeout:                    getEnvInt("WRITE_TIMEOUT", 30),       // 30 seconds
+		IdleTimeout:                     getEnvInt("IDLE_TIMEOUT", 120),       // 2 minutes
+		MaxHeaderBytes:                  getEnvInt("MAX_HEADER_BYTES", 1<<16), // 64 KiB
 	}
 }
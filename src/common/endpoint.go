@@ -3,6 +3,7 @@ package common
 import (
 	"easyflow-backend/src/api"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -106,3 +107,8 @@ func SetupEndpoint[T any](c *gin.Context) (*T, *Logger, *gorm.DB, *Config, []str
 
 	return payload, logger, db, cfg, serializableErrors
 }
+
+// PadDuration sleeps until at least minimum passed since start, so different code paths of an endpoint answer after the same time.
+func PadDuration(start time.Time, minimum time.Duration) {
+	time.Sleep(time.Until(start.Add(minimum)))
+}
@@ -33,16 +33,60 @@ const (
 	ERROR   LogLevel = "ERROR"
 )
 
+var logLevelRank = map[LogLevel]int{
+	DEBUG:   0,
+	INFO:    1,
+	WARNING: 2,
+	ERROR:   3,
+}
+
+// IsValid reports whether the level is one of the known log levels.
+func (level LogLevel) IsValid() bool {
+	_, ok := logLevelRank[level]
+	return ok
+}
+
+// module name -> LogLevel, the empty module name overrides the level of every module
+var logLevelOverrides sync.Map
+
+// SetLogLevelOverride changes the effective log level of a module at runtime.
+// An empty module applies to all modules without an own override.
+func SetLogLevelOverride(module string, level LogLevel) {
+	logLevelOverrides.Store(module, level)
+}
+
+// ClearLogLevelOverride restores the configured log level for a module.
+func ClearLogLevelOverride(module string) {
+	logLevelOverrides.Delete(module)
+}
+
+// GetLogLevelOverrides returns all active overrides by module.
+func GetLogLevelOverrides() map[string]LogLevel {
+	overrides := make(map[string]LogLevel)
+	logLevelOverrides.Range(func(key, value any) bool {
+		overrides[key.(string)] = value.(LogLevel)
+		return true
+	})
+	return overrides
+}
+
+// Field is a key value pair that is attached to every line written by a logger.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
 type Logger struct {
 	LogMutex sync.Mutex
 	Target   io.Writer
 	Module   atomic.Value
 	C        *gin.Context
 	logLevel LogLevel
+	fields   []Field
 }
 
 //GENERAL SCHEMA:
-// {color}[KIND][TIME][MODULE] MESSAGE{reset}
+// {color}[KIND][TIME][IP][MODULE][KEY=VALUE...] MESSAGE{reset}
 
 func NewLogger(target io.Writer, module string, c *gin.Context, logLevel LogLevel) *Logger {
 	logger := &Logger{
@@ -58,6 +102,48 @@ func (l *Logger) SetPrefix(prefix string) {
 	l.Module.Store(prefix)
 }
 
+// With returns a child logger that writes the given fields in addition to the fields of its parent.
+func (l *Logger) With(fields ...Field) *Logger {
+	child := &Logger{
+		Target:   l.Target,
+		C:        l.C,
+		logLevel: l.logLevel,
+		fields:   append(append([]Field{}, l.fields...), fields...),
+	}
+	child.Module.Store(l.Module.Load())
+	return child
+}
+
+// Detached returns a child logger that no longer reads the request, gin reuses the context once the request is answered.
+// Goroutines that outlive the request have to log with it, the client IP is kept as a field.
+func (l *Logger) Detached() *Logger {
+	child := l.With()
+	if l.C != nil {
+		child.fields = append(child.fields, Field{Key: "ip", Value: l.C.ClientIP()})
+		child.C = nil
+	}
+	return child
+}
+
+func (l *Logger) enabled(level LogLevel) bool {
+	effective := l.logLevel
+	if override, ok := logLevelOverrides.Load(l.Module.Load().(string)); ok {
+		effective = override.(LogLevel)
+	} else if override, ok := logLevelOverrides.Load(""); ok {
+		effective = override.(LogLevel)
+	}
+
+	return logLevelRank[level] >= logLevelRank[effective]
+}
+
+func (l *Logger) formatFields() string {
+	var formatted string
+	for _, field := range l.fields {
+		formatted += fmt.Sprintf("[%s=%v]", field.Key, field.Value)
+	}
+	return formatted
+}
+
 func getLocalTime() string {
 	return time.Now().Format("2006-01-02 - 15:04:05")
 }
@@ -77,7 +163,7 @@ func (l *Logger) Println(message interface{}) {
 	defer l.LogMutex.Unlock()
 
 	module_name := l.Module.Load().(string)
-	_, _ = l.Target.Write([]byte(string(green) + "[" + "SUCCESS" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "] " + message.(string) + string(reset) + "\n"))
+	_, _ = l.Target.Write([]byte(string(green) + "[" + "SUCCESS" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "]" + l.formatFields() + " " + message.(string) + string(reset) + "\n"))
 }
 
 func (l *Logger) Printf(format string, args ...interface{}) {
@@ -86,7 +172,7 @@ func (l *Logger) Printf(format string, args ...interface{}) {
 
 	module_name := l.Module.Load().(string)
 	formattedMessage := fmt.Sprintf(format, args...)
-	_, _ = l.Target.Write([]byte(string(green) + "[" + "SUCCESS" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "] " + formattedMessage + string(reset) + "\n"))
+	_, _ = l.Target.Write([]byte(string(green) + "[" + "SUCCESS" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "]" + l.formatFields() + " " + formattedMessage + string(reset) + "\n"))
 }
 
 func (l *Logger) PrintfError(format string, args ...interface{}) {
@@ -95,38 +181,38 @@ func (l *Logger) PrintfError(format string, args ...interface{}) {
 
 	module_name := l.Module.Load().(string)
 	formattedMessage := fmt.Sprintf(format, args...)
-	_, _ = l.Target.Write([]byte(string(red) + "[" + "ERROR" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "] " + formattedMessage + string(reset) + "\n"))
+	_, _ = l.Target.Write([]byte(string(red) + "[" + "ERROR" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "]" + l.formatFields() + " " + formattedMessage + string(reset) + "\n"))
 }
 
 func (l *Logger) PrintfWarning(format string, args ...interface{}) {
-	if l.logLevel == LogLevel(WARNING) || l.logLevel == LogLevel(INFO) || l.logLevel == LogLevel(DEBUG) {
+	if l.enabled(WARNING) {
 		l.LogMutex.Lock()
 		defer l.LogMutex.Unlock()
 
 		module_name := l.Module.Load().(string)
 		formattedMessage := fmt.Sprintf(format, args...)
-		_, _ = l.Target.Write([]byte(string(yellow) + "[" + "WARNING" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "] " + formattedMessage + string(reset) + "\n"))
+		_, _ = l.Target.Write([]byte(string(yellow) + "[" + "WARNING" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "]" + l.formatFields() + " " + formattedMessage + string(reset) + "\n"))
 	}
 }
 
 func (l *Logger) PrintfInfo(format string, args ...interface{}) {
-	if l.logLevel == LogLevel(INFO) || l.logLevel == LogLevel(DEBUG) {
+	if l.enabled(INFO) {
 		l.LogMutex.Lock()
 		defer l.LogMutex.Unlock()
 
 		module_name := l.Module.Load().(string)
 		formattedMessage := fmt.Sprintf(format, args...)
-		_, _ = l.Target.Write([]byte(string(blue) + "[" + "INFO" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "] " + formattedMessage + string(reset) + "\n"))
+		_, _ = l.Target.Write([]byte(string(blue) + "[" + "INFO" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "]" + l.formatFields() + " " + formattedMessage + string(reset) + "\n"))
 	}
 }
 
 func (l *Logger) PrintfDebug(format string, args ...interface{}) {
-	if l.logLevel == LogLevel(DEBUG) {
+	if l.enabled(DEBUG) {
 		l.LogMutex.Lock()
 		defer l.LogMutex.Unlock()
 
 		module_name := l.Module.Load().(string)
 		formattedMessage := fmt.Sprintf(format, args...)
-		_, _ = l.Target.Write([]byte(string(lightBlue) + "[" + "DEBUG" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "] " + formattedMessage + string(reset) + "\n"))
+		_, _ = l.Target.Write([]byte(string(lightBlue) + "[" + "DEBUG" + "]" + "[" + getLocalTime() + "][" + getIP(l.C) + "][" + module_name + "]" + l.formatFields() + " " + formattedMessage + string(reset) + "\n"))
 	}
 }
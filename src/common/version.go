@@ -0,0 +1,5 @@
+package common
+
+// Version of the server, set at build time with
+// -ldflags "-X easyflow-backend/src/common.Version=<version>".
+var Version = "dev"
@@ -6,6 +6,9 @@ import (
 	"gorm.io/gorm/logger"
 )
 
+// models are migrated in this order, see DatabaseInst.Migrate
+var models = []interface{}{&Message{}, &Chat{}, &User{}, &ChatUserKeys{}, &UserKeys{}, &AuditLog{}, &ChatBan{}, &WebAuthnCredential{}, &EmailVerificationToken{}, &MailSuppression{}, &OAuthAccount{}, &KeyLogEntry{}, &KeyLogHead{}, &KnownDevice{}, &ApiKey{}, &ApiKeyUsage{}, &ApiKeyQuota{}, &RevokedToken{}, &UploadNonce{}, &ImpersonationLog{}, &MessageArchive{}, &ArchivedMessage{}, &PasswordHistory{}, &ChatDeletionApproval{}, &StreamBandwidth{}, &ChatWebhook{}, &NotificationSettings{}, &AbuseFlag{}}
+
 type DatabaseInst struct {
 	client *gorm.DB
 }
@@ -16,6 +19,10 @@ func NewDatabaseInst(url string, config *gorm.Config) (*DatabaseInst, error) {
 		return nil, err
 	}
 
+	if err := registerMetricsCallbacks(db); err != nil {
+		return nil, err
+	}
+
 	return &DatabaseInst{client: db}, nil
 }
 
@@ -24,7 +31,26 @@ func (d *DatabaseInst) GetClient() *gorm.DB {
 }
 
 func (d *DatabaseInst) Migrate() error {
-	return d.client.AutoMigrate(&Message{}, &Chat{}, &User{}, &ChatUserKeys{}, &UserKeys{})
+	// users that signed up before email verification existed are treated as verified
+	backfillEmailVerified := d.client.Migrator().HasTable(&User{}) && !d.client.Migrator().HasColumn(&User{}, "EmailVerified")
+
+	err := d.client.AutoMigrate(models...)
+	if err != nil {
+		return err
+	}
+
+	// client message ids used to be unique per sender across all chats
+	if d.client.Migrator().HasIndex(&Message{}, "idx_sender_client_message") {
+		if err := d.client.Migrator().DropIndex(&Message{}, "idx_sender_client_message"); err != nil {
+			return err
+		}
+	}
+
+	if backfillEmailVerified {
+		return d.client.Model(&User{}).Where("1 = 1").Update("email_verified", true).Error
+	}
+
+	return nil
 }
 
 func (d *DatabaseInst) SetLogMode(mode logger.LogLevel) {
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"easyflow-backend/src/bloom"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// registeredEmails is a bloom filter of the emails of all users, nil until the first RebuildEmailFilter.
+// Emails of deleted users stay in it until the next rebuild, positives are always confirmed by a query.
+var registeredEmails atomic.Pointer[bloom.Filter]
+
+// the email column compares case insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// EmailMightExist reports false only for emails that are not registered, so the database lookup can be skipped.
+// Emails registered through another instance are only known after the next rebuild.
+func EmailMightExist(email string) bool {
+	filter := registeredEmails.Load()
+	return filter == nil || filter.Test(normalizeEmail(email))
+}
+
+// AddRegisteredEmail adds an email to the filter, e.g. after the email of a user changed.
+func AddRegisteredEmail(email string) {
+	if filter := registeredEmails.Load(); filter != nil {
+		filter.Add(normalizeEmail(email))
+	}
+}
+
+func (u *User) AfterCreate(tx *gorm.DB) (err error) {
+	AddRegisteredEmail(u.Email)
+	return
+}
+
+// RebuildEmailFilter replaces the filter with one built from the users table.
+// It is sized for twice the current users, so signups until the next rebuild keep the false positive rate low.
+func RebuildEmailFilter(db *gorm.DB, falsePositiveRate float64) error {
+	start := time.Now().Add(-time.Second)
+
+	var count int64
+	if err := db.Model(&User{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	filter := bloom.New(int(count)*2, falsePositiveRate)
+	var users []User
+	if err := db.Select("id", "email").FindInBatches(&users, 10000, func(tx *gorm.DB, batch int) error {
+		for _, user := range users {
+			filter.Add(normalizeEmail(user.Email))
+		}
+		return nil
+	}).Error; err != nil {
+		return err
+	}
+
+	registeredEmails.Store(filter)
+
+	// users created while the table was read were only added to the previous filter
+	var created []string
+	if err := db.Model(&User{}).Where("created_at >= ?", start).Pluck("email", &created).Error; err != nil {
+		return err
+	}
+	for _, email := range created {
+		filter.Add(normalizeEmail(email))
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"easyflow-backend/src/metrics"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startTimeKey = "metrics:start_time"
+
+// registerMetricsCallbacks measures the duration of every statement gorm executes.
+// Record not found is not treated as an error since it is part of the normal control flow.
+func registerMetricsCallbacks(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(startTimeKey, time.Now())
+	}
+
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			raw, ok := tx.InstanceGet(startTimeKey)
+			if !ok {
+				return
+			}
+			start, ok := raw.(time.Time)
+			if !ok {
+				return
+			}
+
+			err := tx.Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = nil
+			}
+			metrics.ObserveDatabase(operation, start, err)
+		}
+	}
+
+	cb := db.Callback()
+	registrations := []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	}
+
+	return errors.Join(registrations...)
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// MigrationPlan is what Migrate would change in the live schema.
+type MigrationPlan struct {
+	// DDL statements in the order they would run
+	Statements []string
+	// statements that can lose data, e.g. a column changed to a smaller type
+	Destructive []string
+	// live columns without a field in the models, auto migration never drops them
+	UnknownColumns []string
+}
+
+// statementRecorder collects the statements a dry run migration would execute.
+// Schema lookups run for real and are left out.
+type statementRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *statementRecorder) LogMode(logger.LogLevel) logger.Interface {
+	return r
+}
+
+func (r *statementRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	sql = strings.TrimSpace(sql)
+	if sql == "" || strings.HasPrefix(strings.ToUpper(sql), "SELECT") {
+		return
+	}
+	r.statements = append(r.statements, sql)
+}
+
+// isDestructive reports whether a migration statement can lose data. Auto migration only modifies existing columns
+// when their type, size or nullability differ, which can truncate values or fail on existing rows.
+func isDestructive(statement string) bool {
+	upper := strings.ToUpper(statement)
+	return strings.Contains(upper, "MODIFY COLUMN") || strings.HasPrefix(upper, "DROP") || strings.Contains(upper, " DROP ")
+}
+
+// PlanMigration compares the live schema with the models without changing it.
+func (d *DatabaseInst) PlanMigration() (*MigrationPlan, error) {
+	recorder := &statementRecorder{Interface: d.client.Logger}
+	dryRun := d.client.Session(&gorm.Session{DryRun: true, Logger: recorder})
+	if err := dryRun.AutoMigrate(models...); err != nil {
+		return nil, err
+	}
+
+	plan := &MigrationPlan{Statements: recorder.statements}
+	for _, statement := range plan.Statements {
+		if isDestructive(statement) {
+			plan.Destructive = append(plan.Destructive, statement)
+		}
+	}
+
+	migrator := d.client.Migrator()
+	for _, model := range models {
+		if !migrator.HasTable(model) {
+			continue
+		}
+
+		statement := &gorm.Statement{DB: d.client}
+		if err := statement.Parse(model); err != nil {
+			return nil, err
+		}
+
+		columns, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			if !slices.Contains(statement.Schema.DBNames, column.Name()) {
+				plan.UnknownColumns = append(plan.UnknownColumns, fmt.Sprintf("%s.%s", statement.Schema.Table, column.Name()))
+			}
+		}
+	}
+
+	return plan, nil
+}
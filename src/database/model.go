@@ -1,6 +1,7 @@
 package database
 
 import (
+	"easyflow-backend/src/enum"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,14 +10,21 @@ import (
 
 type Message struct {
 	Id        string    `gorm:"type:varchar(36);primaryKey"`
-	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP;index:idx_message_chat_created,priority:2"`
 	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
 	Content   string    `gorm:"type:text"`
 	Iv        string    `gorm:"type:varchar(25)"`
-	ChatId    string    `gorm:"type:varchar(36);index"`
-	SenderId  string    `gorm:"type:varchar(36);index"`
-	Chat      Chat      `gorm:"foreignKey:ChatId"`
-	Sender    User      `gorm:"foreignKey:SenderId"`
+	ChatId    string    `gorm:"type:varchar(36);index;index:idx_message_chat_created,priority:1;uniqueIndex:idx_chat_sender_client_message,priority:1"`
+	SenderId  string    `gorm:"type:varchar(36);index;uniqueIndex:idx_chat_sender_client_message,priority:2"`
+	// uuid generated by the client, retries with the same id in the same chat do not create duplicates
+	ClientMessageId *string `gorm:"type:varchar(36);uniqueIndex:idx_chat_sender_client_message,priority:3"`
+	Imported        bool    `gorm:"not null;default:false"` // migrated from another platform with its original timestamp
+	// admin of the chat that imported the message
+	ImportedBy *string `gorm:"type:varchar(36)"`
+	Chat       Chat    `gorm:"foreignKey:ChatId"`
+	Sender     User    `gorm:"foreignKey:SenderId"`
+	// self destructing messages are hidden from this time on and deleted by chat.StartMessageExpiry
+	ExpiresAt *time.Time `gorm:"type:datetime;index"`
 }
 
 func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
@@ -31,7 +39,11 @@ type Chat struct {
 	Name        string    `gorm:"type:varchar(255)"`
 	Picture     *string   `gorm:"type:varchar(2048)"` // TODO: adjust for s3 file key
 	Description *string   `gorm:"type:text"`
+	IsPublic    bool      `gorm:"default:false;index"`
+	Category    *string   `gorm:"type:varchar(50);index"`
 	Messages    []Message `gorm:"foreignKey:ChatId"`
+	// comma separated language tags of the plaintext name and description, a hint for translating them
+	Languages *string `gorm:"type:varchar(100)"`
 }
 
 func (c *Chat) BeforeCreate(tx *gorm.DB) (err error) {
@@ -40,18 +52,40 @@ func (c *Chat) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 type User struct {
-	Id             string         `gorm:"type:varchar(36);primaryKey" json:"id"`
-	CreatedAt      time.Time      `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"createdAt"`
-	UpdatedAt      time.Time      `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updatedAt"`
-	Email          string         `gorm:"type:varchar(255);uniqueIndex" json:"email"`
-	Password       string         `gorm:"type:text" json:"-"`
-	Name           string         `gorm:"type:varchar(50)" json:"name"`
-	Bio            *string        `gorm:"type:varchar(1000)" json:"bio"`
-	Iv             string         `gorm:"type:varchar(25)" json:"iv"`
-	ProfilePicture *string        `gorm:"type:varchar(512)" json:"profilePicture"`
-	PublicKey      string         `gorm:"type:text" json:"publicKey"`
-	PrivateKey     string         `gorm:"type:text" json:"privateKey"`
-	Keys           []ChatUserKeys `gorm:"foreignKey:UserId" json:"-"`
+	Id             string    `gorm:"type:varchar(36);primaryKey" json:"id"`
+	CreatedAt      time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updatedAt"`
+	Email          string    `gorm:"type:varchar(255);uniqueIndex" json:"email"`
+	EmailVerified  bool      `gorm:"not null;default:false" json:"emailVerified"`
+	Role           enum.Role `gorm:"type:varchar(20);not null;default:USER" json:"role"`
+	Password       string    `gorm:"type:text" json:"-"`
+	Name           string    `gorm:"type:varchar(50)" json:"name"`
+	Bio            *string   `gorm:"type:varchar(1000)" json:"bio"`
+	Iv             string    `gorm:"type:varchar(25)" json:"iv"`
+	ProfilePicture *string   `gorm:"type:varchar(512)" json:"profilePicture"`
+	PublicKey      string    `gorm:"type:text" json:"publicKey"`
+	PrivateKey     string    `gorm:"type:text" json:"privateKey"`
+	// failed password logins since the last successful one, see auth.recordFailedLogin
+	FailedLoginAttempts int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil         *time.Time `gorm:"type:datetime" json:"-"`
+	// disabled users cannot log in, e.g. after deprovisioning through scim
+	Disabled bool `gorm:"not null;default:false" json:"-"`
+	// deleted accounts can be restored by logging in until they are purged at this time
+	DeletionScheduledAt *time.Time `gorm:"type:datetime;index" json:"-"`
+	// last authenticated request, written at most once a minute
+	LastSeenAt *time.Time `gorm:"type:datetime" json:"-"`
+	// hides presence and last seen from other users
+	HidePresence bool `gorm:"not null;default:false" json:"hidePresence"`
+	// lowercase handle to find the user without their email, nil until chosen
+	Username          *string    `gorm:"type:varchar(30);uniqueIndex" json:"username"`
+	UsernameChangedAt *time.Time `gorm:"type:datetime" json:"-"`
+	// privacy settings, see PUT /user/privacy
+	DiscoverableByEmail bool             `gorm:"not null;default:true" json:"discoverableByEmail"`
+	ReadReceipts        bool             `gorm:"not null;default:true" json:"readReceipts"`
+	ChatInvites         enum.ChatInvites `gorm:"type:varchar(20);not null;default:everyone" json:"chatInvites"`
+	// guests are temporary accounts without email and password, purged when inactive
+	Guest bool           `gorm:"not null;default:false" json:"guest"`
+	Keys  []ChatUserKeys `gorm:"foreignKey:UserId" json:"-"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -64,10 +98,13 @@ type ChatUserKeys struct {
 	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
 	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
 	Key       string    `gorm:"type:text"`
+	IsAdmin   bool      `gorm:"default:false"`
 	ChatId    string    `gorm:"type:varchar(36);index"`
 	Chat      Chat      `gorm:"foreignKey:ChatId"`
 	UserId    string    `gorm:"type:varchar(36);index"`
 	User      User      `gorm:"foreignKey:UserId"`
+	// hides the presence of the user from the other members, see user.GetPresence
+	HidePresence bool `gorm:"not null;default:false"`
 }
 
 func (cuk *ChatUserKeys) BeforeCreate(tx *gorm.DB) (err error) {
@@ -76,16 +113,318 @@ func (cuk *ChatUserKeys) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 type UserKeys struct {
+	Id         string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt  time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	ExpiredAt  time.Time `gorm:"type:datetime"`
+	LastUsedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	Lifetime   int       `gorm:"not null;default:0"` // refresh token lifetime in seconds chosen at login, 0 means the default
+	Random     string    `gorm:"type:varchar(36)"`
+	// jti and expiry of the last access token of the session, to revoke it with the session
+	AccessTokenId   string    `gorm:"type:varchar(36)"`
+	AccessExpiresAt time.Time `gorm:"type:datetime"`
+	IP              string    `gorm:"type:varchar(45)"`
+	UserAgent       string    `gorm:"type:varchar(512)"`
+	DeviceHash      string    `gorm:"type:varchar(64)"` // device the session was created on, see auth.sessionFingerprint
+	User            User      `gorm:"foreignKey:UserId"`
+	UserId          string    `gorm:"type:varchar(36);index"`
+}
+
+func (uk *UserKeys) BeforeCreate(tx *gorm.DB) (err error) {
+	uk.Id = uuid.NewString()
+	return
+}
+
+type AuditLog struct {
+	Id        string           `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time        `gorm:"type:datetime;default:CURRENT_TIMESTAMP;index"`
+	Action    enum.AuditAction `gorm:"type:varchar(64);index"`
+	IP        string           `gorm:"type:varchar(45);index"`
+	UserAgent string           `gorm:"type:varchar(512)"`
+	Country   string           `gorm:"type:varchar(2)"`
+	City      string           `gorm:"type:varchar(255)"`
+	Details   *string          `gorm:"type:text"`
+	UserId    string           `gorm:"type:varchar(36);index"`
+	User      User             `gorm:"foreignKey:UserId"`
+}
+
+func (al *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	al.Id = uuid.NewString()
+	return
+}
+
+type ChatBan struct {
+	Id        string     `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	ExpiresAt *time.Time `gorm:"type:datetime"` // nil means the ban is permanent
+	Reason    *string    `gorm:"type:varchar(1000)"`
+	ChatId    string     `gorm:"type:varchar(36);index"`
+	Chat      Chat       `gorm:"foreignKey:ChatId"`
+	UserId    string     `gorm:"type:varchar(36);index"`
+	User      User       `gorm:"foreignKey:UserId"`
+	BannedBy  string     `gorm:"type:varchar(36)"`
+}
+
+func (cb *ChatBan) BeforeCreate(tx *gorm.DB) (err error) {
+	cb.Id = uuid.NewString()
+	return
+}
+
+type WebAuthnCredential struct {
+	Id           string     `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt    time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	LastUsedAt   *time.Time `gorm:"type:datetime"`
+	Name         string     `gorm:"type:varchar(100)"`
+	CredentialId string     `gorm:"type:varchar(1400);index"` // base64url encoded credential id
+	Data         string     `gorm:"type:text"`                // json encoded webauthn.Credential
+	UserId       string     `gorm:"type:varchar(36);index"`
+	User         User       `gorm:"foreignKey:UserId"`
+}
+
+func (wc *WebAuthnCredential) BeforeCreate(tx *gorm.DB) (err error) {
+	wc.Id = uuid.NewString()
+	return
+}
+
+type EmailVerificationToken struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	ExpiresAt time.Time `gorm:"type:datetime"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex"` // sha256 of the token sent by mail
+	UserId    string    `gorm:"type:varchar(36);index"`
+	User      User      `gorm:"foreignKey:UserId"`
+}
+
+func (evt *EmailVerificationToken) BeforeCreate(tx *gorm.DB) (err error) {
+	evt.Id = uuid.NewString()
+	return
+}
+
+type MailSuppression struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	Category  string    `gorm:"type:varchar(50);uniqueIndex:idx_mail_suppression_user_category"`
+	UserId    string    `gorm:"type:varchar(36);uniqueIndex:idx_mail_suppression_user_category"`
+	User      User      `gorm:"foreignKey:UserId"`
+}
+
+func (ms *MailSuppression) BeforeCreate(tx *gorm.DB) (err error) {
+	ms.Id = uuid.NewString()
+	return
+}
+
+type OAuthAccount struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	Provider  string    `gorm:"type:varchar(50);uniqueIndex:idx_oauth_provider_subject"`
+	Subject   string    `gorm:"type:varchar(255);uniqueIndex:idx_oauth_provider_subject"`
+	UserId    string    `gorm:"type:varchar(36);index"`
+	User      User      `gorm:"foreignKey:UserId"`
+}
+
+func (oa *OAuthAccount) BeforeCreate(tx *gorm.DB) (err error) {
+	oa.Id = uuid.NewString()
+	return
+}
+
+// KeyLogEntry is a leaf of the append-only key transparency log, see the keylog package.
+// Entries are never updated or deleted.
+type KeyLogEntry struct {
+	Index     int64     `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	PublicKey string    `gorm:"type:text"`
+	LeafHash  string    `gorm:"type:varchar(64)"` // hex encoded
+	UserId    string    `gorm:"type:varchar(36);index"`
+	User      User      `gorm:"foreignKey:UserId"`
+}
+
+// KeyLogHead is the single row holding the size of the key transparency log, appends lock it to get the next index.
+type KeyLogHead struct {
+	Id   int   `gorm:"primaryKey;autoIncrement:false"`
+	Size int64 `gorm:"not null"`
+}
+
+// KnownDevice is a device a user logged in from before, identified by a coarse fingerprint.
+type KnownDevice struct {
+	Id          string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt   time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	LastSeenAt  time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	Fingerprint string    `gorm:"type:varchar(64);uniqueIndex:idx_known_device_user_fingerprint"` // sha256 of user agent and country
+	UserAgent   string    `gorm:"type:varchar(512)"`
+	Country     string    `gorm:"type:varchar(2)"`
+	UserId      string    `gorm:"type:varchar(36);uniqueIndex:idx_known_device_user_fingerprint"`
+	User        User      `gorm:"foreignKey:UserId"`
+}
+
+func (kd *KnownDevice) BeforeCreate(tx *gorm.DB) (err error) {
+	kd.Id = uuid.NewString()
+	return
+}
+
+// ApiKey gives bots and integrations access to the api on behalf of a user, see auth.AuthGuard.
+type ApiKey struct {
+	Id         string     `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt  time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	LastUsedAt *time.Time `gorm:"type:datetime"`
+	ExpiresAt  *time.Time `gorm:"type:datetime"` // nil means the key does not expire
+	Name       string     `gorm:"type:varchar(100)"`
+	Prefix     string     `gorm:"type:varchar(12)"`             // start of the key, shown to identify it
+	KeyHash    string     `gorm:"type:varchar(64);uniqueIndex"` // sha256 of the key
+	UserId     string     `gorm:"type:varchar(36);index"`
+	User       User       `gorm:"foreignKey:UserId"`
+}
+
+func (ak *ApiKey) BeforeCreate(tx *gorm.DB) (err error) {
+	ak.Id = uuid.NewString()
+	return
+}
+
+// UploadNonce allows exactly one confirmation of a presigned upload, it is the jti of the upload token
+type UploadNonce struct {
+	Nonce     string     `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	ExpiresAt time.Time  `gorm:"type:datetime"`
+	UsedAt    *time.Time `gorm:"type:datetime"`
+	Checksum  *string    `gorm:"type:varchar(44)"` // base64 SHA-256 declared by the client
+	Invalid   bool       `gorm:"not null;default:false"`
+	UserId    string     `gorm:"type:varchar(36);index"`
+}
+
+// PasswordHistory keeps the hashes of previous passwords to prevent their reuse
+type PasswordHistory struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	Hash      string    `gorm:"type:text"`
+	UserId    string    `gorm:"type:varchar(36);index"`
+}
+
+func (p *PasswordHistory) BeforeCreate(tx *gorm.DB) (err error) {
+	p.Id = uuid.NewString()
+	return
+}
+
+// RevokedToken is an access token that was revoked before its expiry, e.g. by a logout
+type RevokedToken struct {
+	Jti       string    `gorm:"type:varchar(36);primaryKey"`
+	ExpiresAt time.Time `gorm:"type:datetime;index"`
+}
+
+// MessageArchive is a gzipped json object in the message archive bucket that holds the messages of a chat in one month
+type MessageArchive struct {
+	ChatId    string    `gorm:"type:varchar(36);primaryKey"`
+	Month     string    `gorm:"type:varchar(7);primaryKey"` // YYYY-MM in UTC
+	ObjectKey string    `gorm:"type:varchar(255)"`
+	Messages  int       `gorm:"not null;default:0"`
+	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// ArchivedMessage locates an archived message, so lookups by id only download the archive of its month
+type ArchivedMessage struct {
+	Id     string `gorm:"type:varchar(36);primaryKey"`
+	ChatId string `gorm:"type:varchar(36);index:idx_archived_messages_chat_month"`
+	Month  string `gorm:"type:varchar(7);index:idx_archived_messages_chat_month"`
+}
+
+// ChatDeletionApproval is the vote of a chat owner to delete the chat, see chat.DeleteChat
+type ChatDeletionApproval struct {
+	ChatId    string    `gorm:"type:varchar(36);primaryKey"`
+	UserId    string    `gorm:"type:varchar(36);primaryKey;index"`
+	CreatedAt time.Time `gorm:"type:datetime"`
+}
+
+// ChatWebhook receives the events of a chat, see chat.dispatchWebhook
+type ChatWebhook struct {
 	Id        string    `gorm:"type:varchar(36);primaryKey"`
 	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP"`
+	ChatId    string    `gorm:"type:varchar(36);index"`
+	Url       string    `gorm:"type:varchar(2048)"`
+	// signs the deliveries, it has to be readable so it is not hashed
+	Secret string `gorm:"type:varchar(64)"`
+	// comma separated event names, empty receives all events
+	Events    string `gorm:"type:varchar(255)"`
+	CreatedBy string `gorm:"type:varchar(36)"`
+}
+
+func (w *ChatWebhook) BeforeCreate(tx *gorm.DB) (err error) {
+	w.Id = uuid.NewString()
+	return
+}
+
+// NotificationSettings are created on the first change, users without them get every notification.
+// Use GetNotificationSettings before notifying a user.
+type NotificationSettings struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
 	UpdatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
-	ExpiredAt time.Time `gorm:"type:datetime"`
-	Random    string    `gorm:"type:varchar(36)"`
+	UserId    string    `gorm:"type:varchar(36);uniqueIndex"`
 	User      User      `gorm:"foreignKey:UserId"`
+	// no gorm defaults, disabled settings would be replaced by them on create
+	Email    bool `gorm:"not null"`
+	Push     bool `gorm:"not null"`
+	Mentions bool `gorm:"not null"`
+}
+
+func (ns *NotificationSettings) BeforeCreate(tx *gorm.DB) (err error) {
+	ns.Id = uuid.NewString()
+	return
+}
+
+// AbuseFlag is raised by the antiabuse heuristics for admin review
+type AbuseFlag struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP;index"`
+	// user id, or "ip:<address>" for signups
+	Subject   string `gorm:"type:varchar(64);index"`
+	Heuristic string `gorm:"type:varchar(50)"`
+	// strongest action taken when the flag was raised
+	Action     string     `gorm:"type:varchar(20)"`
+	Details    string     `gorm:"type:varchar(255)"`
+	ReviewedAt *time.Time `gorm:"type:datetime;index"`
+	ReviewedBy *string    `gorm:"type:varchar(100)"`
+}
+
+func (af *AbuseFlag) BeforeCreate(tx *gorm.DB) (err error) {
+	af.Id = uuid.NewString()
+	return
+}
+
+// ImpersonationLog records every request made with an impersonation token
+type ImpersonationLog struct {
+	Id        string    `gorm:"type:varchar(36);primaryKey"`
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP;index"`
+	TokenId   string    `gorm:"type:varchar(36);index"`
+	Operator  string    `gorm:"type:varchar(100)"`
 	UserId    string    `gorm:"type:varchar(36);index"`
+	Method    string    `gorm:"type:varchar(10)"`
+	Path      string    `gorm:"type:varchar(2048)"`
+	Status    int
 }
 
-func (uk *UserKeys) BeforeCreate(tx *gorm.DB) (err error) {
-	uk.Id = uuid.NewString()
+func (l *ImpersonationLog) BeforeCreate(tx *gorm.DB) (err error) {
+	l.Id = uuid.NewString()
 	return
 }
+
+// ApiKeyUsage counts the requests of an api key per month and endpoint
+type ApiKeyUsage struct {
+	ApiKeyId string `gorm:"type:varchar(36);uniqueIndex:idx_api_key_usage"`
+	Month    string `gorm:"type:varchar(7);uniqueIndex:idx_api_key_usage"`   // YYYY-MM in UTC
+	Endpoint string `gorm:"type:varchar(255);uniqueIndex:idx_api_key_usage"` // route pattern, e.g. /chat/:chatId
+	Requests int64  `gorm:"not null;default:0"`
+	Bytes    int64  `gorm:"not null;default:0"`
+}
+
+// ApiKeyQuota counts the requests of an api key per month against API_KEY_MONTHLY_QUOTA. Unlike ApiKeyUsage it is
+// written on every request, so instances share the count and cannot overshoot the quota.
+type ApiKeyQuota struct {
+	ApiKeyId string `gorm:"type:varchar(36);primaryKey"`
+	Month    string `gorm:"type:varchar(7);primaryKey"` // YYYY-MM in UTC
+	Requests int64  `gorm:"not null;default:0"`
+}
+
+// StreamBandwidth counts the bytes of message streams sent to a user per day
+type StreamBandwidth struct {
+	UserId string `gorm:"type:varchar(36);uniqueIndex:idx_stream_bandwidth"`
+	Day    string `gorm:"type:varchar(10);uniqueIndex:idx_stream_bandwidth"` // YYYY-MM-DD in UTC
+	Bytes  int64  `gorm:"not null;default:0"`
+}
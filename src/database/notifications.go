@@ -0,0 +1,25 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// GetNotificationSettings returns the notification settings of the user, everything is enabled until the user
+// changes them. Mail, push and realtime senders have to check them before notifying.
+func GetNotificationSettings(db *gorm.DB, userId string) (*NotificationSettings, error) {
+	settings := NotificationSettings{
+		UserId:   userId,
+		Email:    true,
+		Push:     true,
+		Mentions: true,
+	}
+
+	err := db.Where("user_id = ?", userId).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	return &settings, nil
+}
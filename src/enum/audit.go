@@ -0,0 +1,21 @@
+package enum
+
+// AuditAction identifies the kind of event stored in the audit log.
+type AuditAction string
+
+const (
+	LoginSucceeded AuditAction = "LOGIN_SUCCEEDED"
+	LoginFailed    AuditAction = "LOGIN_FAILED"
+	// profile and security changes, visible to the user through the audit endpoint
+	ProfileUpdated    AuditAction = "PROFILE_UPDATED"
+	EmailVerified     AuditAction = "EMAIL_VERIFIED"
+	PasskeyRegistered AuditAction = "PASSKEY_REGISTERED"
+	SessionRevoked    AuditAction = "SESSION_REVOKED"
+	PasswordChanged   AuditAction = "PASSWORD_CHANGED"
+	EmailChanged      AuditAction = "EMAIL_CHANGED"
+	NewDeviceLogin    AuditAction = "NEW_DEVICE_LOGIN"
+	AccountDeleted    AuditAction = "ACCOUNT_DELETED"
+	AccountRestored   AuditAction = "ACCOUNT_RESTORED"
+	Impersonated      AuditAction = "IMPERSONATED"
+	LoginRisk         AuditAction = "LOGIN_RISK"
+)
@@ -0,0 +1,46 @@
+package enum
+
+// ErrorDescription documents an error code for API consumers.
+type ErrorDescription struct {
+	Code        ErrorCode `json:"code"`
+	Description string    `json:"description"`
+	// http status codes the error is returned with
+	Status []int `json:"status"`
+}
+
+// ErrorCatalogue lists every ErrorCode. New error codes have to be added here as well.
+var ErrorCatalogue = []ErrorDescription{
+	{Unauthorized, "The request requires a valid login.", []int{401}},
+	{ApiError, "An unexpected server error occurred.", []int{500}},
+	{NotAllowed, "The user is not allowed to perform this action.", []int{403}},
+	{NotFound, "The requested resource does not exist.", []int{404}},
+	{AlreadyExists, "A resource with the same unique attributes already exists.", []int{409}},
+	{WrongCredentials, "The email or password is wrong.", []int{401}},
+	{MalformedRequest, "The request body, parameters or query failed validation.", []int{400}},
+	{InvalidCookie, "A required authentication cookie is missing.", []int{400}},
+	{InvalidAccessToken, "The access token is missing or invalid.", []int{400, 498}},
+	{InvalidRefreshToken, "The refresh token is missing, invalid or was revoked.", []int{400, 498}},
+	{ExpiredAccessToken, "The access token expired, refresh it.", []int{498}},
+	{ExpiredRefreshToken, "The refresh token expired, the user has to log in again.", []int{498}},
+	{UserNotFound, "The user does not exist.", []int{404}},
+	{ContentPolicyViolation, "The submitted content violates the content policy.", []int{422}},
+	{BannedFromChat, "The user is banned from the chat.", []int{403}},
+	{InvalidWebAuthnSession, "The passkey ceremony expired or does not exist.", []int{400}},
+	{WebAuthnFailed, "The passkey could not be verified.", []int{400, 401}},
+	{EmailNotVerified, "The user has to verify their email address first.", []int{403}},
+	{InvalidToken, "The token is invalid or expired.", []int{400}},
+	{TooManyAttempts, "Too many attempts, e.g. a locked account (details contain retryAfter in seconds) too many signups from one email domain or IP, chats created in a short time or requests of a guest.", []int{429}},
+	{EmailDomainNotAllowed, "Signups with this email domain are not allowed.", []int{403}},
+	{AccountDisabled, "The account was disabled by an administrator.", []int{403}},
+	{QuotaExceeded, "A quota is used up, the monthly requests of an api key or the daily stream bandwidth of a user.", []int{429}},
+	{InvalidUploadToken, "The upload token is invalid, expired, issued to another user or was already used.", []int{400}},
+	{UploadRejected, "The uploaded object is larger or has another content type than the upload token allows.", []int{400}},
+	{ChecksumMismatch, "The uploaded object does not match the declared checksum.", []int{400}},
+	{CompromisedPassword, "The password appeared in a data breach, choose another one.", []int{400}},
+	{PasswordPolicy, "The password violates the password policy, the details list every violated rule.", []int{422}},
+	{CaptchaRequired, "The login looks risky, repeat it with a captchaToken.", []int{403}},
+	{PasskeyRequired, "The login looks risky, the user has to log in with a passkey.", []int{403}},
+	{LoginBlocked, "The login was blocked by the login risk assessment.", []int{403}},
+	{DeviceMismatch, "The refresh token was used from another device than it was issued to, the session was ended and the user has to log in again.", []int{498}},
+	{UpgradeRequired, "The client version is no longer supported, the client has to be updated.", []int{426}},
+}
@@ -5,17 +5,37 @@ type ErrorCode string
 
 // Error codes constants, unexported.
 const (
-	Unauthorized        ErrorCode = "UNAUTHORIZED"
-	ApiError            ErrorCode = "API_ERROR"
-	NotAllowed          ErrorCode = "NOT_ALLOWED"
-	NotFound            ErrorCode = "NOT_FOUND"
-	AlreadyExists       ErrorCode = "ALREADY_EXISTS"
-	WrongCredentials    ErrorCode = "WRONG_CREDENTIALS"
-	MalformedRequest    ErrorCode = "MALFORMED_REQUEST"
-	InvalidCookie       ErrorCode = "INVALID_COOKIE"
-	InvalidAccessToken  ErrorCode = "INVALID_ACCESS_TOKEN"
-	InvalidRefreshToken ErrorCode = "INVALID_REFRESH_TOKEN"
-	ExpiredAccessToken  ErrorCode = "EXPIRED_ACCESS_TOKEN"
-	ExpiredRefreshToken ErrorCode = "EXPIRED_REFRESH_TOKEN"
-	UserNotFound        ErrorCode = "USER_NOT_FOUND"
+	Unauthorized           ErrorCode = "UNAUTHORIZED"
+	ApiError               ErrorCode = "API_ERROR"
+	NotAllowed             ErrorCode = "NOT_ALLOWED"
+	NotFound               ErrorCode = "NOT_FOUND"
+	AlreadyExists          ErrorCode = "ALREADY_EXISTS"
+	WrongCredentials       ErrorCode = "WRONG_CREDENTIALS"
+	MalformedRequest       ErrorCode = "MALFORMED_REQUEST"
+	InvalidCookie          ErrorCode = "INVALID_COOKIE"
+	InvalidAccessToken     ErrorCode = "INVALID_ACCESS_TOKEN"
+	InvalidRefreshToken    ErrorCode = "INVALID_REFRESH_TOKEN"
+	ExpiredAccessToken     ErrorCode = "EXPIRED_ACCESS_TOKEN"
+	ExpiredRefreshToken    ErrorCode = "EXPIRED_REFRESH_TOKEN"
+	UserNotFound           ErrorCode = "USER_NOT_FOUND"
+	ContentPolicyViolation ErrorCode = "CONTENT_POLICY_VIOLATION"
+	BannedFromChat         ErrorCode = "BANNED_FROM_CHAT"
+	InvalidWebAuthnSession ErrorCode = "INVALID_WEBAUTHN_SESSION"
+	WebAuthnFailed         ErrorCode = "WEBAUTHN_FAILED"
+	EmailNotVerified       ErrorCode = "EMAIL_NOT_VERIFIED"
+	InvalidToken           ErrorCode = "INVALID_TOKEN"
+	UpgradeRequired        ErrorCode = "UPGRADE_REQUIRED"
+	TooManyAttempts        ErrorCode = "TOO_MANY_ATTEMPTS"
+	EmailDomainNotAllowed  ErrorCode = "EMAIL_DOMAIN_NOT_ALLOWED"
+	AccountDisabled        ErrorCode = "ACCOUNT_DISABLED"
+	QuotaExceeded          ErrorCode = "QUOTA_EXCEEDED"
+	InvalidUploadToken     ErrorCode = "INVALID_UPLOAD_TOKEN"
+	UploadRejected         ErrorCode = "UPLOAD_REJECTED"
+	ChecksumMismatch       ErrorCode = "CHECKSUM_MISMATCH"
+	CompromisedPassword    ErrorCode = "COMPROMISED_PASSWORD"
+	DeviceMismatch         ErrorCode = "DEVICE_MISMATCH"
+	PasswordPolicy         ErrorCode = "PASSWORD_POLICY"
+	CaptchaRequired        ErrorCode = "CAPTCHA_REQUIRED"
+	PasskeyRequired        ErrorCode = "PASSKEY_REQUIRED"
+	LoginBlocked           ErrorCode = "LOGIN_BLOCKED"
 )
@@ -0,0 +1,11 @@
+package enum
+
+// ChatInvites controls who can add a user to a new chat, joining public chats is always possible.
+type ChatInvites string
+
+const (
+	ChatInvitesEveryone ChatInvites = "everyone"
+	// only users that already share a chat with the user
+	ChatInvitesContacts ChatInvites = "contacts"
+	ChatInvitesNobody   ChatInvites = "nobody"
+)
@@ -0,0 +1,10 @@
+package enum
+
+// Role of a user, roles are not hierarchical and have to be listed explicitly in auth.RequireRole.
+type Role string
+
+const (
+	RoleUser      Role = "USER"
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
@@ -0,0 +1,44 @@
+package geoip
+
+import (
+	"sync"
+)
+
+// Location is the coarse location of an IP address. Empty fields mean unknown.
+type Location struct {
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// Provider resolves IP addresses to locations.
+type Provider interface {
+	Lookup(ip string) (Location, error)
+}
+
+var provider Provider
+var providerMutex sync.RWMutex
+
+// SetProvider sets the provider used by Lookup. A nil provider disables geolocation.
+func SetProvider(p Provider) {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+
+	provider = p
+}
+
+// Lookup returns the location of the ip or an empty location if it can not be resolved.
+func Lookup(ip string) Location {
+	providerMutex.RLock()
+	p := provider
+	providerMutex.RUnlock()
+
+	if p == nil {
+		return Location{}
+	}
+
+	location, err := p.Lookup(ip)
+	if err != nil {
+		return Location{}
+	}
+	return location
+}
@@ -0,0 +1,100 @@
+package geoip
+
+import (
+	"easyflow-backend/src/common"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type cityRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// MaxMindProvider reads locations from a MaxMind (GeoLite2/GeoIP2 City) database file
+// and reloads it when the file changes.
+type MaxMindProvider struct {
+	path     string
+	mutex    sync.RWMutex
+	reader   *maxminddb.Reader
+	modified time.Time
+}
+
+// NewMaxMindProvider opens the database at path and checks for a newer file every refreshInterval.
+func NewMaxMindProvider(path string, refreshInterval time.Duration, logger *common.Logger) (*MaxMindProvider, error) {
+	p := &MaxMindProvider{path: path}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+
+	if refreshInterval > 0 {
+		go func() {
+			for range time.Tick(refreshInterval) {
+				if err := p.reload(); err != nil {
+					logger.PrintfWarning("Could not reload geoip database %s: %s", path, err)
+				}
+			}
+		}()
+	}
+
+	return p, nil
+}
+
+func (p *MaxMindProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.RLock()
+	unchanged := p.reader != nil && !info.ModTime().After(p.modified)
+	p.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	reader, err := maxminddb.Open(p.path)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	old := p.reader
+	p.reader = reader
+	p.modified = info.ModTime()
+	p.mutex.Unlock()
+
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+func (p *MaxMindProvider) Lookup(ip string) (Location, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}, fmt.Errorf("invalid ip address: %s", ip)
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var record cityRecord
+	if err := p.reader.Lookup(parsed, &record); err != nil {
+		return Location{}, err
+	}
+
+	return Location{
+		Country: record.Country.IsoCode,
+		City:    record.City.Names["en"],
+	}, nil
+}
@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Send delivers a transactional plain text mail over SMTP, e.g. verification links.
+// If no SMTP host is configured the mail is only logged, which is useful during development.
+func Send(cfg *common.Config, logger *common.Logger, to string, subject string, body string) error {
+	return send(cfg, logger, to, subject, body, nil)
+}
+
+// SendNonTransactional delivers a mail the user can opt out of. It is skipped if the user unsubscribed
+// from the category or disabled email notifications and otherwise carries an unsubscribe link and List-Unsubscribe headers.
+func SendNonTransactional(db *gorm.DB, cfg *common.Config, logger *common.Logger, user *database.User, category Category, subject string, body string) error {
+	if cfg.UnsubscribeSecret == "" {
+		return ErrNoUnsubscribeSecret
+	}
+
+	settings, err := database.GetNotificationSettings(db, user.Id)
+	if err != nil {
+		return fmt.Errorf("failed to get notification settings: %w", err)
+	}
+	if !settings.Email {
+		logger.PrintfDebug("User: %s disabled email notifications, skipping", user.Id)
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&database.MailSuppression{}).
+		Where("user_id = ? AND category IN ?", user.Id, []Category{category, CategoryAll}).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check mail suppression: %w", err)
+	}
+
+	if count > 0 {
+		logger.PrintfDebug("User: %s unsubscribed from %s mails, skipping", user.Id, category)
+		return nil
+	}
+
+	link := fmt.Sprintf("%s/notifications/unsubscribe/%s", cfg.BackendURL, NewUnsubscribeToken(cfg, user.Id, category))
+	body += "\n\n--\nUnsubscribe from these mails: " + link
+
+	return send(cfg, logger, user.Email, subject, body, []string{
+		"List-Unsubscribe: <" + link + ">",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+	})
+}
+
+func send(cfg *common.Config, logger *common.Logger, to string, subject string, body string, headers []string) error {
+	if cfg.SmtpHost == "" {
+		logger.PrintfDebug("SMTP is not configured, mail to %s with subject %q:\n%s", to, subject, body)
+		return nil
+	}
+
+	lines := []string{
+		"From: " + cfg.MailFrom,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+	}
+	lines = append(lines, headers...)
+	message := strings.Join(append(lines, "", body), "\r\n")
+
+	var auth smtp.Auth
+	if cfg.SmtpUser != "" {
+		auth = smtp.PlainAuth("", cfg.SmtpUser, cfg.SmtpPassword, cfg.SmtpHost)
+	}
+
+	addr := net.JoinHostPort(cfg.SmtpHost, cfg.SmtpPort)
+	if err := smtp.SendMail(addr, auth, cfg.MailFrom, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", to, err)
+	}
+
+	logger.PrintfInfo("Sent mail with subject %q to %s", subject, to)
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"easyflow-backend/src/common"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Category groups non-transactional mails so users can unsubscribe from them separately.
+type Category string
+
+const (
+	// suppresses every non-transactional mail
+	CategoryAll           Category = "all"
+	CategoryNotifications Category = "notifications"
+)
+
+var categories = map[Category]struct{}{
+	CategoryAll:           {},
+	CategoryNotifications: {},
+}
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// ErrNoUnsubscribeSecret is returned for mails with unsubscribe links when UNSUBSCRIBE_SECRET is not set,
+// links signed with an empty key could be forged by anyone.
+var ErrNoUnsubscribeSecret = errors.New("UNSUBSCRIBE_SECRET is not set")
+
+func unsubscribeSignature(cfg *common.Config, payload string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.UnsubscribeSecret))
+	mac.Write([]byte("unsubscribe:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewUnsubscribeToken returns a signed token that can only be used to unsubscribe the user from the category.
+func NewUnsubscribeToken(cfg *common.Config, userId string, category Category) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userId + ":" + string(category)))
+	return payload + "." + unsubscribeSignature(cfg, payload)
+}
+
+// ParseUnsubscribeToken verifies the signature and returns the user and category of the token.
+func ParseUnsubscribeToken(cfg *common.Config, token string) (string, Category, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || cfg.UnsubscribeSecret == "" || !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(cfg, payload))) {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	userId, category, ok := strings.Cut(string(decoded), ":")
+	if _, known := categories[Category(category)]; !ok || !known {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	return userId, Category(category), nil
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"easyflow-backend/src/api/admin"
 	"easyflow-backend/src/api/auth"
 	"easyflow-backend/src/api/chat"
+	"easyflow-backend/src/api/keylog"
+	"easyflow-backend/src/api/meta"
+	"easyflow-backend/src/api/notifications"
+	"easyflow-backend/src/api/scim"
+	"easyflow-backend/src/api/telemetry"
 	"easyflow-backend/src/api/user"
 	"easyflow-backend/src/common"
 	"easyflow-backend/src/database"
+	"easyflow-backend/src/geoip"
+	"easyflow-backend/src/metrics"
 	"easyflow-backend/src/middleware"
 	"os"
 	"strings"
@@ -47,19 +55,59 @@ func main() {
 		dbInst.SetLogMode(logger.Silent)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(dbInst, os.Args[2:], log))
+	}
+
+	if err := checkMigration(dbInst, cfg, log); err != nil {
+		panic(err)
+	}
+
 	err := dbInst.Migrate()
 	if err != nil {
 		panic(err)
 	}
 
+	err = keylog.Backfill(dbInst.GetClient(), log)
+	if err != nil {
+		panic(err)
+	}
+
+	auth.StartApiKeyUsageFlush(dbInst.GetClient(), cfg, log)
+	if cfg.LoginRiskScoring {
+		auth.SetRiskScorer(auth.NewRuleScorer(cfg.LoginRiskVelocityThreshold))
+	}
+	user.StartGuestPurge(dbInst.GetClient(), cfg, log)
+	user.StartAccountPurge(dbInst.GetClient(), cfg, log)
+	user.StartEmailFilter(dbInst.GetClient(), cfg, log)
+	chat.StartMessageArchiver(dbInst.GetClient(), cfg, log)
+	chat.StartMessageExpiry(dbInst.GetClient(), cfg, log)
+	chat.StartStreamBandwidthFlush(dbInst.GetClient(), cfg, log)
+
+	if cfg.GeoIPDatabasePath != "" {
+		provider, err := geoip.NewMaxMindProvider(cfg.GeoIPDatabasePath, time.Duration(cfg.GeoIPRefreshInterval)*time.Second, log)
+		if err != nil {
+			log.PrintfError("Could not load geoip database, continuing without geolocation: %s", err)
+		} else {
+			geoip.SetProvider(provider)
+		}
+	}
+
 	router := gin.New()
 
-	err = router.SetTrustedProxies(nil)
+	// without trusted proxies gin ignores forwarded headers and uses the remote address of the connection
+	err = router.SetTrustedProxies(cfg.TrustedProxies)
 	if err != nil {
-		log.PrintfError("Could not set trusted proxies list")
+		log.PrintfError("Could not set trusted proxies list: %s", err)
 		return
 	}
 
+	if len(cfg.RemoteIPHeaders) > 0 {
+		router.RemoteIPHeaders = cfg.RemoteIPHeaders
+	}
+
+	log.Printf("Trusted proxies: %v, client IP headers: %v", cfg.TrustedProxies, router.RemoteIPHeaders)
+
 	router.RedirectFixedPath = true
 	router.RedirectTrailingSlash = true
 
@@ -67,17 +115,23 @@ func main() {
 
 	router.Use(cors.CorsMiddleware(cors.Config{
 		AllowedOrigins:   strings.Split(cfg.FrontendURL, ", "),
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Authorization", "Content-Length", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Length", "Content-Type", "X-Client-Version", "If-None-Match", "X-Api-Key"},
+		ExposeHeaders:    []string{"Content-Length", "X-Client-Deprecated", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	router.Use(middleware.DatabaseMiddleware(dbInst.GetClient()))
 	router.Use(middleware.ConfigMiddleware(cfg))
+	router.Use(middleware.CaptureMiddleware(cfg.DebugCaptureRate, cfg.DebugCaptureSize))
+	router.Use(middleware.ClientVersionMiddleware(cfg.MinClientVersion, cfg.DeprecatedClientVersion))
 	router.Use(gin.Recovery())
 
+	if cfg.MetricsToken != "" {
+		router.GET("/metrics", gin.WrapH(metrics.Handler(cfg.MetricsToken)))
+	}
+
 	//register user endpoints
 	userEndpoints := router.Group("/user")
 	{
@@ -97,8 +151,74 @@ func main() {
 		chat.RegisterChatEndpoints(chatEndpoints)
 	}
 
-	log.Printf("Starting server on port %s", cfg.Port)
-	err = router.Run(":" + cfg.Port)
+	notificationEndpoints := router.Group("/notifications")
+	{
+		log.Printf("Registering notification endpoints")
+		notifications.RegisterNotificationEndpoints(notificationEndpoints)
+	}
+
+	keyLogEndpoints := router.Group("/key-log")
+	{
+		log.Printf("Registering key log endpoints")
+		keylog.RegisterKeyLogEndpoints(keyLogEndpoints)
+	}
+
+	metaEndpoints := router.Group("/meta")
+	{
+		log.Printf("Registering meta endpoints")
+		meta.RegisterMetaEndpoints(metaEndpoints)
+	}
+
+	scimEndpoints := router.Group("/scim/v2")
+	{
+		log.Printf("Registering scim endpoints")
+		scim.RegisterScimEndpoints(scimEndpoints)
+	}
+
+	telemetryEndpoints := router.Group("/telemetry")
+	{
+		log.Printf("Registering telemetry endpoints")
+		telemetry.RegisterTelemetryEndpoints(telemetryEndpoints)
+	}
+
+	adminEndpoints := router.Group("/admin")
+	{
+		log.Printf("Registering admin endpoints")
+		admin.RegisterAdminEndpoints(adminEndpoints)
+	}
+
+	tlsConfig, acmeHandler, err := newTLSConfig(cfg)
+	if err != nil {
+		log.PrintfError("Failed to load TLS configuration: %s", err)
+		return
+	}
+
+	server := newHTTPServer(cfg, ":"+cfg.Port, router)
+	server.TLSConfig = tlsConfig
+
+	if tlsConfig == nil {
+		log.Printf("Starting server on port %s", cfg.Port)
+		err = server.ListenAndServe()
+	} else {
+		if cfg.HTTPRedirectPort != "" {
+			redirectHandler := redirectToHTTPS(cfg)
+			if acmeHandler != nil {
+				redirectHandler = acmeHandler(redirectHandler)
+			}
+
+			go func() {
+				log.Printf("Redirecting HTTP on port %s to HTTPS", cfg.HTTPRedirectPort)
+				if err := newHTTPServer(cfg, ":"+cfg.HTTPRedirectPort, redirectHandler).ListenAndServe(); err != nil {
+					log.PrintfError("HTTP redirect server stopped: %s", err)
+				}
+			}()
+		}
+
+		log.Printf("Starting TLS server on port %s", cfg.Port)
+		// certificates are already part of the TLS config
+		err = server.ListenAndServeTLS("", "")
+	}
+
 	if err != nil {
 		log.PrintfError("Failed to start server: %s", err)
 		return
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	storageOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "easyflow",
+		Subsystem: "storage",
+		Name:      "operations_total",
+		Help:      "Number of object store operations by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	storageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "easyflow",
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of object store operations by operation and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+
+	databaseOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "easyflow",
+		Subsystem: "database",
+		Name:      "operations_total",
+		Help:      "Number of database operations by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	databaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "easyflow",
+		Subsystem: "database",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of database operations by operation and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+
+	connectionRtt = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "easyflow",
+		Subsystem: "connection",
+		Name:      "rtt_seconds",
+		Help:      "Round trip times reported by clients.",
+		Buckets:   []float64{.025, .05, .1, .15, .25, .5, 1, 2.5, 5},
+	})
+
+	connectionJitter = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "easyflow",
+		Subsystem: "connection",
+		Name:      "jitter_seconds",
+		Help:      "Jitter reported by clients.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1},
+	})
+
+	abuseActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "easyflow",
+		Subsystem: "antiabuse",
+		Name:      "actions_total",
+		Help:      "Number of flagged and throttled requests by heuristic and action.",
+	}, []string{"heuristic", "action"})
+)
+
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// ObserveStorage records an object store call that started at start.
+func ObserveStorage(operation string, start time.Time, err error) {
+	o := outcome(err)
+	storageOperations.WithLabelValues(operation, o).Inc()
+	storageDuration.WithLabelValues(operation, o).Observe(time.Since(start).Seconds())
+}
+
+// ObserveDatabase records a database call that started at start.
+func ObserveDatabase(operation string, start time.Time, err error) {
+	o := outcome(err)
+	databaseOperations.WithLabelValues(operation, o).Inc()
+	databaseDuration.WithLabelValues(operation, o).Observe(time.Since(start).Seconds())
+}
+
+// ObserveConnectionQuality records a client report of round trip time and jitter in seconds.
+func ObserveConnectionQuality(rtt float64, jitter float64) {
+	connectionRtt.Observe(rtt)
+	connectionJitter.Observe(jitter)
+}
+
+// ObserveAbuseAction records a request the antiabuse heuristics flagged or throttled.
+func ObserveAbuseAction(heuristic string, action string) {
+	abuseActions.WithLabelValues(heuristic, action).Inc()
+}
+
+// Handler serves the metrics to scrapers that send the token as bearer token.
+func Handler(token string) http.Handler {
+	metrics := promhttp.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
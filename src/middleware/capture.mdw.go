@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodies larger than this are not captured
+const maxCapturedBodySize = 64 * 1024
+
+var redactedKeys = map[string]struct{}{
+	"password":     {},
+	"newpassword":  {},
+	"oldpassword":  {},
+	"privatekey":   {},
+	"key":          {},
+	"accesstoken":  {},
+	"refreshtoken": {},
+	"token":        {},
+}
+
+type CapturedRequest struct {
+	Time         time.Time   `json:"time"`
+	Method       string      `json:"method"`
+	Path         string      `json:"path"`
+	Status       int         `json:"status"`
+	RequestId    string      `json:"requestId"`
+	RequestBody  interface{} `json:"requestBody,omitempty"`
+	ResponseBody interface{} `json:"responseBody,omitempty"`
+}
+
+type captureStore struct {
+	mutex   sync.Mutex
+	entries []CapturedRequest
+	next    int
+}
+
+var captures = &captureStore{}
+
+func (s *captureStore) add(entry CapturedRequest, size int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if cap(s.entries) != size {
+		s.entries = make([]CapturedRequest, 0, size)
+		s.next = 0
+	}
+
+	if len(s.entries) < size {
+		s.entries = append(s.entries, entry)
+	} else {
+		s.entries[s.next] = entry
+	}
+	s.next = (s.next + 1) % size
+}
+
+// GetCapturedRequests returns the captured requests that are younger than maxAge, newest first.
+func GetCapturedRequests(maxAge time.Duration) []CapturedRequest {
+	captures.mutex.Lock()
+	defer captures.mutex.Unlock()
+
+	result := []CapturedRequest{}
+	for i := range captures.entries {
+		// walk backwards from the most recent entry
+		index := (captures.next - 1 - i + len(captures.entries)) % len(captures.entries)
+		entry := captures.entries[index]
+		if time.Since(entry.Time) <= maxAge {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	if w.body.Len()+len(b) <= maxCapturedBodySize {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// redact replaces the values of sensitive keys in decoded json.
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if _, ok := redactedKeys[strings.ToLower(key)]; ok {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redact(inner)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redact(inner)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func decodeBody(body []byte) interface{} {
+	if len(body) == 0 || len(body) > maxCapturedBodySize {
+		return nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		// only json bodies can be redacted reliably
+		return "[NON-JSON BODY OMITTED]"
+	}
+	return redact(decoded)
+}
+
+// CaptureMiddleware stores redacted request and response bodies for a sampled share of requests
+// so they can be inspected by admins. A rate of 0 disables the capturing.
+func CaptureMiddleware(rate float64, size int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rate <= 0 || size <= 0 || rand.Float64() >= rate {
+			c.Next()
+			return
+		}
+
+		// chunked bodies have no content length, at most one byte more than is captured is read ahead
+		var requestBody []byte
+		if c.Request.Body != nil && c.Request.ContentLength <= maxCapturedBodySize {
+			requestBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(maxCapturedBodySize)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), c.Request.Body), c.Request.Body}
+		}
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+
+		c.Next()
+
+		captures.add(CapturedRequest{
+			Time:         time.Now(),
+			Method:       c.Request.Method,
+			Path:         c.Request.URL.Path,
+			Status:       writer.Status(),
+			RequestId:    writer.Header().Get("X-Request-ID"),
+			RequestBody:  decodeBody(requestBody),
+			ResponseBody: decodeBody(writer.body.Bytes()),
+		}, size)
+	}
+}
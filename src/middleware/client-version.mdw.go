@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"easyflow-backend/src/api"
+	"easyflow-backend/src/enum"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseVersion parses a dotted version like "1.4.2" or "v1.4", pre-release suffixes are ignored.
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "-")
+	if version == "" {
+		return parts, false
+	}
+
+	for i, part := range strings.SplitN(version, ".", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+
+	return parts, true
+}
+
+// compareVersions returns -1, 0 or 1 if a is lower, equal or higher than b.
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// ClientVersionMiddleware checks the X-Client-Version header of the request.
+// Clients below minVersion are rejected with 426 Upgrade Required, clients below deprecatedVersion
+// receive an X-Client-Deprecated header so they can ask the user to upgrade before they are cut off.
+// Requests without the header or with an empty minimum version are let through.
+func ClientVersionMiddleware(minVersion string, deprecatedVersion string) gin.HandlerFunc {
+	min, minOk := parseVersion(minVersion)
+	deprecated, deprecatedOk := parseVersion(deprecatedVersion)
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("X-Client-Version")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		version, ok := parseVersion(header)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, api.ApiError{
+				Code:    http.StatusBadRequest,
+				Error:   enum.MalformedRequest,
+				Details: fmt.Sprintf("Invalid client version: %s", header),
+			})
+			return
+		}
+
+		if minOk && compareVersions(version, min) < 0 {
+			c.AbortWithStatusJSON(http.StatusUpgradeRequired, api.ApiError{
+				Code:    http.StatusUpgradeRequired,
+				Error:   enum.UpgradeRequired,
+				Details: gin.H{"minVersion": minVersion},
+			})
+			return
+		}
+
+		if deprecatedOk && compareVersions(version, deprecated) < 0 {
+			c.Header("X-Client-Deprecated", deprecatedVersion)
+		}
+
+		c.Next()
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func LoggerMiddleware(module_name string) gin.HandlerFunc {
@@ -33,7 +34,18 @@ func LoggerMiddleware(module_name string) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("logger", common.NewLogger(os.Stdout, module_name, c, common.LogLevel(config.LogLevel)))
+		requestId := c.GetHeader("X-Request-ID")
+		if requestId == "" {
+			requestId = uuid.NewString()
+		}
+		c.Header("X-Request-ID", requestId)
+
+		logger := common.NewLogger(os.Stdout, module_name, c, common.LogLevel(config.LogLevel)).With(
+			common.Field{Key: "request", Value: requestId},
+			common.Field{Key: "route", Value: c.FullPath()},
+		)
+
+		c.Set("logger", logger)
 		c.Next()
 	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"easyflow-backend/src/common"
+	"easyflow-backend/src/database"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// runMigrateCommand implements "easyflow-backend migrate [--dry-run]" and returns the exit code.
+// The dry run prints the pending statements and exits with 1 if any of them is destructive.
+func runMigrateCommand(dbInst *database.DatabaseInst, args []string, log *common.Logger) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the pending schema changes without applying them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if !*dryRun {
+		if err := dbInst.Migrate(); err != nil {
+			log.PrintfError("Migration failed: %s", err)
+			return 1
+		}
+		log.Printf("Migration finished")
+		return 0
+	}
+
+	plan, err := dbInst.PlanMigration()
+	if err != nil {
+		log.PrintfError("Could not plan migration: %s", err)
+		return 1
+	}
+
+	if len(plan.Statements) == 0 {
+		fmt.Fprintln(os.Stdout, "-- schema is up to date")
+	}
+	for _, statement := range plan.Statements {
+		if slices.Contains(plan.Destructive, statement) {
+			fmt.Fprintln(os.Stdout, "-- destructive")
+		}
+		fmt.Fprintf(os.Stdout, "%s;\n", statement)
+	}
+	for _, column := range plan.UnknownColumns {
+		fmt.Fprintf(os.Stdout, "-- unknown column: %s\n", column)
+	}
+
+	if len(plan.Destructive) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkMigration logs the schema drift before the startup migration. In production it refuses destructive
+// migrations unless MIGRATE_ALLOW_DESTRUCTIVE is set, so a deploy cannot change existing columns by accident.
+func checkMigration(dbInst *database.DatabaseInst, cfg *common.Config, log *common.Logger) error {
+	plan, err := dbInst.PlanMigration()
+	if err != nil {
+		return err
+	}
+
+	for _, column := range plan.UnknownColumns {
+		log.PrintfWarning("Column %s is not part of the models", column)
+	}
+	if len(plan.Statements) > 0 {
+		log.Printf("Applying %d pending schema changes", len(plan.Statements))
+	}
+	for _, statement := range plan.Destructive {
+		log.PrintfWarning("Destructive schema change: %s", statement)
+	}
+
+	if len(plan.Destructive) > 0 && cfg.Stage == "production" && !cfg.MigrateAllowDestructive {
+		return errors.New("migration would modify existing columns, review it with \"migrate --dry-run\" and set MIGRATE_ALLOW_DESTRUCTIVE to apply it")
+	}
+
+	return nil
+}